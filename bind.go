@@ -0,0 +1,399 @@
+package tbcomctl
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// bindTag is the struct tag that maps the struct fields to the form
+// controllers.
+const bindTag = "form"
+
+// defaultLayouts are the time layouts that are tried, if the field has no
+// layout in the tag.
+var defaultLayouts = []string{time.RFC3339, "2006-01-02T15:04", "2006-01-02", "15:04"}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	durationType = reflect.TypeOf(time.Duration(0))
+)
+
+// FieldError is the error that occurred while converting the controller value
+// to the struct field.
+type FieldError struct {
+	Field   string // struct field name
+	Control string // controller name
+	Value   string // controller value
+	Err     error
+}
+
+func (e *FieldError) Error() string {
+	return fmt.Sprintf("field %s (%s): %q: %s", e.Field, e.Control, e.Value, e.Err)
+}
+
+func (e *FieldError) Unwrap() error { return e.Err }
+
+// BindError is the list of errors of all fields that could not be converted.
+type BindError []*FieldError
+
+func (e BindError) Error() string {
+	var msgs = make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Error()
+	}
+	return "bind: " + strings.Join(msgs, "; ")
+}
+
+// fieldTag is the parsed form tag.
+type fieldTag struct {
+	name   string // controller name
+	layout string // time layout
+}
+
+// parseTag parses the form tag, that has the following format:
+//
+//	form:"name[,layout=2006-01-02]"
+func parseTag(tag string) fieldTag {
+	parts := strings.Split(tag, ",")
+	ft := fieldTag{name: parts[0]}
+	for _, p := range parts[1:] {
+		if strings.HasPrefix(p, "layout=") {
+			ft.layout = strings.TrimPrefix(p, "layout=")
+		}
+	}
+	return ft
+}
+
+// boundField is the struct field mapped to the controller.
+type boundField struct {
+	field reflect.StructField
+	value reflect.Value
+	tag   fieldTag
+}
+
+// boundFields returns the fields of the struct pointed by ptr, that have the
+// form tag.
+func boundFields(ptr interface{}) ([]boundField, error) {
+	v := reflect.ValueOf(ptr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Struct {
+		return nil, errors.New("bind: pointer to struct expected")
+	}
+	v = v.Elem()
+	t := v.Type()
+	var fields []boundField
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(bindTag)
+		if !ok || tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		ft := parseTag(tag)
+		if ft.name == "" {
+			ft.name = sf.Name
+		}
+		fields = append(fields, boundField{field: sf, value: v.Field(i), tag: ft})
+	}
+	return fields, nil
+}
+
+// Bind decodes the form data of the recipient into the struct pointed by dst.
+// The struct fields are mapped to the form controllers by the "form" tag,
+// which contains the controller name and, optionally, the time layout:
+//
+//	type Profile struct {
+//		Name     string    `form:"name"`
+//		Age      int       `form:"age"`
+//		Birthday time.Time `form:"birthday,layout=02.01.2006"`
+//		Hobbies  []string  `form:"hobbies"`
+//	}
+//
+// Supported field types are strings, integers, floats, bools, time.Time,
+// time.Duration, types implementing encoding.TextUnmarshaler, pointers to and
+// slices of them.  Slices are decoded from the list values of multi-value
+// controllers.  Fields, for which the controller has no value, and
+// non-string fields with empty values (i.e. skipped optional inputs) are left
+// untouched.  If some values can't be converted, Bind converts the rest and
+// returns BindError with all the errors.  If the controllers of the form have
+// different scopes, use BindContext.
+func (fm *Form) Bind(r tb.Recipient, dst interface{}) error {
+	return fm.bind(func(Controller) string { return r.Recipient() }, dst)
+}
+
+// BindContext is the Bind, that decodes the form data for the context, the
+// value of each controller is taken for the recipient of the controller
+// scope, see Form.DataContext.
+func (fm *Form) BindContext(c tb.Context, dst interface{}) error {
+	return fm.bind(contextRecipient(c), dst)
+}
+
+// bind decodes the form data into dst, the value of each controller is taken
+// for the recipient returned by fn.
+func (fm *Form) bind(fn recipientFunc, dst interface{}) error {
+	fields, err := boundFields(dst)
+	if err != nil {
+		return err
+	}
+	var errs BindError
+	for _, f := range fields {
+		ctrl, ok := fm.Controller(f.tag.name)
+		if !ok {
+			continue
+		}
+		val, ok := ctrl.Value(fn(ctrl))
+		if !ok || (val == "" && f.value.Kind() != reflect.String) {
+			continue
+		}
+		if err := decodeValue(f.value, val, f.tag); err != nil {
+			errs = append(errs, &FieldError{Field: f.field.Name, Control: f.tag.name, Value: val, Err: err})
+		}
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// valueSetter is the interface for the controllers that allow setting the
+// value.
+type valueSetter interface {
+	SetValue(recipient string, value string)
+}
+
+// Fill sets the values of the form controllers for the recipient from the
+// struct pointed by src, i.e. to pre-fill the form when user edits the data
+// entered before.  Struct is mapped to the controllers in the same way as in
+// Bind.  Nil pointers are skipped.  If the controllers of the form have
+// different scopes, use FillContext.
+func (fm *Form) Fill(r tb.Recipient, src interface{}) error {
+	return fm.fill(func(Controller) string { return r.Recipient() }, src)
+}
+
+// FillContext is the Fill, that sets the values for the context, the value of
+// each controller is set for the recipient of the controller scope, see
+// Form.DataContext.
+func (fm *Form) FillContext(c tb.Context, src interface{}) error {
+	return fm.fill(contextRecipient(c), src)
+}
+
+// fill sets the values of the form controllers from src, the value of each
+// controller is set for the recipient returned by fn.
+func (fm *Form) fill(fn recipientFunc, src interface{}) error {
+	fields, err := boundFields(src)
+	if err != nil {
+		return err
+	}
+	var errs BindError
+	for _, f := range fields {
+		ctrl, ok := fm.Controller(f.tag.name)
+		if !ok {
+			continue
+		}
+		vs, ok := ctrl.(valueSetter)
+		if !ok {
+			continue
+		}
+		if f.value.Kind() == reflect.Ptr && f.value.IsNil() {
+			continue
+		}
+		val, err := encodeValue(f.value, f.tag)
+		if err != nil {
+			errs = append(errs, &FieldError{Field: f.field.Name, Control: f.tag.name, Err: err})
+			continue
+		}
+		vs.SetValue(fn(ctrl), val)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// decodeValue converts s to the type of v and sets v.
+func decodeValue(v reflect.Value, s string, tag fieldTag) error {
+	switch {
+	case v.Type() == timeType:
+		t, err := parseTime(s, tag.layout)
+		if err != nil {
+			return err
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+		return nil
+	case v.Kind() == reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := decodeValue(elem.Elem(), s, tag); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	case v.CanAddr():
+		if u, ok := v.Addr().Interface().(encoding.TextUnmarshaler); ok {
+			return u.UnmarshalText([]byte(s))
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(f)
+	case reflect.Bool:
+		b, err := parseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Slice:
+		items, err := decodeList(s)
+		if err != nil {
+			return err
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(slice.Index(i), item, tag); err != nil {
+				return fmt.Errorf("item %d: %w", i, err)
+			}
+		}
+		v.Set(slice)
+	default:
+		return fmt.Errorf("unsupported type: %s", v.Type())
+	}
+	return nil
+}
+
+// encodeValue converts v to the string representation, that can be decoded
+// by decodeValue.
+func encodeValue(v reflect.Value, tag fieldTag) (string, error) {
+	switch {
+	case v.Type() == timeType:
+		layout := tag.layout
+		if layout == "" {
+			layout = time.RFC3339
+		}
+		return v.Interface().(time.Time).Format(layout), nil
+	case v.Type() == durationType:
+		return time.Duration(v.Int()).String(), nil
+	case v.Kind() == reflect.Ptr:
+		return encodeValue(v.Elem(), tag)
+	}
+	if m, ok := v.Interface().(encoding.TextMarshaler); ok {
+		b, err := m.MarshalText()
+		return string(b), err
+	}
+
+	switch v.Kind() {
+	case reflect.String:
+		return v.String(), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return strconv.FormatUint(v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()), nil
+	case reflect.Bool:
+		return strconv.FormatBool(v.Bool()), nil
+	case reflect.Slice:
+		items := make([]string, v.Len())
+		for i := range items {
+			s, err := encodeValue(v.Index(i), tag)
+			if err != nil {
+				return "", fmt.Errorf("item %d: %w", i, err)
+			}
+			items[i] = s
+		}
+		return encodeList(items), nil
+	}
+	return "", fmt.Errorf("unsupported type: %s", v.Type())
+}
+
+// parseTime parses the time using the layout, or, if it's empty, the default
+// layouts.
+func parseTime(s string, layout string) (time.Time, error) {
+	if layout != "" {
+		return time.Parse(layout, s)
+	}
+	var err error
+	for _, l := range defaultLayouts {
+		var t time.Time
+		if t, err = time.Parse(l, s); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, err
+}
+
+// parseBool parses the bool value, in addition to the values supported by
+// strconv.ParseBool, it understands "yes" and "no".
+func parseBool(s string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(s)) {
+	case "yes", "y":
+		return true, nil
+	case "no", "n":
+		return false, nil
+	}
+	return strconv.ParseBool(s)
+}
+
+// encodeList encodes the list of values in a single string, that is stored in
+// the registry by the multi-value controllers.
+func encodeList(items []string) string {
+	if items == nil {
+		items = []string{}
+	}
+	b, err := json.Marshal(items)
+	if err != nil {
+		panic(err) // can't happen with strings.
+	}
+	return string(b)
+}
+
+// decodeList decodes the list, encoded with encodeList.  For convenience, the
+// string that is not a JSON array is treated as a comma-separated list.
+func decodeList(s string) ([]string, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return []string{}, nil
+	}
+	if !strings.HasPrefix(s, "[") {
+		items := strings.Split(s, ",")
+		for i := range items {
+			items[i] = strings.TrimSpace(items[i])
+		}
+		return items, nil
+	}
+	var items []string
+	if err := json.Unmarshal([]byte(s), &items); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
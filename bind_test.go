@@ -0,0 +1,120 @@
+package tbcomctl
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+type testProfile struct {
+	Name     string        `form:"name"`
+	Age      int           `form:"age"`
+	Height   float64       `form:"height"`
+	Married  bool          `form:"married"`
+	Birthday time.Time     `form:"birthday,layout=02.01.2006"`
+	Reminder time.Duration `form:"reminder"`
+	Hobbies  []string      `form:"hobbies"`
+	Lucky    []int         `form:"lucky"`
+	Nick     *string       `form:"nick"`
+	Skipped  string        `form:"-"`
+	Untagged string
+}
+
+func newTestProfileForm() *Form {
+	var ctrls []Controller
+	for _, name := range []string{"name", "age", "height", "married", "birthday", "reminder", "hobbies", "lucky", "nick", "Untagged"} {
+		ctrls = append(ctrls, NewInputText(name, name, nil))
+	}
+	return NewForm(ctrls...)
+}
+
+func TestForm_Bind(t *testing.T) {
+	user := &tb.User{ID: 42}
+	fm := newTestProfileForm()
+	values := map[string]string{
+		"name":     "Bob",
+		"age":      "42",
+		"height":   "1,85",
+		"married":  "yes",
+		"birthday": "02.01.1980",
+		"reminder": "1h30m",
+		"hobbies":  `["chess","go"]`,
+		"lucky":    "7, 13",
+		"nick":     "bobby",
+		"Untagged": "must not be set",
+	}
+	for name, val := range values {
+		fm.cm[name].(valueSetter).SetValue(user.Recipient(), val)
+	}
+
+	var got testProfile
+	if err := fm.Bind(user, &got); err != nil {
+		t.Fatal(err)
+	}
+	nick := "bobby"
+	assert.Equal(t, testProfile{
+		Name:     "Bob",
+		Age:      42,
+		Height:   1.85,
+		Married:  true,
+		Birthday: time.Date(1980, 1, 2, 0, 0, 0, 0, time.UTC),
+		Reminder: 90 * time.Minute,
+		Hobbies:  []string{"chess", "go"},
+		Lucky:    []int{7, 13},
+		Nick:     &nick,
+	}, got)
+}
+
+func TestForm_Bind_errors(t *testing.T) {
+	user := &tb.User{ID: 42}
+	fm := newTestProfileForm()
+	fm.cm["name"].(valueSetter).SetValue(user.Recipient(), "Bob")
+	fm.cm["age"].(valueSetter).SetValue(user.Recipient(), "forty two")
+	fm.cm["married"].(valueSetter).SetValue(user.Recipient(), "perhaps")
+
+	var got testProfile
+	err := fm.Bind(user, &got)
+	var bindErr BindError
+	if !errors.As(err, &bindErr) {
+		t.Fatalf("expected BindError, got: %v", err)
+	}
+	assert.Len(t, bindErr, 2)
+	assert.Equal(t, "Age", bindErr[0].Field)
+	assert.True(t, errors.Is(bindErr[0], strconv.ErrSyntax))
+	assert.Equal(t, "married", bindErr[1].Control)
+	// valid fields are still bound.
+	assert.Equal(t, "Bob", got.Name)
+
+	assert.Error(t, fm.Bind(user, got), "non-pointer must be rejected")
+}
+
+func TestForm_Fill(t *testing.T) {
+	user := &tb.User{ID: 42}
+	fm := newTestProfileForm()
+	src := testProfile{
+		Name:     "Alice",
+		Age:      30,
+		Height:   1.7,
+		Birthday: time.Date(1990, 5, 17, 0, 0, 0, 0, time.UTC),
+		Reminder: 15 * time.Minute,
+		Hobbies:  []string{"a, b", "c"},
+		Lucky:    []int{3},
+	}
+	if err := fm.Fill(user, &src); err != nil {
+		t.Fatal(err)
+	}
+	val, _ := fm.Value("birthday", user.Recipient())
+	assert.Equal(t, "17.05.1990", val)
+	_, ok := fm.Value("nick", user.Recipient())
+	assert.False(t, ok, "nil pointers must be skipped")
+
+	var got testProfile
+	if err := fm.Bind(user, &got); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, src, got)
+}
@@ -4,8 +4,11 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 
 	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
 )
 
 // Button is the control button.
@@ -38,8 +41,12 @@ func (b *buttons) SetMaxButtons(n int) {
 	b.maxButtons = n
 }
 
+// pbPrefix is the prefix of the post buttons callback ID.
+const pbPrefix = "postbuttons"
+
 type PostButtons struct {
 	*buttons
+	name string
 	cbFn tb.HandlerFunc
 }
 
@@ -51,9 +58,18 @@ func PBOptMaxButtons(n int) PBOption {
 	}
 }
 
+// PBOptName sets the name of the PostButtons, it must be set if the bot uses
+// several PostButtons with different callback functions.
+func PBOptName(name string) PBOption {
+	return func(pb *PostButtons) {
+		pb.name = name
+	}
+}
+
 // NewPostButtons creates an instance of PostButtons.  The callbackFunction is
 // the function that will be assigned and called for each button press, so it
-// should handle all possible values.
+// should handle all possible values.  The callback data of the button, as
+// returned by the telebot.Context.Data, is the label of the button.
 func NewPostButtons(callbackFn func(c tb.Context) error, opts ...PBOption) *PostButtons {
 	pb := &PostButtons{
 		cbFn:    callbackFn,
@@ -65,32 +81,81 @@ func NewPostButtons(callbackFn func(c tb.Context) error, opts ...PBOption) *Post
 	return pb
 }
 
-// Markup returns the markup with buttons labeled with labels.
+// Markup returns the markup with buttons labeled with labels.  The labels are
+// encoded in the callback data, so they must be short enough to fit into the
+// Telegram limit, otherwise an error is returned.
 func (pb *PostButtons) Markup(c tb.Context, labels []string, pattern ...uint) (*tb.ReplyMarkup, error) {
-	if len(pattern) == 0 {
-		return ButtonMarkup(c, labels, pb.maxButtons, pb.cbFn), nil
-	}
-	markup, err := ButtonPatternMarkup(c, labels, pattern, pb.cbFn)
+	markup := new(tb.ReplyMarkup)
+	id := callbackID(pbPrefix, pb.name)
+	btns, err := encodedButtons(markup, id, multibuttonVersion, labels, labels)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	var rows []tb.Row
+	if len(pattern) == 0 {
+		rows = OrganizeButtons(btns, pb.maxButtons)
+	} else {
+		if rows, err = organizeButtonsPattern(btns, pattern); err != nil {
+			return nil, err
+		}
 	}
+	markup.Inline(rows...)
+	pb.Register(bot(c.Bot()))
 	return markup, nil
 }
 
-// ButtonMarkup returns the button markup for the message.  It creates handlers
-// for all the buttons assigning the cbFn callback function to each of them.
-// Values must be unique.  maxRowButtons is maximum number of buttons in a row.
+// callback decodes the callback data and calls the callback function with the
+// button label as the data.
+func (pb *PostButtons) callback(c tb.Context) error {
+	d, ok := decodeCallback(c, callbackID(pbPrefix, pb.name), "")
+	if !ok {
+		return nil
+	}
+	if d.Version != multibuttonVersion {
+		return respondStale(c, "")
+	}
+	return pb.cbFn(withCallbackData(c, d.Payload))
+}
+
+// Register registers the post buttons callback with the dispatcher of the bot.
+// It should be called before the bot is started, so that the buttons of the
+// posts published before the restart are handled.
+func (pb *PostButtons) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(callbackID(pbPrefix, pb.name), pb, pb.callback)
+}
+
+// ButtonMarkup returns the button markup for the message.  The cbFn callback
+// function is called for all the buttons, see Dispatcher.  Each distinct set of
+// values registers a new callback, the markup with the same values replaces
+// the callback.  For dynamic values use ButtonMarkupNamed or PostButtons.
+// maxRowButtons is maximum number of buttons in a row.
 func ButtonMarkup(c tb.Context, values []string, maxRowButtons int, cbFn func(c tb.Context) error) *tb.ReplyMarkup {
+	return ButtonMarkupNamed(c, valuesName(values), values, maxRowButtons, cbFn)
+}
+
+// ButtonMarkupNamed is the ButtonMarkup, that identifies the buttons by name:
+// the buttons with the same name share one callback, and only the buttons of
+// the last markup with that name are handled, the buttons of the earlier
+// markups with other values are reported as outdated.
+func ButtonMarkupNamed(c tb.Context, name string, values []string, maxRowButtons int, cbFn func(c tb.Context) error) *tb.ReplyMarkup {
 	if maxRowButtons <= 0 || defNumButtons < maxRowButtons {
 		maxRowButtons = defNumButtons
 	}
-	markup, btns := createButtons(c, values, cbFn)
+	markup, btns := createButtons(c, name, values, cbFn)
 	markup.Inline(OrganizeButtons(btns, maxRowButtons)...)
 	return markup
 }
 
+// ButtonPatternMarkup is the ButtonMarkup, that organises the buttons with the
+// pattern, see PickOptBtnPattern.
 func ButtonPatternMarkup(c tb.Context, values []string, pattern []uint, cbFn tb.HandlerFunc) (*tb.ReplyMarkup, error) {
-	markup, btns := createButtons(c, values, cbFn)
+	return ButtonPatternMarkupNamed(c, valuesName(values), values, pattern, cbFn)
+}
+
+// ButtonPatternMarkupNamed is the ButtonMarkupNamed, that organises the buttons
+// with the pattern, see PickOptBtnPattern.
+func ButtonPatternMarkupNamed(c tb.Context, name string, values []string, pattern []uint, cbFn tb.HandlerFunc) (*tb.ReplyMarkup, error) {
+	markup, btns := createButtons(c, name, values, cbFn)
 	rows, err := organizeButtonsPattern(btns, pattern)
 	if err != nil {
 		return nil, err
@@ -99,18 +164,42 @@ func ButtonPatternMarkup(c tb.Context, values []string, pattern []uint, cbFn tb.
 	return markup, nil
 }
 
+// valuesName returns the name of the buttons derived from the values.
+func valuesName(values []string) string {
+	return strings.Join(values, "\n")
+}
+
 func bot(b tb.API) *tb.Bot {
 	return b.(*tb.Bot)
 }
 
-func createButtons(c tb.Context, values []string, cbFn func(c tb.Context) error) (*tb.ReplyMarkup, []tb.Btn) {
+// createButtons creates the buttons labeled with values, the callback data, as
+// returned by the telebot.Context.Data in cbFn, is the value.  The buttons are
+// routed by the Dispatcher, the control ID is derived from the name, so that
+// each name has one callback, that handles the buttons of the last values.
+func createButtons(c tb.Context, name string, values []string, cbFn func(c tb.Context) error) (*tb.ReplyMarkup, []tb.Btn) {
+	values = append([]string(nil), values...)
+	id, version := callbackID("buttons", name), codec.Version(values...)
+	payloads := make([]string, len(values))
+	for i := range values {
+		payloads[i] = strconv.Itoa(i)
+	}
 	markup := new(tb.ReplyMarkup)
-	var btns []tb.Btn
-	for _, label := range values {
-		btn := markup.Data(label, hash(label), label)
-		btns = append(btns, btn)
-		bot(c.Bot()).Handle(&btn, cbFn)
+	btns, err := encodedButtons(markup, id, version, values, payloads)
+	if err != nil {
+		panic(err) // can't happen, the payloads are short.
 	}
+	DispatcherFor(bot(c.Bot())).handle(id, id, func(c tb.Context) error {
+		d, ok := decodeCallback(c, id, "")
+		if !ok {
+			return nil
+		}
+		idx, err := strconv.Atoi(d.Payload)
+		if err != nil || idx < 0 || len(values) <= idx || d.Version != version {
+			return respondStale(c, "")
+		}
+		return cbFn(withCallbackData(c, values[idx]))
+	})
 	return markup, btns
 }
 
@@ -156,7 +245,7 @@ func organizeButtonsPattern(btns []tb.Btn, pattern []uint) ([]tb.Row, error) {
 		if offset >= uint(len(btns)) {
 			break
 		}
-		for i := offset; i-offset < perRow; i++ {
+		for i := offset; i-offset < perRow && i < uint(len(btns)); i++ {
 			row = append(row, btns[i])
 		}
 		rows = append(rows, row)
@@ -185,6 +185,18 @@ func Test_organizeButtonsPattern(t *testing.T) {
 			},
 			false,
 		},
+		{
+			"3 btn, 2,2 pattern",
+			args{
+				btns:    []tb.Btn{btn, btn, btn},
+				pattern: []uint{2, 2},
+			},
+			[]tb.Row{
+				[]tb.Btn{btn, btn},
+				[]tb.Btn{btn},
+			},
+			false,
+		},
 		{
 			"4 btn 3 button pattern",
 			args{
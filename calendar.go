@@ -0,0 +1,355 @@
+package tbcomctl
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// Calendar is the date picker, that shows the month grid of inline buttons with
+// the month navigation.  The days outside of the min and max date bounds are
+// disabled.  The value of the Calendar is the date in the DateLayout (ISO 8601)
+// format, the Callback receives it as the Context.Data.
+type Calendar struct {
+	commonCtl
+
+	removeButtons bool
+	backBtn       bool
+
+	tvc        TextCallbacker
+	backBtnTxt Texter
+
+	min, max  time.Time    // date bounds, zero - no bound.
+	weekStart time.Weekday // first day of the week.
+}
+
+var _ Controller = &Calendar{}
+
+// Labels of the buttons that do nothing.
+const (
+	labelBlank    = " " // empty cell.
+	labelDisabled = "·" // day outside of the bounds.
+)
+
+// weekdayNames are the names of the weekdays in the calendar header, they are
+// translated with the package Printer.
+var weekdayNames = [...]string{"Su", "Mo", "Tu", "We", "Th", "Fr", "Sa"}
+
+type CalendarOption func(*Calendar)
+
+// CalOptMinDate sets the earliest date that can be picked.
+func CalOptMinDate(t time.Time) CalendarOption {
+	return func(cal *Calendar) {
+		cal.min = dateOf(t)
+	}
+}
+
+// CalOptMaxDate sets the latest date that can be picked.
+func CalOptMaxDate(t time.Time) CalendarOption {
+	return func(cal *Calendar) {
+		cal.max = dateOf(t)
+	}
+}
+
+// CalOptWeekStart sets the first day of the week, the default is Monday.
+func CalOptWeekStart(d time.Weekday) CalendarOption {
+	return func(cal *Calendar) {
+		cal.weekStart = d
+	}
+}
+
+// CalOptBtnBack adds the back button to the calendar.
+func CalOptBtnBack(texter Texter) CalendarOption {
+	return func(cal *Calendar) {
+		cal.backBtn = true
+		cal.backBtnTxt = texter
+	}
+}
+
+// CalOptRemoveButtons set the Remove Buttons option.  If Remove Buttons is
+// set, the calendar will be removed once the user picks the date.
+func CalOptRemoveButtons(b bool) CalendarOption {
+	return func(cal *Calendar) {
+		cal.removeButtons = b
+	}
+}
+
+func CalOptOverwrite(b bool) CalendarOption {
+	return func(cal *Calendar) {
+		cal.commonCtl.setOverwrite(b)
+	}
+}
+
+func CalOptPrivateOnly(b bool) CalendarOption {
+	return func(cal *Calendar) {
+		optPrivateOnly(b)(&cal.commonCtl)
+	}
+}
+
+func CalOptFallbackLang(lang string) CalendarOption {
+	return func(cal *Calendar) {
+		optFallbackLang(lang)(&cal.commonCtl)
+	}
+}
+
+// CalOptRegistry sets the registry that stores the calendar state.
+func CalOptRegistry(reg Registry) CalendarOption {
+	return func(cal *Calendar) {
+		optRegistry(reg)(&cal.commonCtl)
+	}
+}
+
+// CalOptScope sets the scope of the calendar state, see Scope.
+func CalOptScope(s Scope) CalendarOption {
+	return func(cal *Calendar) {
+		optScope(s)(&cal.commonCtl)
+	}
+}
+
+// NewCalendar creates a new calendar.  TextCallbacker.Text produces the text
+// of the message, TextCallbacker.Callback is called when the user picks the
+// date.  It panics, if the minimum date is after the maximum date.
+func NewCalendar(name string, tc TextCallbacker, opts ...CalendarOption) *Calendar {
+	cal := &Calendar{
+		commonCtl: newCommonCtl(name),
+		tvc:       tc,
+		weekStart: time.Monday,
+	}
+	for _, opt := range opts {
+		opt(cal)
+	}
+	if !cal.min.IsZero() && !cal.max.IsZero() && cal.min.After(cal.max) {
+		panic(fmt.Sprintf("tbcomctl: calendar %s: min date %s is after max date %s", name, cal.min.Format(DateLayout), cal.max.Format(DateLayout)))
+	}
+	return cal
+}
+
+// dateOf returns the date of t at midnight UTC.
+func dateOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// monthOf returns the first day of the month of t.
+func monthOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+// Handler is a handler function to use with telebot.Handle.
+func (cal *Calendar) Handler(c tb.Context) error {
+	if cal.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	text, err := cal.tvc.Text(WithController(context.Background(), cal), c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, cal.fallbackLang))
+		return fmt.Errorf("error while generating text for controller: %s: %w", cal.name, err)
+	}
+	markup, err := cal.inlineMarkup(c, cal.initialMonth(c))
+	if err != nil {
+		return fmt.Errorf("error while generating markup for controller: %s: %w", cal.name, err)
+	}
+	outbound, err := cal.sendOrEdit(c, text, cal.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = cal.reg.Register(cal.recipient(c), outbound.ID)
+	cal.logOutgoingMsg(outbound, "calendar")
+	return nil
+}
+
+// initialMonth returns the month of the picked date, if the user returns to
+// the calendar, or the current month within the bounds.
+func (cal *Calendar) initialMonth(c tb.Context) time.Time {
+	if val, ok := cal.Value(cal.recipient(c).Recipient()); ok {
+		if t, err := time.Parse(DateLayout, val); err == nil {
+			return monthOf(t)
+		}
+	}
+	today := dateOf(time.Now())
+	if !cal.min.IsZero() && today.Before(cal.min) {
+		today = cal.min
+	}
+	if !cal.max.IsZero() && today.After(cal.max) {
+		today = cal.max
+	}
+	return monthOf(today)
+}
+
+// enabled returns true if the date is within the bounds.
+func (cal *Calendar) enabled(date time.Time) bool {
+	return (cal.min.IsZero() || !date.Before(cal.min)) && (cal.max.IsZero() || !date.After(cal.max))
+}
+
+// monthEnabled returns true if the month has at least one enabled day.
+func (cal *Calendar) monthEnabled(month time.Time) bool {
+	return (cal.min.IsZero() || !month.AddDate(0, 1, -1).Before(cal.min)) && (cal.max.IsZero() || !month.After(cal.max))
+}
+
+// Callback data payloads of the calendar buttons.
+const (
+	payloadNoop  = "-" // blank cells, headers and disabled days.
+	payloadMonth = "m" // followed by the month in monthFormat.
+	payloadDay   = "d" // followed by the date in dayFormat.
+
+	monthFormat = "200601"
+	dayFormat   = "20060102"
+)
+
+// callbackID returns the ID of the calendar for the callback data.
+func (cal *Calendar) callbackID() string {
+	return callbackID("calendar", cal.name)
+}
+
+// Register registers the calendar callback with the dispatcher of the bot, see
+// Picklist.Register.
+func (cal *Calendar) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(cal.callbackID(), cal, cal.callback)
+}
+
+// version returns the version of the date bounds for the callback data.
+func (cal *Calendar) version() string {
+	return codec.Version(cal.min.Format(DateLayout), cal.max.Format(DateLayout))
+}
+
+// inlineMarkup generates the month grid of the month.
+func (cal *Calendar) inlineMarkup(c tb.Context, month time.Time) (*tb.ReplyMarkup, error) {
+	pr := PrinterContext(c, cal.fallbackLang)
+	var labels, payloads []string
+	add := func(label, payload string) {
+		labels, payloads = append(labels, label), append(payloads, payload)
+	}
+
+	// header with the navigation
+	if prev := month.AddDate(0, -1, 0); cal.monthEnabled(prev) {
+		add(pagePrev, payloadMonth+prev.Format(monthFormat))
+	} else {
+		add(labelBlank, payloadNoop)
+	}
+	add(pr.Sprintf(month.Month().String())+" "+strconv.Itoa(month.Year()), payloadNoop)
+	if next := month.AddDate(0, 1, 0); cal.monthEnabled(next) {
+		add(pageNext, payloadMonth+next.Format(monthFormat))
+	} else {
+		add(labelBlank, payloadNoop)
+	}
+	for i := 0; i < 7; i++ {
+		add(pr.Sprintf(weekdayNames[(int(cal.weekStart)+i)%7]), payloadNoop)
+	}
+
+	// days
+	picked, _ := cal.Value(cal.recipient(c).Recipient())
+	for i := (int(month.Weekday()) - int(cal.weekStart) + 7) % 7; i > 0; i-- {
+		add(labelBlank, payloadNoop)
+	}
+	for day := month; day.Month() == month.Month(); day = day.AddDate(0, 0, 1) {
+		switch {
+		case !cal.enabled(day):
+			add(labelDisabled, payloadNoop)
+		case day.Format(DateLayout) == picked:
+			add("["+strconv.Itoa(day.Day())+"]", payloadDay+day.Format(dayFormat))
+		default:
+			add(strconv.Itoa(day.Day()), payloadDay+day.Format(dayFormat))
+		}
+	}
+	for (len(labels)-3)%7 != 0 {
+		add(labelBlank, payloadNoop)
+	}
+	if cal.backBtn {
+		txt, err := cal.backBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("backTextFn returned an error: %s", err)
+		}
+		add(txt, payloadBack)
+	}
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, cal.callbackID(), cal.version(), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	rows := []tb.Row{btns[:3]}
+	for i := 3; i+7 <= len(btns); i += 7 {
+		rows = append(rows, btns[i:i+7])
+	}
+	if cal.backBtn {
+		rows = append(rows, tb.Row{btns[len(btns)-1]})
+	}
+	markup.Inline(rows...)
+	cal.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (cal *Calendar) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Calendar.Callback")
+	defer task.End()
+
+	cal.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, cal.callbackID(), cal.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if d.Version != cal.version() {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, cal.fallbackLang)
+	}
+	switch {
+	case d.Payload == payloadNoop:
+		return c.Respond(&tb.CallbackResponse{})
+	case d.Payload == payloadBack:
+		trace.Log(ctx, "callback", "back is pressed (option)")
+		return cal.handleBackButton(ctx, c)
+	case strings.HasPrefix(d.Payload, payloadMonth):
+		month, err := time.Parse(monthFormat, strings.TrimPrefix(d.Payload, payloadMonth))
+		if err != nil || !cal.monthEnabled(month) {
+			return respondStale(c, cal.fallbackLang)
+		}
+		return cal.showMonth(ctx, c, month)
+	}
+
+	date, err := time.Parse(dayFormat, strings.TrimPrefix(d.Payload, payloadDay))
+	if err != nil || !strings.HasPrefix(d.Payload, payloadDay) || !cal.enabled(date) {
+		trace.Log(ctx, "callback", "invalid date")
+		return respondStale(c, cal.fallbackLang)
+	}
+	return cal.pickValue(ctx, c, cal, cal.tvc, date.Format(DateLayout), func(c tb.Context) error {
+		return cal.editMsg(ctx, c, monthOf(date))
+	})
+}
+
+// editMsg removes the calendar, if the Remove Buttons option is set, or marks
+// the picked date.
+func (cal *Calendar) editMsg(ctx context.Context, c tb.Context, month time.Time) error {
+	if cal.removeButtons {
+		text, err := cal.tvc.Text(WithController(ctx, cal), c)
+		if err != nil {
+			return err
+		}
+		return c.Edit(text, cal.sendOpts)
+	}
+	markup, err := cal.inlineMarkup(c, month)
+	if err != nil {
+		return err
+	}
+	_, err = c.Bot().EditReplyMarkup(c.Message(), markup)
+	return err
+}
+
+// showMonth shows the month grid in place of the current one.
+func (cal *Calendar) showMonth(ctx context.Context, c tb.Context, month time.Time) error {
+	markup, err := cal.inlineMarkup(c, month)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+		trace.Log(ctx, "EditReplyMarkup", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{})
+}
@@ -0,0 +1,99 @@
+package tbcomctl
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestCalendar(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "ru"}
+
+	var got []string
+	cal := NewCalendar("date", NewStaticTVC("Date?", nil, func(_ context.Context, c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	}),
+		CalOptMinDate(time.Date(2024, time.March, 5, 12, 0, 0, 0, time.Local)),
+		CalOptMaxDate(time.Date(2024, time.April, 10, 0, 0, 0, 0, time.UTC)),
+		CalOptBtnBack(NewTexter("Back")),
+	)
+	c := newTestCallback(b, user, "")
+
+	month := cal.initialMonth(c)
+	assert.Equal(t, time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC), month, "current month is clamped to the bounds")
+
+	m, err := cal.inlineMarkup(c, monthOf(time.Date(2024, time.March, 20, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := markupLabels(m)
+	if assert.Len(t, rows, 8) {
+		assert.Equal(t, []string{labelBlank, "Март 2024", pageNext}, rows[0], "no navigation before the min date")
+		assert.Equal(t, []string{"Пн", "Вт", "Ср", "Чт", "Пт", "Сб", "Вс"}, rows[1])
+		assert.Equal(t, []string{labelBlank, labelBlank, labelBlank, labelBlank, labelDisabled, labelDisabled, labelDisabled}, rows[2])
+		assert.Equal(t, []string{labelDisabled, "5", "6", "7", "8", "9", "10"}, rows[3])
+		assert.Equal(t, []string{"Back"}, rows[7])
+	}
+
+	// disabled days do nothing.
+	press := pressButton(t, b, user, m, labelDisabled)
+	assert.NoError(t, cal.callback(press))
+	assert.Empty(t, got)
+	assert.Empty(t, press.lastResponse().Text)
+
+	assert.NoError(t, cal.callback(pressButton(t, b, user, m, pageNext)))
+	edit := log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageReplyMarkup", edit.Method)
+	assert.Contains(t, edit.Params["reply_markup"], "Апрель 2024")
+
+	assert.NoError(t, cal.callback(pressButton(t, b, user, m, "15")))
+	assert.Equal(t, []string{"2024-03-15"}, got)
+	val, _ := cal.Value(user.Recipient())
+	assert.Equal(t, "2024-03-15", val)
+	assert.Equal(t, time.Date(2024, time.March, 1, 0, 0, 0, 0, time.UTC), cal.initialMonth(c), "calendar returns to the picked date")
+
+	m, err = cal.inlineMarkup(c, monthOf(time.Date(2024, time.April, 1, 0, 0, 0, 0, time.UTC)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows = markupLabels(m)
+	assert.Equal(t, []string{pagePrev, "Апрель 2024", labelBlank}, rows[0], "no navigation after the max date")
+	assert.Equal(t, []string{"8", "9", "10", labelDisabled, labelDisabled, labelDisabled, labelDisabled}, rows[3])
+
+	// the bounds have changed.
+	old := pressButton(t, b, user, m, "9")
+	CalOptMaxDate(time.Date(2024, time.April, 8, 0, 0, 0, 0, time.UTC))(cal)
+	assert.NoError(t, cal.callback(old))
+	assert.Equal(t, []string{"2024-03-15"}, got)
+	assert.Equal(t, "⌛ Эта кнопка устарела, попробуйте еще раз.", old.lastResponse().Text)
+}
+
+func TestCalendar_weekStart(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	cal := NewCalendar("date", NewStaticTVC("Date?", nil, nil), CalOptWeekStart(time.Sunday))
+
+	m, err := cal.inlineMarkup(newTestCallback(b, user, ""), time.Date(2024, time.September, 1, 0, 0, 0, 0, time.UTC))
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := markupLabels(m)
+	assert.Equal(t, "September 2024", rows[0][1])
+	assert.Equal(t, "Su Mo Tu We Th Fr Sa", strings.Join(rows[1], " "))
+	assert.Equal(t, []string{"1", "2", "3", "4", "5", "6", "7"}, rows[2])
+	assert.Len(t, rows, 7)
+	assert.Equal(t, []string{"29", "30", labelBlank, labelBlank, labelBlank, labelBlank, labelBlank}, rows[6])
+}
+
+func TestNewCalendar_bounds(t *testing.T) {
+	day := time.Date(2023, time.March, 10, 15, 0, 0, 0, time.UTC)
+	assert.Panics(t, func() { NewCalendar("inverted", nil, CalOptMinDate(day), CalOptMaxDate(day.AddDate(0, 0, -1))) })
+	assert.NotPanics(t, func() { NewCalendar("single", nil, CalOptMinDate(day), CalOptMaxDate(day.Add(-time.Hour))) }, "min and max are dates")
+	assert.NotPanics(t, func() { NewCalendar("open", nil, CalOptMinDate(day)) })
+}
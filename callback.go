@@ -0,0 +1,85 @@
+package tbcomctl
+
+import (
+	"errors"
+	"fmt"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// callbackCodec encodes the callback data of the inline buttons of all
+// controls.
+var callbackCodec = codec.New(nil)
+
+// SetCallbackSecret sets the secret that is used to sign the callback data of
+// the inline buttons, so that it can't be forged by a crafted client.  It
+// should be called before the bot is started.  Buttons sent before the secret
+// is changed are rejected.
+func SetCallbackSecret(secret []byte) {
+	callbackCodec = codec.New(secret)
+}
+
+// callbackID returns the ID of the control of the given kind, that is used as
+// the telebot unique of its buttons.
+func callbackID(kind, name string) string {
+	return codec.ID(kind + ":" + name)
+}
+
+// encodedButtons creates the buttons labeled with labels, each button has the
+// corresponding payload.  All buttons have the same unique, so the handler
+// should be registered once for any of them.
+func encodedButtons(m *tb.ReplyMarkup, id, version string, labels, payloads []string) ([]tb.Btn, error) {
+	btns := make([]tb.Btn, len(labels))
+	for i, label := range labels {
+		data, err := callbackCodec.Encode(codec.Data{ID: id, Version: version, Payload: payloads[i]})
+		if err != nil {
+			return nil, fmt.Errorf("button %q: %w", label, err)
+		}
+		btns[i] = m.Data(label, id, data)
+	}
+	return btns, nil
+}
+
+// decodeCallback decodes the callback data of the control id.  If the data
+// can't be decoded, the user is alerted, and ok is false.
+func decodeCallback(c tb.Context, id string, fallbackLang string) (d codec.Data, ok bool) {
+	d, err := callbackCodec.Decode(id, c.Data())
+	if err != nil {
+		lg.Printf("%s: rejected callback data %q: %s", Userinfo(c.Sender()), c.Data(), err)
+		msg := MsgBtnStale
+		if errors.Is(err, codec.ErrSignature) {
+			msg = MsgBtnInvalid
+		}
+		respondAlert(c, PrinterContext(c, fallbackLang).Sprintf(msg))
+		return d, false
+	}
+	return d, true
+}
+
+// respondStale alerts the user that the button is outdated.
+func respondStale(c tb.Context, fallbackLang string) error {
+	return respondAlert(c, PrinterContext(c, fallbackLang).Sprintf(MsgBtnStale))
+}
+
+func respondAlert(c tb.Context, msg string) error {
+	return c.Respond(&tb.CallbackResponse{Text: msg, ShowAlert: true})
+}
+
+// callbackContext is the context with the decoded callback data.
+type callbackContext struct {
+	tb.Context
+	cb *tb.Callback
+}
+
+// withCallbackData returns the context that returns data as the callback
+// data, so that the user callbacks receive the decoded value.
+func withCallbackData(c tb.Context, data string) tb.Context {
+	cb := *c.Callback()
+	cb.Data = data
+	return &callbackContext{Context: c, cb: &cb}
+}
+
+func (c *callbackContext) Callback() *tb.Callback { return c.cb }
+func (c *callbackContext) Data() string           { return c.cb.Data }
@@ -0,0 +1,138 @@
+package tbcomctl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+// markupLabels returns the labels of the inline keyboard.
+func markupLabels(m *tb.ReplyMarkup) [][]string {
+	var rows [][]string
+	for _, row := range m.InlineKeyboard {
+		var r []string
+		for _, btn := range row {
+			r = append(r, btn.Text)
+		}
+		rows = append(rows, r)
+	}
+	return rows
+}
+
+// pressButton returns the callback context of the button with the label.
+func pressButton(t *testing.T, b *tb.Bot, u *tb.User, m *tb.ReplyMarkup, label string) *testContext {
+	t.Helper()
+	for _, row := range m.InlineKeyboard {
+		for _, btn := range row {
+			if btn.Text == label {
+				return newTestCallback(b, u, btn.Data)
+			}
+		}
+	}
+	t.Fatalf("button %q not found in %v", label, markupLabels(m))
+	return nil
+}
+
+func TestPostButtons_callback(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	var got []string
+	pb := NewPostButtons(func(c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	}, PBOptName("poll"))
+	m, err := pb.Markup(newTestCallback(b, user, ""), []string{"yes", "no"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, pb.callback(pressButton(t, b, user, m, "no")))
+	assert.Equal(t, []string{"no"}, got, "label is passed to the callback")
+
+	// data of the old version of the library.
+	c := newTestCallback(b, user, "no")
+	assert.NoError(t, pb.callback(c))
+	assert.Equal(t, []string{"no"}, got)
+	assert.Equal(t, MsgBtnStale, c.lastResponse().Text)
+
+	_, err = pb.Markup(newTestCallback(b, user, ""), []string{"a very long label that does not fit into the callback data"})
+	assert.Error(t, err)
+}
+
+func TestSetCallbackSecret(t *testing.T) {
+	defer SetCallbackSecret(nil)
+
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	var got []string
+	pb := NewPostButtons(func(c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	})
+
+	SetCallbackSecret([]byte("secret"))
+	m, err := pb.Markup(newTestCallback(b, user, ""), []string{"up", "down"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	down := pressButton(t, b, user, m, "down")
+	assert.NoError(t, pb.callback(down))
+	assert.Equal(t, []string{"down"}, got)
+
+	// the crafted client replaces the payload.
+	c := newTestCallback(b, user, down.Data()[:len(down.Data())-len("down")]+"up")
+	assert.NoError(t, pb.callback(c))
+	assert.Equal(t, []string{"down"}, got, "tampered data is rejected")
+	assert.Equal(t, MsgBtnInvalid, c.lastResponse().Text)
+
+	// the secret has changed.
+	SetCallbackSecret([]byte("other"))
+	c = newTestCallback(b, user, down.Data())
+	assert.NoError(t, pb.callback(c))
+	assert.Equal(t, MsgBtnInvalid, c.lastResponse().Text)
+}
+
+func TestRating_callback(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	var votes []int
+	rb := NewRating(func(_ tb.Editable, _ *tb.User, idx int) ([2]Button, error) {
+		votes = append(votes, idx)
+		return [2]Button{{Name: "👍", Value: 1}, {Name: "👎"}}, nil
+	})
+	m := rb.Markup(b, [2]Button{{Name: "👍"}, {Name: "👎"}})
+	c := pressButton(t, b, user, m, "👎")
+	assert.NoError(t, rb.callback(c))
+	assert.Equal(t, []int{1}, votes)
+	assert.Equal(t, MsgVoteCounted, c.lastResponse().Text)
+
+	c = newTestCallback(b, user, "0")
+	assert.NoError(t, rb.callback(c))
+	assert.Equal(t, []int{1}, votes, "raw index is rejected")
+	assert.True(t, c.lastResponse().ShowAlert)
+}
+
+func TestRating_name(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	var got []string
+	rateFn := func(name string) RatingFunc {
+		return func(_ tb.Editable, _ *tb.User, idx int) ([2]Button, error) {
+			got = append(got, name)
+			return [2]Button{{Name: "👍"}, {Name: "👎"}}, nil
+		}
+	}
+	posts := NewRating(rateFn("posts"), RBOptName("posts"))
+	photos := NewRating(rateFn("photos"), RBOptName("photos"))
+	btns := [2]Button{{Name: "👍"}, {Name: "👎"}}
+	pm := posts.Markup(b, btns)
+	fm := photos.Markup(b, btns)
+
+	b.ProcessUpdate(pressUpdate(t, user, fm, "👍"))
+	b.ProcessUpdate(pressUpdate(t, user, pm, "👍"))
+	assert.Equal(t, []string{"photos", "posts"}, got)
+
+	assert.Panics(t, func() { NewRating(rateFn("dup")).Markup(b, btns); NewRating(rateFn("dup")).Register(b) }, "same default name")
+	assert.Panics(t, func() { NewRating(rateFn("empty"), RBOptName("")) })
+}
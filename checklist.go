@@ -0,0 +1,340 @@
+package tbcomctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"sync"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// checkMark is prepended to the labels of the selected checklist values.
+const checkMark = "✅ "
+
+// Checklist is the multi-select control.  It shows the inline button for each
+// value, pressing the button toggles the check mark on it.  User confirms the
+// selection with the Done button, only then the Callback is called and the next
+// controller is invoked.
+//
+// The value of the Checklist is the list of selected values, encoded as JSON
+// array, it is updated on every button press.  Selected values can be decoded
+// into the slice with Form.Bind.
+type Checklist struct {
+	commonCtl
+	*buttons
+
+	removeButtons bool
+
+	tvc        TextValueCallbacker
+	doneBtnTxt Texter
+	btnPattern []uint
+
+	min int // minimum number of selected values
+	max int // maximum number of selected values, 0 - no limit
+
+	mu sync.Mutex // guards toggling of values.
+}
+
+var _ Controller = &Checklist{}
+
+type ChecklistOption func(cl *Checklist)
+
+// CLOptMin sets the minimum number of values user must select.
+func CLOptMin(n int) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.min = n
+	}
+}
+
+// CLOptMax sets the maximum number of values user can select.  Zero means no
+// limit.
+func CLOptMax(n int) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.max = n
+	}
+}
+
+// CLOptBtnDone sets the text of the Done button.
+func CLOptBtnDone(texter Texter) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.doneBtnTxt = texter
+	}
+}
+
+// CLOptBtnPattern sets the button pattern for the values, see
+// PickOptBtnPattern.  The Done button is always shown on a separate row.
+func CLOptBtnPattern(pattern []uint) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.btnPattern = pattern
+	}
+}
+
+// CLOptMaxInlineButtons sets the maximum number of buttons in a row, if the
+// button pattern is not set.
+func CLOptMaxInlineButtons(n int) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.buttons.SetMaxButtons(n)
+	}
+}
+
+// CLOptRemoveButtons sets the Remove Buttons option.  If set, the buttons are
+// removed once the user presses Done.
+func CLOptRemoveButtons(b bool) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.removeButtons = b
+	}
+}
+
+func CLOptOverwrite(b bool) ChecklistOption {
+	return func(cl *Checklist) {
+		cl.commonCtl.setOverwrite(b)
+	}
+}
+
+func CLOptPrivateOnly(b bool) ChecklistOption {
+	return func(cl *Checklist) {
+		optPrivateOnly(b)(&cl.commonCtl)
+	}
+}
+
+func CLOptFallbackLang(lang string) ChecklistOption {
+	return func(cl *Checklist) {
+		optFallbackLang(lang)(&cl.commonCtl)
+	}
+}
+
+// CLOptRegistry sets the registry that stores the checklist state.
+func CLOptRegistry(reg Registry) ChecklistOption {
+	return func(cl *Checklist) {
+		optRegistry(reg)(&cl.commonCtl)
+	}
+}
+
+// CLOptScope sets the scope of the checklist state, see Scope.
+func CLOptScope(s Scope) ChecklistOption {
+	return func(cl *Checklist) {
+		optScope(s)(&cl.commonCtl)
+	}
+}
+
+// NewChecklist creates a new checklist.  The Callback of tvc is called when
+// the user presses Done, the selected values can be retrieved with Value and
+// decoded with Form.Bind.  If the Callback returns an error, the user stays on
+// the checklist.
+func NewChecklist(name string, tvc TextValueCallbacker, opts ...ChecklistOption) *Checklist {
+	cl := &Checklist{
+		commonCtl:  newCommonCtl(name),
+		tvc:        tvc,
+		buttons:    &buttons{maxButtons: defNumButtons},
+		doneBtnTxt: NewTexter(MsgDone),
+	}
+	for _, opt := range opts {
+		opt(cl)
+	}
+	return cl
+}
+
+// Handler is a handler function to use with telebot.Handle.
+func (cl *Checklist) Handler(c tb.Context) error {
+	if cl.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	ctrlCtx := WithController(context.Background(), cl)
+
+	values, err := cl.tvc.Values(ctrlCtx, c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, cl.fallbackLang))
+		return fmt.Errorf("error while generating values for controller: %s: %w", cl.name, err)
+	}
+	text, err := cl.tvc.Text(ctrlCtx, c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, cl.fallbackLang))
+		return fmt.Errorf("error while generating text for controller: %s: %w", cl.name, err)
+	}
+	markup, err := cl.inlineMarkup(c, values, cl.selected(cl.recipient(c).Recipient()))
+	if err != nil {
+		return err
+	}
+	outbound, err := cl.sendOrEdit(c, text, cl.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = cl.reg.Register(cl.recipient(c), outbound.ID)
+
+	cl.logOutgoingMsg(outbound, fmt.Sprintf("checklist: %q", strings.Join(values, "*")))
+	return nil
+}
+
+// selected returns the set of the values selected by the recipient.
+func (cl *Checklist) selected(recipient string) map[string]bool {
+	sel := make(map[string]bool)
+	val, ok := cl.Value(recipient)
+	if !ok {
+		return sel
+	}
+	items, err := decodeList(val)
+	if err != nil {
+		lg.Printf("%s: invalid value %q: %s", cl.name, val, err)
+		return sel
+	}
+	for _, item := range items {
+		sel[item] = true
+	}
+	return sel
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (cl *Checklist) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Checklist.Callback")
+	defer task.End()
+
+	cl.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, cl.callbackID(), cl.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if d.Payload == payloadDone {
+		return cl.handleDone(ctx, c)
+	}
+
+	values, err := cl.tvc.Values(WithController(ctx, cl), c)
+	if err != nil {
+		c.Respond(&tb.CallbackResponse{Text: PrinterContext(c, cl.fallbackLang).Sprintf(MsgUnexpected), ShowAlert: true})
+		return err
+	}
+	idx, err := strconv.Atoi(d.Payload)
+	if err != nil || idx < 0 || len(values) <= idx || d.Version != codec.Version(values...) {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, cl.fallbackLang)
+	}
+	return cl.toggle(ctx, c, values, values[idx])
+}
+
+// toggle toggles the value and updates the buttons.
+func (cl *Checklist) toggle(ctx context.Context, c tb.Context, values []string, value string) error {
+	pr := PrinterContext(c, cl.fallbackLang)
+
+	cl.mu.Lock()
+	sel := cl.selected(cl.recipient(c).Recipient())
+	if sel[value] {
+		delete(sel, value)
+	} else {
+		if cl.max > 0 && len(sel) >= cl.max {
+			cl.mu.Unlock()
+			return c.Respond(&tb.CallbackResponse{Text: pr.Sprintf(MsgSelectMax, cl.max), ShowAlert: true})
+		}
+		sel[value] = true
+	}
+	// stored in the order of values, unknown (stale) values are dropped.
+	var items []string
+	for _, v := range values {
+		if sel[v] {
+			items = append(items, v)
+		}
+	}
+	cl.SetValue(cl.recipient(c).Recipient(), encodeList(items))
+	cl.mu.Unlock()
+
+	markup, err := cl.inlineMarkup(c, values, sel)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+		trace.Log(ctx, "EditReplyMarkup", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{})
+}
+
+// handleDone checks the number of selected values, calls the Callback and
+// invokes the next controller.
+func (cl *Checklist) handleDone(ctx context.Context, c tb.Context) error {
+	pr := PrinterContext(c, cl.fallbackLang)
+	if n := len(cl.selected(cl.recipient(c).Recipient())); n < cl.min {
+		return c.Respond(&tb.CallbackResponse{Text: pr.Sprintf(MsgSelectMin, cl.min), ShowAlert: true})
+	}
+	if _, ok := cl.Value(cl.recipient(c).Recipient()); !ok {
+		// nothing was selected, but that's allowed.
+		cl.SetValue(cl.recipient(c).Recipient(), encodeList(nil))
+	}
+
+	if err := cl.tvc.Callback(WithController(ctx, cl), c); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return c.Respond(&tb.CallbackResponse{Text: e.Msg, ShowAlert: e.Alert})
+		}
+		c.Respond(&tb.CallbackResponse{Text: pr.Sprintf(MsgUnexpected), ShowAlert: true})
+		return err
+	}
+
+	if cl.removeButtons {
+		if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+			trace.Log(ctx, "EditReplyMarkup", err.Error())
+		}
+	}
+	if err := c.Respond(&tb.CallbackResponse{Text: MsgOK}); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+	err := cl.nextHandler(c)
+	cl.reg.Unregister(cl.recipient(c), c.Callback().Message.ID)
+	return err
+}
+
+// payloadDone is the callback data payload of the Done button, value buttons
+// have the index of the value as the payload.
+const payloadDone = "d"
+
+// callbackID returns the ID of the checklist for the callback data.
+func (cl *Checklist) callbackID() string {
+	return callbackID("checklist", cl.name)
+}
+
+// Register registers the checklist callback with the dispatcher of the bot,
+// see Picklist.Register.
+func (cl *Checklist) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(cl.callbackID(), cl, cl.callback)
+}
+
+// inlineMarkup generates the inline markup for the values, marking the
+// selected ones, and the Done button.
+func (cl *Checklist) inlineMarkup(c tb.Context, values []string, sel map[string]bool) (*tb.ReplyMarkup, error) {
+	labels := make([]string, 0, len(values)+1)
+	payloads := make([]string, 0, len(values)+1)
+	for i, v := range values {
+		if sel[v] {
+			v = checkMark + v
+		}
+		labels = append(labels, v)
+		payloads = append(payloads, strconv.Itoa(i))
+	}
+	doneTxt, err := cl.doneBtnTxt.Text(context.Background(), c)
+	if err != nil {
+		dlg.Printf("doneTextFn returned an error: %s", err)
+	}
+	labels = append(labels, doneTxt)
+	payloads = append(payloads, payloadDone)
+
+	markup := new(tb.ReplyMarkup)
+	id := cl.callbackID()
+	btns, err := encodedButtons(markup, id, codec.Version(values...), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	cl.Register(bot(c.Bot()))
+	var rows []tb.Row
+	if len(cl.btnPattern) == 0 {
+		rows = OrganizeButtons(btns[:len(values)], cl.maxButtons)
+	} else if len(values) > 0 {
+		if rows, err = organizeButtonsPattern(btns[:len(values)], cl.btnPattern); err != nil {
+			return nil, err
+		}
+	}
+	markup.Inline(append(rows, tb.Row{btns[len(values)]})...)
+	return markup, nil
+}
@@ -0,0 +1,72 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestChecklist_callback(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	r := user.Recipient()
+
+	var done []string
+	cl := NewChecklist("topics", NewStaticTVC("Pick topics", []string{"go", "rust", "zig"}, func(ctx context.Context, c tb.Context) error {
+		ctrl, _ := ControllerFromCtx(ctx)
+		val, _ := ctrl.Value(c.Sender().Recipient())
+		done, _ = decodeList(val)
+		return nil
+	}), CLOptMin(1), CLOptMax(2))
+
+	press := func(label string) *testContext {
+		values, _ := cl.tvc.Values(context.Background(), nil)
+		m, err := cl.inlineMarkup(newTestCallback(b, user, ""), values, cl.selected(r))
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := pressButton(t, b, user, m, label)
+		assert.NoError(t, cl.callback(c))
+		return c
+	}
+	value := func() string {
+		val, _ := cl.Value(r)
+		return val
+	}
+
+	c := press(MsgDone)
+	assert.Equal(t, "Select at least 1 option(s).", c.lastResponse().Text, "min is enforced")
+	assert.Nil(t, done)
+
+	press("go")
+	press("rust")
+	assert.Equal(t, `["go","rust"]`, value())
+	c = press("zig")
+	assert.True(t, c.lastResponse().ShowAlert, "max is enforced")
+	assert.Equal(t, `["go","rust"]`, value())
+	press(checkMark + "go")
+	assert.Equal(t, `["rust"]`, value())
+
+	c = press(MsgDone)
+	assert.Equal(t, MsgOK, c.lastResponse().Text)
+	assert.Equal(t, []string{"rust"}, done)
+}
+
+func TestChecklist_inlineMarkup(t *testing.T) {
+	b := newTestBot(t)
+	c := newTestCallback(b, &tb.User{ID: 42}, "")
+	values := []string{"a", "b", "c"}
+	sel := map[string]bool{"b": true}
+
+	cl := NewChecklist("test", NewStaticTVC("", values, nil), CLOptMaxInlineButtons(2))
+	m, err := cl.inlineMarkup(c, values, sel)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a", checkMark + "b"}, {"c"}, {MsgDone}}, markupLabels(m))
+
+	cl = NewChecklist("pattern", NewStaticTVC("", values, nil), CLOptBtnPattern([]uint{1, 2}), CLOptBtnDone(NewTexter("OK")))
+	m, err = cl.inlineMarkup(c, values, sel)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"a"}, {checkMark + "b", "c"}, {"OK"}}, markupLabels(m))
+}
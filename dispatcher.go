@@ -0,0 +1,111 @@
+package tbcomctl
+
+import (
+	"fmt"
+	"regexp"
+	"sync"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Dispatcher routes the presses of the inline buttons to the controls that own
+// them.  It is the single tb.OnCallback handler of the bot, the control is
+// found by the control ID encoded in the callback data, so the telebot handler
+// map does not grow, no matter how many buttons are rendered.
+//
+// Controls and forms add themselves to the dispatcher of the bot with Register.
+// The control ID is derived from the type and the name of the control, so the
+// controls of the same type must have unique names within the bot.
+// The bot should not have other tb.OnCallback handlers, use SetFallback to
+// handle the callbacks of the buttons created outside of this package.
+type Dispatcher struct {
+	mu       sync.RWMutex
+	handlers map[string]handler // control ID->callback
+	fallback tb.HandlerFunc
+}
+
+// handler is the callback of the control.
+type handler struct {
+	owner interface{} // control, that registered the callback.
+	fn    tb.HandlerFunc
+}
+
+// registerer is the interface for the controls that handle the callbacks of
+// the inline buttons.
+type registerer interface {
+	Register(b *tb.Bot)
+}
+
+var (
+	dispMu      sync.Mutex
+	dispatchers = make(map[*tb.Bot]*Dispatcher)
+)
+
+// DispatcherFor returns the dispatcher of the bot.  On the first call for the
+// bot, the dispatcher is created and registered as the tb.OnCallback handler.
+func DispatcherFor(b *tb.Bot) *Dispatcher {
+	dispMu.Lock()
+	defer dispMu.Unlock()
+	if d, ok := dispatchers[b]; ok {
+		return d
+	}
+	d := &Dispatcher{handlers: make(map[string]handler)}
+	b.Handle(tb.OnCallback, d.Handler)
+	dispatchers[b] = d
+	return d
+}
+
+// SetFallback sets the handler for the callbacks, that are not owned by any
+// registered control.
+func (d *Dispatcher) SetFallback(h tb.HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.fallback = h
+}
+
+// handle sets the callback of the control id, owned by the owner.  It replaces
+// the previous callback of the same owner, so it is safe to call it on every
+// render.  It panics, if the id is owned by another control, i.e. two controls
+// of the same type have the same name, as the buttons of one of them would be
+// routed to the other.
+func (d *Dispatcher) handle(id string, owner interface{}, fn tb.HandlerFunc) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if h, ok := d.handlers[id]; ok && h.owner != owner {
+		panic(fmt.Sprintf("tbcomctl: callback ID %s of %T is already registered by another %T, controls must have unique names", id, owner, h.owner))
+	}
+	d.handlers[id] = handler{owner: owner, fn: fn}
+}
+
+// cbDataRx is the format of the callback data of the inline buttons, see
+// tb.ReplyMarkup.Data.
+var cbDataRx = regexp.MustCompile(`^\f([-\w]+)(\|(.+))?$`)
+
+// Handler is the tb.OnCallback handler.  It calls the callback of the control
+// with the callback data stripped of the control ID, the same way telebot does
+// for the registered buttons.
+func (d *Dispatcher) Handler(c tb.Context) error {
+	cb := c.Callback()
+	if cb == nil {
+		return nil
+	}
+	d.mu.RLock()
+	fallback := d.fallback
+	var fn tb.HandlerFunc
+	m := cbDataRx.FindStringSubmatch(cb.Data)
+	if m != nil {
+		fn = d.handlers[m[1]].fn
+	}
+	d.mu.RUnlock()
+
+	if fn == nil {
+		if fallback != nil {
+			return fallback(c)
+		}
+		dlg.Printf("%s: no control for callback data %q", Userinfo(c.Sender()), cb.Data)
+		return c.Respond()
+	}
+	data := *cb
+	data.Unique, data.Data = m[1], m[3]
+	return fn(&callbackContext{Context: c, cb: &data})
+}
@@ -0,0 +1,140 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+// pressUpdate returns the update, that telegram sends when the user presses the
+// button with the label.
+func pressUpdate(t *testing.T, u *tb.User, m *tb.ReplyMarkup, label string) tb.Update {
+	t.Helper()
+	for _, row := range m.InlineKeyboard {
+		for _, btn := range row {
+			if btn.Text == label {
+				return tb.Update{Callback: &tb.Callback{
+					ID:      "1",
+					Sender:  u,
+					Message: &tb.Message{ID: 1, Chat: &tb.Chat{ID: u.ID}},
+					Data:    "\f" + btn.Unique + "|" + btn.Data,
+				}}
+			}
+		}
+	}
+	t.Fatalf("button %q not found in %v", label, markupLabels(m))
+	return tb.Update{}
+}
+
+func TestDispatcher(t *testing.T) {
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	t.Run("form register", func(t *testing.T) {
+		var got string
+		p := NewPicklist("colour", NewStaticTVC("Pick", []string{"red", "green"}, func(_ context.Context, c tb.Context) error {
+			got = c.Data()
+			return nil
+		}))
+		fm := NewForm(p, NewMessage("done", NewTexter("Thanks")))
+
+		old := newTestBot(t)
+		choices, pg, err := p.choices(context.Background(), newTestCallback(old, user, ""), 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := p.inlineMarkup(newTestCallback(old, user, ""), choices, pg)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// the bot was restarted, the buttons of the old message are routed
+		// after the form is registered.
+		b := newTestBot(t)
+		b.ProcessUpdate(pressUpdate(t, user, m, "green"))
+		assert.Equal(t, "", got)
+		assert.Same(t, fm, fm.Register(b))
+		b.ProcessUpdate(pressUpdate(t, user, m, "green"))
+		assert.Equal(t, "green", got)
+	})
+	t.Run("fallback", func(t *testing.T) {
+		b := newTestBot(t)
+		d := DispatcherFor(b)
+		assert.Same(t, d, DispatcherFor(b))
+
+		var got []string
+		d.SetFallback(func(c tb.Context) error {
+			got = append(got, c.Data())
+			return nil
+		})
+		b.ProcessUpdate(tb.Update{Callback: &tb.Callback{ID: "1", Sender: user, Data: "\fother|data"}})
+		b.ProcessUpdate(tb.Update{Callback: &tb.Callback{ID: "2", Sender: user, Data: "plain"}})
+		assert.Equal(t, []string{"\fother|data", "plain"}, got)
+	})
+	t.Run("duplicate name", func(t *testing.T) {
+		b := newTestBot(t)
+		p := NewPicklist("confirm", NewStaticTVC("Sure?", []string{MsgYes, MsgNo}, nil))
+		p.Register(b)
+		p.Register(b)
+		assert.Panics(t, func() {
+			NewPicklist("confirm", NewStaticTVC("Really?", []string{MsgYes, MsgNo}, nil)).Register(b)
+		})
+		assert.NotPanics(t, func() {
+			NewStepper("confirm", nil, 1, 2).Register(b)
+		}, "controls of other types have other IDs")
+	})
+	t.Run("button markup", func(t *testing.T) {
+		b := newTestBot(t)
+		var got []string
+		cb := func(c tb.Context) error {
+			got = append(got, c.Data())
+			return nil
+		}
+		values := []string{"a", "b"}
+		m1 := ButtonMarkupNamed(newTestCallback(b, user, ""), "ab", values, 2, cb)
+		m2 := ButtonMarkupNamed(newTestCallback(b, user, ""), "ab", values, 1, cb)
+		values[0] = "changed"
+		assert.Equal(t, m1.InlineKeyboard[0][0].Unique, m2.InlineKeyboard[0][0].Unique, "same name shares the callback")
+
+		b.ProcessUpdate(pressUpdate(t, user, m1, "a"))
+		b.ProcessUpdate(pressUpdate(t, user, m2, "b"))
+		assert.Equal(t, []string{"a", "b"}, got)
+
+		// the markup with the other name has its own callback.
+		var other []string
+		m3 := ButtonMarkupNamed(newTestCallback(b, user, ""), "yn", []string{"yes", "no"}, 2, func(c tb.Context) error {
+			other = append(other, c.Data())
+			return nil
+		})
+		b.ProcessUpdate(pressUpdate(t, user, m3, "yes"))
+		b.ProcessUpdate(pressUpdate(t, user, m1, "b"))
+		assert.Equal(t, []string{"yes"}, other)
+		assert.Equal(t, []string{"a", "b", "b"}, got)
+
+		// new values replace the callback, the buttons of the old values are
+		// outdated.
+		m4 := ButtonMarkupNamed(newTestCallback(b, user, ""), "ab", []string{"c"}, 2, cb)
+		b.ProcessUpdate(pressUpdate(t, user, m1, "a"))
+		b.ProcessUpdate(pressUpdate(t, user, m4, "c"))
+		assert.Equal(t, []string{"a", "b", "b", "c"}, got)
+	})
+	t.Run("button markup values", func(t *testing.T) {
+		b := newTestBot(t)
+		var got []string
+		cb := func(c tb.Context) error {
+			got = append(got, c.Data())
+			return nil
+		}
+		m1 := ButtonMarkup(newTestCallback(b, user, ""), []string{"a", "b"}, 2, cb)
+		m2, err := ButtonPatternMarkup(newTestCallback(b, user, ""), []string{"c"}, []uint{1}, cb)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.NotEqual(t, m1.InlineKeyboard[0][0].Unique, m2.InlineKeyboard[0][0].Unique, "other values have other callback")
+
+		b.ProcessUpdate(pressUpdate(t, user, m1, "a"))
+		b.ProcessUpdate(pressUpdate(t, user, m2, "c"))
+		assert.Equal(t, []string{"a", "c"}, got)
+	})
+}
@@ -68,7 +68,8 @@ func main() {
 	m := tbcomctl.NewMessageText("msg", "all ok")
 	form := tbcomctl.NewForm(p1, p2, p3, m).
 		SetOverwrite(true).
-		SetRemoveButtons(true)
+		SetRemoveButtons(true).
+		Register(b)
 	b.Handle("/picklist", form.Handler)
 
 	log.Println("ready, send /picklist")
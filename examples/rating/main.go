@@ -41,6 +41,7 @@ func main() {
 		},
 		tbcomctl.RBOptShowVoteCounter(true),
 	)
+	rb.Register(b)
 
 	iChat, err := strconv.ParseInt(chat, 10, 64)
 	if err != nil {
@@ -1,6 +1,12 @@
 package tbcomctl
 
 import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+
 	tb "gopkg.in/telebot.v3"
 )
 
@@ -13,8 +19,29 @@ import (
 type Form struct {
 	ctrls []Controller
 	cm    map[string]Controller
+
+	transitions map[string]TransitionFunc // controller name->transition
+
+	onComplete FormFunc
+	onCancel   FormFunc
+
+	scope Scope // scope of the form state, if nil - the sender.
+
+	mu  sync.Mutex // guards the form state updates.
+	reg Registry   // stores the form state of the recipients, see formState.
 }
 
+// formState is the state of the form run of the recipient.
+type formState struct {
+	Done bool     `json:"done,omitempty"` // form was completed or cancelled.
+	Path []string `json:"path,omitempty"` // controller names in the order visited.
+	Ret  string   `json:"ret,omitempty"`  // controller to return to after the edit, see edit.
+}
+
+// FormFunc is the function that is called on form events, data is the form
+// data for the recipient.
+type FormFunc func(ctx context.Context, c tb.Context, data map[string]string) error
+
 // NewForm creates a new Form from a set of Controllers. The Controllers will be
 // called in the same order they will appear in the argument list. Controllers
 // must all have a unique name (within a form), otherwise NewForm will panic.
@@ -23,7 +50,9 @@ func NewForm(ctrls ...Controller) *Form {
 		panic("creating form with no controllers")
 	}
 	fm := &Form{
-		ctrls: ctrls,
+		ctrls:       ctrls,
+		transitions: make(map[string]TransitionFunc),
+		reg:         NewMemRegistry(),
 	}
 	// name->controller map
 	fm.cm = make(map[string]Controller, len(fm.ctrls))
@@ -48,6 +77,12 @@ func NewForm(ctrls ...Controller) *Form {
 	return fm
 }
 
+// NewFormRegistry creates a new form, that stores the state of all controllers
+// in the registry reg, see SetRegistry.
+func NewFormRegistry(reg Registry, ctrls ...Controller) *Form {
+	return NewForm(ctrls...).SetRegistry(reg)
+}
+
 // SetOverwrite sets the overwrite flag on all controllers within the form.
 func (fm *Form) SetOverwrite(b bool) *Form {
 	for _, c := range fm.ctrls {
@@ -62,19 +97,163 @@ func (fm *Form) SetOverwrite(b bool) *Form {
 // form.
 func (fm *Form) SetRemoveButtons(b bool) *Form {
 	for _, c := range fm.ctrls {
-		if p, ok := c.(*Picklist); ok {
+		switch p := c.(type) {
+		case *Picklist:
+			p.removeButtons = b
+		case *Checklist:
+			p.removeButtons = b
+		case *Review:
+			p.removeButtons = b
+		case *Calendar:
+			p.removeButtons = b
+		case *TimePicker:
 			p.removeButtons = b
+		case *Stepper:
+			p.removeButtons = b
+		case *Settings:
+			p.removeButtons = b
+		}
+	}
+	return fm
+}
+
+// SetScope sets the scope on the form and all controllers within the form, see
+// Scope.  The scope decides, how the form data is keyed, i.e. with ScopeChatUser
+// the same user can fill in the form in several chats independently.
+func (fm *Form) SetScope(s Scope) *Form {
+	fm.scope = s
+	for _, c := range fm.ctrls {
+		if sc, ok := c.(scoper); ok {
+			sc.setScope(s)
+		}
+	}
+	return fm
+}
+
+// Recipient returns the recipient, that is the key of the form data for the
+// context, according to the form scope.  It should be used to get the form
+// values with Value.
+func (fm *Form) Recipient(c tb.Context) tb.Recipient {
+	if fm.scope == nil {
+		return c.Sender()
+	}
+	return fm.scope(c)
+}
+
+// SetRegistry sets the registry on all controllers within the form.  The
+// registry is shared between the controllers, the keys are prefixed with the
+// controller name.  The form state, i.e. the path of the user through the form,
+// is stored in the same registry.
+func (fm *Form) SetRegistry(reg Registry) *Form {
+	if reg != nil {
+		fm.reg = formNamespaced(fm.ctrls[0].Name(), reg)
+	}
+	for _, c := range fm.ctrls {
+		if r, ok := c.(registrar); ok {
+			r.setRegistry(reg)
+		}
+	}
+	return fm
+}
+
+// Register registers the callbacks of all controllers of the form, that have
+// the inline buttons, with the dispatcher of the bot.  It should be called
+// before the bot is started, so that the buttons of the messages sent before
+// the restart are handled.
+func (fm *Form) Register(b *tb.Bot) *Form {
+	for _, c := range fm.ctrls {
+		if r, ok := c.(registerer); ok {
+			r.Register(b)
 		}
 	}
 	return fm
 }
 
+// OnComplete sets the function that is called once the last controller of the
+// form has accepted the user input.  It is called once per form run.
+func (fm *Form) OnComplete(fn FormFunc) *Form {
+	fm.onComplete = fn
+	return fm
+}
+
+// OnCancel sets the function that is called when the user cancels the form.
+func (fm *Form) OnCancel(fn FormFunc) *Form {
+	fm.onCancel = fn
+	return fm
+}
+
 // Handler is the form handler.  It calls the handler of the first controller in
 // the chain.
 func (fm *Form) Handler(c tb.Context) error {
+	fm.setDone(fm.Recipient(c).Recipient(), false)
+	fm.resetPath(fm.Recipient(c).Recipient())
+	setFromCtrl(c, nil)
 	return fm.ctrls[0].Handler(c)
 }
 
+// state returns the form state of the recipient and its encoded value.
+func (fm *Form) state(recipient string) (formState, string) {
+	var st formState
+	data, ok := fm.reg.Value(recipient)
+	if ok {
+		if err := json.Unmarshal([]byte(data), &st); err != nil {
+			dlg.Printf("form: invalid state of %s: %s", recipient, err)
+		}
+	}
+	return st, data
+}
+
+// update updates the form state of the recipient with fn.  The state is
+// stored only if it was changed.
+func (fm *Form) update(recipient string, fn func(st *formState)) {
+	fm.mu.Lock()
+	defer fm.mu.Unlock()
+	st, old := fm.state(recipient)
+	fn(&st)
+	data, err := json.Marshal(st)
+	if err != nil {
+		panic(err) // formState is always marshalable.
+	}
+	if string(data) != old {
+		fm.reg.SetValue(recipient, string(data))
+	}
+}
+
+// setDone sets the done flag for the recipient and returns the previous value.
+func (fm *Form) setDone(recipient string, b bool) bool {
+	var prev bool
+	fm.update(recipient, func(st *formState) {
+		prev, st.Done = st.Done, b
+	})
+	return prev
+}
+
+// complete calls the completion function, unless the form was already
+// completed or cancelled by the user.
+func (fm *Form) complete(c tb.Context) error {
+	if fm.setDone(fm.Recipient(c).Recipient(), true) || fm.onComplete == nil {
+		return nil
+	}
+	return fm.onComplete(context.Background(), c, fm.DataContext(c))
+}
+
+// Cancel cancels the form for the user: all form Inputs stop waiting for
+// the user input, and the cancel function is called.  It can be used as a
+// handler for the cancel command:
+//
+//	b.Handle("/cancel", form.Cancel)
+func (fm *Form) Cancel(c tb.Context) error {
+	for _, ctrl := range fm.ctrls {
+		if w, ok := ctrl.(waiter); ok {
+			w.stopWait(c)
+		}
+	}
+	if fm.setDone(fm.Recipient(c).Recipient(), true) || fm.onCancel == nil {
+		return nil
+	}
+	return fm.onCancel(context.Background(), c, fm.DataContext(c))
+}
+
 // Controller returns the Form Controller by it's name.
 func (fm *Form) Controller(name string) (Controller, bool) {
 	c, ok := fm.cm[name]
@@ -85,6 +264,10 @@ type onTexter interface {
 	OnTextMw(fn tb.HandlerFunc) tb.HandlerFunc
 }
 
+type onMediaer interface {
+	OnMediaMw(tb.HandlerFunc) tb.HandlerFunc
+}
+
 // OnTextMiddleware returns the middleware for the OnText handler.
 //
 //	var f Form
@@ -101,6 +284,25 @@ func (fm *Form) OnTextMiddleware(onText tb.HandlerFunc) tb.HandlerFunc {
 	return middlewareChain(onText, mwfn...)
 }
 
+// OnMediaMiddleware returns the middleware for the media handlers, i.e.
+// OnPhoto, that passes the message to the media inputs of the form.  It should
+// wrap the handlers of all MediaEndpoints, the form media inputs will not
+// receive the messages of the endpoints that are not wrapped.
+//
+//	var f Form
+//	for _, ep := range MediaEndpoints {
+//		tb.Handle(ep, f.OnMediaMiddleware(/*other handlers*/))
+//	}
+func (fm *Form) OnMediaMiddleware(onMedia tb.HandlerFunc) tb.HandlerFunc {
+	var mwfn []tb.MiddlewareFunc
+	for _, ctrl := range fm.ctrls {
+		if mmw, ok := ctrl.(onMediaer); ok {
+			mwfn = append(mwfn, mmw.OnMediaMw)
+		}
+	}
+	return middlewareChain(onMedia, mwfn...)
+}
+
 func middlewareChain(final tb.HandlerFunc, mw ...tb.MiddlewareFunc) tb.HandlerFunc {
 	var handler = final
 	for i := len(mw) - 1; i >= 0; i-- {
@@ -109,11 +311,25 @@ func middlewareChain(final tb.HandlerFunc, mw ...tb.MiddlewareFunc) tb.HandlerFu
 	return handler
 }
 
-// Data returns form data for the recipient.
+// Data returns form data for the recipient.  If the controllers of the form
+// have different scopes, use DataContext.
 func (fm *Form) Data(r tb.Recipient) map[string]string {
+	return fm.data(func(Controller) string { return r.Recipient() })
+}
+
+// DataContext returns form data for the context.  The value of each
+// controller is taken for the recipient of the controller scope, so the
+// controllers may have different scopes, see Scope.
+func (fm *Form) DataContext(c tb.Context) map[string]string {
+	return fm.data(contextRecipient(c))
+}
+
+// data returns form data, the value of each controller is taken for the
+// recipient returned by fn.
+func (fm *Form) data(fn recipientFunc) map[string]string {
 	data := make(map[string]string, len(fm.ctrls))
 	for k, v := range fm.cm {
-		val, ok := v.Value(r.Recipient())
+		val, ok := v.Value(fn(v))
 		if !ok {
 			continue
 		}
@@ -122,7 +338,8 @@ func (fm *Form) Data(r tb.Recipient) map[string]string {
 	return data
 }
 
-// Value returns the form control value for recipient by name
+// Value returns the form control value for recipient by name.  The recipient
+// is the key of the form scope, see Recipient.
 func (fm *Form) Value(ctrlName, recipient string) (string, bool) {
 	ctrl, ok := fm.cm[ctrlName]
 	if !ok {
@@ -130,3 +347,222 @@ func (fm *Form) Value(ctrlName, recipient string) (string, bool) {
 	}
 	return ctrl.Value(recipient)
 }
+
+// ValueContext returns the form control value for the context by name.  The
+// value is taken for the recipient of the control scope, see DataContext.
+func (fm *Form) ValueContext(c tb.Context, ctrlName string) (string, bool) {
+	ctrl, ok := fm.cm[ctrlName]
+	if !ok {
+		return "", false
+	}
+	return ctrl.Value(recipientOf(ctrl, c).Recipient())
+}
+
+// Reset clears the values and the state of all form controllers, and the
+// form state for the recipient.
+func (fm *Form) Reset(recipient string) {
+	fm.reg.Clear(recipient)
+	for _, ctrl := range fm.ctrls {
+		if c, ok := ctrl.(clearer); ok {
+			c.clear(recipient)
+		}
+	}
+}
+
+// StartSweeper starts the background sweeper for the form controllers and the
+// form state, see StartSweeper.
+func (fm *Form) StartSweeper(ctx context.Context, b *tb.Bot, interval, ttl time.Duration) {
+	startSweeper(ctx, interval, ttl, func(before time.Time) {
+		sweep(ctx, b, before, fm.ctrls...)
+		fm.expire(before)
+	})
+}
+
+// expire removes the form states that were updated before the given time.
+func (fm *Form) expire(before time.Time) {
+	if e, ok := fm.reg.(Expirer); ok {
+		e.Expire("", before)
+	}
+}
+
+// TransitionFunc returns the name of the form controller that should be shown
+// to the user after the current one.  value is the value of the current
+// controller, data is the form data.  Returning an empty string means the next
+// controller in the form.  The ctx contains the current controller, see
+// ControllerFromCtx.
+type TransitionFunc func(ctx context.Context, c tb.Context, value string, data map[string]string) (string, error)
+
+// OnValue returns the TransitionFunc that chooses the next controller by the
+// value of the current controller.  If the value is not in cases, the dflt is
+// used, which can be empty for the next controller in the form.
+//
+//	fm.SetTransition("has_company", OnValue(map[string]string{"no": "email"}, ""))
+func OnValue(cases map[string]string, dflt string) TransitionFunc {
+	return func(_ context.Context, _ tb.Context, value string, _ map[string]string) (string, error) {
+		if next, ok := cases[value]; ok {
+			return next, nil
+		}
+		return dflt, nil
+	}
+}
+
+// GoTo returns the TransitionFunc that always jumps to the controller name.
+func GoTo(name string) TransitionFunc {
+	return func(context.Context, tb.Context, string, map[string]string) (string, error) {
+		return name, nil
+	}
+}
+
+// SetTransition sets the transition function for the controller with the
+// name from.  The transition function is called, once the controller has
+// accepted the user input, to choose the next controller, which can be any
+// controller of the form, including the previous ones.  SetTransition panics,
+// if the controller does not exist in the form.
+func (fm *Form) SetTransition(from string, fn TransitionFunc) *Form {
+	if _, ok := fm.cm[from]; !ok {
+		panic("controller " + from + " does not exist")
+	}
+	fm.transitions[from] = fn
+	return fm
+}
+
+// index returns the index of the controller with the name in the form or -1.
+func (fm *Form) index(name string) int {
+	for i, ctrl := range fm.ctrls {
+		if ctrl.Name() == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// nextCtrl returns the controller that should follow the controller with the
+// name from.  It returns nil, if there's no next controller.
+func (fm *Form) nextCtrl(c tb.Context, from string) (Controller, error) {
+	if fn, ok := fm.transitions[from]; ok {
+		ctrl := fm.cm[from]
+		val, _ := ctrl.Value(recipientOf(ctrl, c).Recipient())
+		name, err := fn(WithController(context.Background(), ctrl), c, val, fm.DataContext(c))
+		if err != nil {
+			return nil, err
+		}
+		if name != "" {
+			next, ok := fm.cm[name]
+			if !ok {
+				return nil, fmt.Errorf("form: transition from %q to unknown controller %q", from, name)
+			}
+			return next, nil
+		}
+	}
+	if i := fm.index(from); 0 <= i && i < len(fm.ctrls)-1 {
+		return fm.ctrls[i+1], nil
+	}
+	return nil, nil
+}
+
+// forward calls the handler of the controller that follows the controller
+// with the name from, or completes the form, if there's none.
+func (fm *Form) forward(c tb.Context, from string) error {
+	if ret, ok := fm.popReturn(fm.Recipient(c).Recipient(), from); ok {
+		// the path is not changed by the edit, the transitions are not
+		// followed.
+		setFromCtrl(c, fm.cm[from])
+		return ret.Handler(c)
+	}
+	next, err := fm.nextCtrl(c, from)
+	if err != nil {
+		return err
+	}
+	if next == nil {
+		return fm.complete(c)
+	}
+	fm.visit(fm.Recipient(c).Recipient(), from, next.Name())
+	setFromCtrl(c, fm.cm[from])
+	return next.Handler(c)
+}
+
+// back calls the handler of the controller that the user has visited before
+// the controller with the name from.
+func (fm *Form) back(c tb.Context, from string) error {
+	prev := fm.leave(fm.Recipient(c).Recipient(), from)
+	if prev == nil {
+		return nil
+	}
+	setFromCtrl(c, fm.cm[from])
+	return prev.Handler(c)
+}
+
+// edit calls the handler of the controller with the name to, once it accepts
+// the user input, the form returns to the controller with the name from,
+// instead of following the transitions.
+func (fm *Form) edit(c tb.Context, from, to string) error {
+	next, ok := fm.cm[to]
+	if !ok {
+		return fmt.Errorf("form: edit of unknown controller %q", to)
+	}
+	fm.update(fm.Recipient(c).Recipient(), func(st *formState) {
+		st.Ret = from
+	})
+	setFromCtrl(c, fm.cm[from])
+	return next.Handler(c)
+}
+
+// popReturn returns the controller, that the recipient should return to after
+// leaving the edited controller with the name from.
+func (fm *Form) popReturn(recipient string, from string) (Controller, bool) {
+	var name string
+	fm.update(recipient, func(st *formState) {
+		if st.Ret != from {
+			name, st.Ret = st.Ret, ""
+		}
+	})
+	ctrl, ok := fm.cm[name]
+	return ctrl, ok
+}
+
+// resetPath resets the path of the recipient to the first controller.
+func (fm *Form) resetPath(recipient string) {
+	fm.update(recipient, func(st *formState) {
+		st.Path, st.Ret = []string{fm.ctrls[0].Name()}, ""
+	})
+}
+
+// visit records the transition from one controller to another in the path of
+// the recipient.  If the controller was visited before, the path is truncated
+// to it.
+func (fm *Form) visit(recipient string, from, to string) {
+	fm.update(recipient, func(st *formState) {
+		if len(st.Path) == 0 || st.Path[len(st.Path)-1] != from {
+			// path is unknown, i.e. the state has expired.
+			st.Path = []string{from}
+		}
+		for i, name := range st.Path {
+			if name == to {
+				st.Path = st.Path[:i+1]
+				return
+			}
+		}
+		st.Path = append(st.Path, to)
+	})
+}
+
+// leave removes the controller from from the path of the recipient and returns
+// the controller that was visited before it.  If the path is unknown, the
+// previous controller in the form is returned.
+func (fm *Form) leave(recipient string, from string) Controller {
+	var prev string
+	fm.update(recipient, func(st *formState) {
+		if len(st.Path) < 2 || st.Path[len(st.Path)-1] != from {
+			return
+		}
+		st.Path = st.Path[:len(st.Path)-1]
+		prev = st.Path[len(st.Path)-1]
+	})
+	if prev != "" {
+		return fm.cm[prev]
+	}
+	if i := fm.index(from); i > 0 {
+		return fm.ctrls[i-1]
+	}
+	return nil
+}
@@ -0,0 +1,268 @@
+package tbcomctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+// testContext is the telebot context stub for tests, calling methods that are
+// not overridden panics.
+type testContext struct {
+	tb.Context
+	bot    *tb.Bot
+	sender *tb.User
+	msg    *tb.Message
+	cb     *tb.Callback
+	store  map[string]interface{}
+
+	responses []*tb.CallbackResponse
+}
+
+func newTestContext(u *tb.User) *testContext {
+	return &testContext{sender: u, store: make(map[string]interface{})}
+}
+
+// newTestCallback returns the context of the callback with data from the
+// message with ID 1.
+func newTestCallback(b *tb.Bot, u *tb.User, data string) *testContext {
+	c := newTestContext(u)
+	c.bot = b
+	c.msg = &tb.Message{ID: 1, Chat: &tb.Chat{ID: u.ID}}
+	c.cb = &tb.Callback{Sender: u, Message: c.msg, Data: data}
+	return c
+}
+
+func (c *testContext) Bot() tb.API                     { return c.bot }
+func (c *testContext) Sender() *tb.User                { return c.sender }
+func (c *testContext) Message() *tb.Message            { return c.msg }
+func (c *testContext) Callback() *tb.Callback          { return c.cb }
+func (c *testContext) Chat() *tb.Chat                  { return c.msg.Chat }
+func (c *testContext) Data() string                    { return c.cb.Data }
+func (c *testContext) Get(key string) interface{}      { return c.store[key] }
+func (c *testContext) Set(key string, val interface{}) { c.store[key] = val }
+
+func (c *testContext) Edit(what interface{}, opts ...interface{}) error {
+	_, err := c.bot.Edit(c.msg, what, opts...)
+	return err
+}
+
+func (c *testContext) Respond(resp ...*tb.CallbackResponse) error {
+	c.responses = append(c.responses, resp...)
+	return nil
+}
+
+// lastResponse returns the last callback response.
+func (c *testContext) lastResponse() *tb.CallbackResponse {
+	if len(c.responses) == 0 {
+		return nil
+	}
+	return c.responses[len(c.responses)-1]
+}
+
+// newTestBot returns the offline bot, that talks to the fake API server, which
+// responds with the message to any request.
+func newTestBot(t *testing.T) *tb.Bot {
+	b, _ := newRecordingBot(t)
+	return b
+}
+
+// apiCall is the request to the fake API server.
+type apiCall struct {
+	Method string
+	Params map[string]string
+}
+
+// apiLog records the requests to the fake API server.
+type apiLog struct {
+	mu    sync.Mutex
+	calls []apiCall
+}
+
+// methods returns the methods called so far.
+func (l *apiLog) methods() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var mm []string
+	for _, c := range l.calls {
+		mm = append(mm, c.Method)
+	}
+	return mm
+}
+
+// call returns the i-th call.
+func (l *apiLog) call(i int) apiCall {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.calls[i]
+}
+
+// newRecordingBot returns the test bot and the log of the API requests.
+func newRecordingBot(t *testing.T) (*tb.Bot, *apiLog) {
+	var log apiLog
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		params := make(map[string]string)
+		_ = json.NewDecoder(r.Body).Decode(&params)
+		log.mu.Lock()
+		log.calls = append(log.calls, apiCall{Method: path.Base(r.URL.Path), Params: params})
+		log.mu.Unlock()
+
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, `{"ok":true,"result":{"message_id":1,"chat":{"id":42}}}`)
+	}))
+	t.Cleanup(srv.Close)
+	b, err := tb.NewBot(tb.Settings{URL: srv.URL, Offline: true, Synchronous: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b, &log
+}
+
+func TestNewFormRegistry(t *testing.T) {
+	reg := NewMemRegistry()
+	p := NewPicklist("p", NewStaticTVC("pick", []string{"a"}, nil))
+	m := NewMessage("m", NewTexter("done"), tb.Silent)
+	NewFormRegistry(reg, p, m)
+
+	p.SetValue("42", "a")
+	val, ok := reg.Value("p:42")
+	assert.True(t, ok)
+	assert.Equal(t, "a", val)
+	assert.Equal(t, []interface{}{tb.Silent}, m.opts, "send options are passed as is")
+	m.SetValue("42", "sent")
+	_, ok = reg.Value("m:42")
+	assert.True(t, ok)
+}
+
+func TestForm_complete(t *testing.T) {
+	user := &tb.User{ID: 42}
+	c := newTestContext(user)
+
+	var calls int
+	p := NewPicklist("p", NewStaticTVC("pick", []string{"a"}, nil))
+	fm := NewForm(p).OnComplete(func(ctx context.Context, c tb.Context, data map[string]string) error {
+		calls++
+		assert.Equal(t, map[string]string{"p": "a"}, data)
+		return nil
+	})
+	p.SetValue(user.Recipient(), "a")
+
+	assert.NoError(t, p.nextHandler(c))
+	assert.NoError(t, p.nextHandler(c)) // i.e. the button pressed again.
+	assert.Equal(t, 1, calls)
+
+	fm.setDone(user.Recipient(), false) // form restarted
+	assert.NoError(t, p.nextHandler(c))
+	assert.Equal(t, 2, calls)
+}
+
+func TestForm_Cancel(t *testing.T) {
+	user := &tb.User{ID: 42}
+	c := newTestContext(user)
+
+	var completed, cancelled int
+	ip1 := NewInputText("first", "first", nil)
+	ip2 := NewInputText("second", "second", nil)
+	fm := NewForm(ip1, ip2).
+		OnComplete(func(ctx context.Context, c tb.Context, data map[string]string) error {
+			completed++
+			return nil
+		}).
+		OnCancel(func(ctx context.Context, c tb.Context, data map[string]string) error {
+			cancelled++
+			assert.Equal(t, map[string]string{"first": "value"}, data)
+			return nil
+		})
+	ip1.SetValue(user.Recipient(), "value")
+	ip2.reg.Wait(user, 100)
+
+	assert.NoError(t, fm.Cancel(c))
+	assert.False(t, ip2.reg.IsWaiting(user))
+	assert.Equal(t, 1, cancelled)
+
+	// completion must not fire after the form was cancelled.
+	assert.NoError(t, ip2.nextHandler(c))
+	assert.Equal(t, 0, completed)
+}
+
+func TestForm_forward_edit(t *testing.T) {
+	b, _ := newRecordingBot(t)
+	user := &tb.User{ID: 42}
+	c := newTestCallback(b, user, "")
+
+	var calls int
+	colour := NewPicklist("colour", NewStaticTVC("colour?", []string{"red"}, nil))
+	name := NewInputText("name", "name?", nil)
+	done := NewMessage("done", NewTexter("done"))
+	fm := NewForm(colour, name, done).
+		SetTransition("colour", func(context.Context, tb.Context, string, map[string]string) (string, error) {
+			calls++
+			return "", errors.New("transition is called")
+		})
+
+	assert.NoError(t, fm.edit(c, "done", "colour"))
+	assert.NoError(t, fm.forward(c, "colour"), "edited controller returns without the transition")
+	assert.Equal(t, 0, calls)
+	assert.Error(t, fm.forward(c, "colour"))
+	assert.Equal(t, 1, calls)
+}
+
+func TestForm_transitions(t *testing.T) {
+	user := &tb.User{ID: 42}
+	c := newTestContext(user)
+	r := user.Recipient()
+
+	hasCompany := NewPicklist("has_company", NewStaticTVC("company?", []string{"yes", "no"}, nil))
+	company := NewInputText("company", "company name", nil)
+	email := NewInputText("email", "email", nil)
+	fm := NewForm(hasCompany, company, email).
+		SetTransition("has_company", OnValue(map[string]string{"no": "email"}, ""))
+
+	next := func(from string) string {
+		t.Helper()
+		ctrl, err := fm.nextCtrl(c, from)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ctrl == nil {
+			return ""
+		}
+		return ctrl.Name()
+	}
+
+	hasCompany.SetValue(r, "yes")
+	assert.Equal(t, "company", next("has_company"))
+	hasCompany.SetValue(r, "no")
+	assert.Equal(t, "email", next("has_company"))
+	assert.Equal(t, "", next("email"))
+
+	// back follows the path taken by the user.
+	fm.resetPath(r)
+	fm.visit(r, "has_company", "email")
+	assert.Equal(t, hasCompany, fm.leave(r, "email"))
+
+	// jumping back truncates the path.
+	fm.resetPath(r)
+	fm.visit(r, "has_company", "company")
+	fm.visit(r, "company", "email")
+	fm.visit(r, "email", "company")
+	st, _ := fm.state(r)
+	assert.Equal(t, []string{"has_company", "company"}, st.Path)
+
+	// unknown path falls back to the form order.
+	fm.Reset(r)
+	assert.Equal(t, company, fm.leave(r, "email"))
+
+	fm.SetTransition("email", GoTo("nonexistent"))
+	_, err := fm.nextCtrl(c, "email")
+	assert.Error(t, err)
+}
@@ -10,10 +10,10 @@ require (
 	github.com/stretchr/testify v1.8.0
 	golang.org/x/text v0.4.0
 	gopkg.in/telebot.v3 v3.4.1-beta
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
 	github.com/davecgh/go-spew v1.1.1 // indirect
 	github.com/pmezard/go-difflib v1.0.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -13,6 +13,53 @@ const (
 	MsgVoteCounted = "✅ Vote counted."
 	MsgSubCheck    = "？ Check subscription >>"
 	MsgSubNoSub    = "❌ You're not subscribed to one or more of the required channels."
+
+	MsgYes          = "Yes"
+	MsgNo           = "No"
+	MsgSkipHint     = "(send \"-\" to skip)"
+	MsgInvalidValue = "❌ Invalid value, please try again."
+
+	MsgDone      = "Done"
+	MsgSelectMin = "Select at least %d option(s)."
+	MsgSelectMax = "You can select at most %d option(s)."
+
+	MsgBtnStale   = "⌛ This button is outdated, please try again."
+	MsgBtnInvalid = "❌ Invalid button."
+
+	MsgInvalidInt     = "❌ Please enter a whole number."
+	MsgIntRange       = "❌ Please enter a number between %d and %d."
+	MsgInvalidDecimal = "❌ Please enter a number."
+	MsgInvalidEmail   = "❌ Please enter a valid email address."
+	MsgInvalidPhone   = "❌ Please enter the phone number in the international format, i.e. +14155552671."
+	MsgInvalidDate    = "❌ Please enter the date in the format: %s."
+	MsgInvalidChoice  = "❌ Please enter one of: %s."
+
+	MsgSendPhoto    = "📷 Please send a photo."
+	MsgSendDocument = "📎 Please send a file."
+	MsgSendVoice    = "🎤 Please send a voice message."
+	MsgSendLocation = "📍 Please send a location."
+	MsgSendContact  = "👤 Please share a contact."
+	MsgFileTooBig   = "❌ The file is too big, the maximum size is %s."
+	MsgFileType     = "❌ This file type is not accepted, allowed types: %s."
+
+	MsgShareContact  = "📱 Share contact"
+	MsgShareLocation = "📍 Share location"
+	MsgSkip          = "Skip"
+	MsgNotOwnContact = "❌ Please share your own contact with the button below."
+
+	MsgTooManyAttempts = "❌ Too many attempts."
+
+	MsgReviewTitle = "Please check your answers:"
+	MsgConfirm     = "✅ Confirm"
+	MsgEditField   = "✏️ %s"
+
+	MsgHours   = "%d h"
+	MsgMinutes = "%d min"
+
+	MsgOutOfRange = "The value must be between %d and %d."
+
+	MsgBack = "⬅ Back"
+	MsgHome = "🏠 Home"
 )
 
 var translations = map[language.Tag][]i18nmsg{
@@ -23,6 +70,61 @@ var translations = map[language.Tag][]i18nmsg{
 		{MsgVoteCounted, "✅ Голос учтен."},
 		{MsgSubCheck, "？ Проверить подписку >>"},
 		{MsgSubNoSub, "❌ Вы не подписались на один или более необходимых каналов."},
+		{MsgYes, "Да"},
+		{MsgNo, "Нет"},
+		{MsgSkipHint, "(отправьте \"-\", чтобы пропустить)"},
+		{MsgInvalidValue, "❌ Неверное значение, попробуйте еще раз."},
+		{MsgDone, "Готово"},
+		{MsgSelectMin, "Выберите не менее %d вариант(ов)."},
+		{MsgSelectMax, "Можно выбрать не более %d вариант(ов)."},
+		{MsgBtnStale, "⌛ Эта кнопка устарела, попробуйте еще раз."},
+		{MsgBtnInvalid, "❌ Недействительная кнопка."},
+		{MsgInvalidInt, "❌ Введите целое число."},
+		{MsgIntRange, "❌ Введите число от %d до %d."},
+		{MsgInvalidDecimal, "❌ Введите число."},
+		{MsgInvalidEmail, "❌ Введите правильный адрес электронной почты."},
+		{MsgInvalidPhone, "❌ Введите номер телефона в международном формате, например +74951234567."},
+		{MsgInvalidDate, "❌ Введите дату в формате: %s."},
+		{MsgInvalidChoice, "❌ Введите одно из значений: %s."},
+		{MsgSendPhoto, "📷 Отправьте фотографию."},
+		{MsgSendDocument, "📎 Отправьте файл."},
+		{MsgSendVoice, "🎤 Отправьте голосовое сообщение."},
+		{MsgSendLocation, "📍 Отправьте местоположение."},
+		{MsgSendContact, "👤 Поделитесь контактом."},
+		{MsgFileTooBig, "❌ Файл слишком большой, максимальный размер: %s."},
+		{MsgFileType, "❌ Этот тип файла не принимается, допустимые типы: %s."},
+		{MsgShareContact, "📱 Поделиться контактом"},
+		{MsgShareLocation, "📍 Поделиться местоположением"},
+		{MsgSkip, "Пропустить"},
+		{MsgNotOwnContact, "❌ Поделитесь своим контактом с помощью кнопки ниже."},
+		{MsgTooManyAttempts, "❌ Слишком много попыток."},
+		{MsgReviewTitle, "Пожалуйста, проверьте ответы:"},
+		{MsgConfirm, "✅ Подтвердить"},
+		{MsgHours, "%d ч"},
+		{MsgMinutes, "%d мин"},
+		{MsgOutOfRange, "Значение должно быть от %d до %d."},
+		{MsgBack, "⬅ Назад"},
+		{MsgHome, "🏠 В начало"},
+		// calendar
+		{"January", "Январь"},
+		{"February", "Февраль"},
+		{"March", "Март"},
+		{"April", "Апрель"},
+		{"May", "Май"},
+		{"June", "Июнь"},
+		{"July", "Июль"},
+		{"August", "Август"},
+		{"September", "Сентябрь"},
+		{"October", "Октябрь"},
+		{"November", "Ноябрь"},
+		{"December", "Декабрь"},
+		{"Mo", "Пн"},
+		{"Tu", "Вт"},
+		{"We", "Ср"},
+		{"Th", "Чт"},
+		{"Fr", "Пт"},
+		{"Sa", "Сб"},
+		{"Su", "Вс"},
 	},
 }
 
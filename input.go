@@ -2,8 +2,13 @@ package tbcomctl
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net/http"
+	"strings"
+	"sync"
 	"time"
+	"unicode/utf16"
 
 	tb "gopkg.in/telebot.v3"
 )
@@ -22,20 +27,58 @@ type Input struct {
 
 	// valueResolverFn helps the input to get the correct value from the incoming message
 	valueResolverFn ValueResolver
+	resolveFirst    bool // resolve the value before the callback, see IOptResolveFirst.
 
 	noReply bool
+	group   bool // group mode, see IOptGroup.
+
+	media     bool     // input expects the media, see mediaInput.
+	maxSize   int64    // maximum file size, 0 - no limit.
+	mimeTypes []string // accepted MIME types of the file.
+
+	request      string // MediaContact or MediaLocation, if the input shows the request keyboard.
+	requestLabel string // request button label.
+	skipLabel    string // skip button label, if empty, there's no skip button.
+
+	onExpire ExpireFunc // called when the waiting for the user input expires.
+
+	maxAttempts int               // maximum number of attempts, 0 - unlimited.
+	onGiveUp    HandleContextFunc // called when the user runs out of attempts.
+	retryMode   RetryMode
+	retryDelay  time.Duration // delay before the prompt is resent.
+
+	amu      sync.Mutex
+	attempts map[string]int         // number of failed attempts per recipient.
+	resends  map[string]*time.Timer // pending prompt resends per recipient, see RetryResend.
 }
 
+// ValueResolver returns the value of the user input, that is stored in the
+// registry.  It is called after the Callback has accepted the input, or before
+// it, if IOptResolveFirst is set.  If it returns the error created with
+// NewInputError, the user is asked to retry the input.
 type ValueResolver func(*tb.Message) (string, error)
 
 // interface assertions
 var (
 	_ Controller = &Input{}
 	_ onTexter   = &Input{}
+	_ onMediaer  = &Input{}
 )
 
 type InputOption func(*Input)
 
+// RetryMode defines how the user is asked to retry the input.
+type RetryMode int
+
+const (
+	// RetryResend sends the error message and then resends the prompt.
+	RetryResend RetryMode = iota
+	// RetryReply sends the error message in reply to the prompt.
+	RetryReply
+	// RetryEdit edits the prompt, adding the error message to the text.
+	RetryEdit
+)
+
 func IOptNoReply(b bool) InputOption {
 	return func(ip *Input) {
 		ip.noReply = true
@@ -48,12 +91,108 @@ func IOptPrivateOnly(b bool) InputOption {
 	}
 }
 
+// IOptFallbackLang sets the fallback language of the input messages, it is
+// used if the language of the user is unknown.
+func IOptFallbackLang(lang string) InputOption {
+	return func(ip *Input) {
+		optFallbackLang(lang)(&ip.commonCtl)
+	}
+}
+
+// IOptValueResolver sets the function that resolves the value of the user
+// input, the default is the message text.  For the typed inputs, i.e.
+// NewIntInput, the returned value is validated and normalized by the input.
 func IOptValueResolver(fn ValueResolver) InputOption {
 	return func(ip *Input) {
 		ip.valueResolverFn = fn
 	}
 }
 
+// IOptResolveFirst makes the input call the ValueResolver before the
+// Callback, the Callback receives the resolved value as the Context.Data, and
+// is not called, if the resolver returns an error.  By default, the Callback
+// receives the message and the resolver is called after it.  The typed and
+// media inputs, i.e. NewIntInput, always resolve the value first.
+func IOptResolveFirst(b bool) InputOption {
+	return func(ip *Input) {
+		ip.resolveFirst = b
+	}
+}
+
+// IOptOnExpire sets the function that is called, when the input stops waiting
+// for the user response, because the entry has expired (see StartSweeper).  It
+// can be used to notify the user that the form has timed out, and to reset the
+// form:
+//
+//	func(ctx context.Context, b *tb.Bot, r tb.Recipient) {
+//		ctrl, _ := ControllerFromCtx(ctx)
+//		ctrl.Form().Reset(r.Recipient())
+//		b.Send(r, "The form has timed out.")
+//	}
+//
+// If the input has the ScopeChatUser scope, r is the ChatUser, and the message
+// should be sent to its ChatID.
+func IOptOnExpire(fn ExpireFunc) InputOption {
+	return func(ip *Input) {
+		ip.onExpire = fn
+	}
+}
+
+// IOptMaxAttempts sets the maximum number of attempts to enter the valid
+// value.  Once the user has run out of attempts, the input stops waiting for the
+// response, and the OnGiveUp function is called.  Zero means no limit.
+func IOptMaxAttempts(n int) InputOption {
+	return func(ip *Input) {
+		ip.maxAttempts = n
+	}
+}
+
+// IOptOnGiveUp sets the function that is called when the user runs out of
+// attempts, see IOptMaxAttempts.  It can be used to cancel the form:
+//
+//	func(ctx context.Context, c tb.Context) error {
+//		ctrl, _ := ControllerFromCtx(ctx)
+//		return ctrl.Form().Cancel(c)
+//	}
+func IOptOnGiveUp(fn HandleContextFunc) InputOption {
+	return func(ip *Input) {
+		ip.onGiveUp = fn
+	}
+}
+
+// IOptRetryMode sets the retry mode, the default is RetryResend.
+func IOptRetryMode(mode RetryMode) InputOption {
+	return func(ip *Input) {
+		ip.retryMode = mode
+	}
+}
+
+// IOptGroup enables the group mode.  In the group chats, the prompt is sent to
+// the chat, addressed to the user, and only the replies to the prompt are
+// accepted, so that several users can fill in the forms at once.  The values
+// are stored for the ChatUser recipient.  In private chats, the input works as
+// usual.  The request-contact and request-location inputs ignore it.
+func IOptGroup(b bool) InputOption {
+	return func(ip *Input) {
+		ip.group = b
+	}
+}
+
+// IOptScope sets the scope of the input state, see Scope.  In the group mode,
+// the group chats always use ScopeChatUser.
+func IOptScope(s Scope) InputOption {
+	return func(ip *Input) {
+		optScope(s)(&ip.commonCtl)
+	}
+}
+
+// IOptRegistry sets the registry that stores the input state.
+func IOptRegistry(reg Registry) InputOption {
+	return func(ip *Input) {
+		optRegistry(reg)(&ip.commonCtl)
+	}
+}
+
 // NewInput text creates a new text input, optionally chaining with the `next`
 // handler. One must use Handle as a handler for bot endpoint, and then hook the
 // OnText to OnTextMw.  TextCallbacker.Text should produce the text that user
@@ -66,6 +205,7 @@ func NewInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
 	ip := &Input{
 		commonCtl:       newCommonCtl(name),
 		tc:              tc,
+		retryDelay:      retryDelay,
 		valueResolverFn: func(msg *tb.Message) (string, error) { return msg.Text, nil },
 	}
 	for _, opt := range opts {
@@ -80,26 +220,104 @@ func NewInputText(name string, text string, onTextFn HandleContextFunc, opts ...
 	return NewInput(name, NewStaticTVC(text, nil, onTextFn), opts...)
 }
 
+// Handler sends the input prompt to the user and starts waiting for the
+// response.
 func (ip *Input) Handler(c tb.Context) error {
-	var opts []interface{}
-	if !ip.noReply {
-		opts = append(opts, tb.ForceReply)
-	}
+	ip.resetAttempts(ip.recipient(c).Recipient())
+	return ip.prompt(c)
+}
+
+// prompt sends the input prompt.
+func (ip *Input) prompt(c tb.Context) error {
 	text, err := ip.tc.Text(WithController(context.Background(), ip), c)
 	if err != nil {
-		c.Send(unexpectedErrorText(c))
+		c.Send(unexpectedErrorText(c, ip.fallbackLang))
 		return fmt.Errorf("error while generating text for controller: %s: %w", ip.name, err)
 	}
-	outbound, err := c.Bot().Send(c.Sender(), text, opts...)
+	opts := new(tb.SendOptions)
+	if ip.request != "" {
+		opts.ReplyMarkup = ip.requestMarkup(c)
+	} else if !ip.noReply || ip.inGroup(c) {
+		opts.ReplyMarkup = &tb.ReplyMarkup{ForceReply: true, Selective: ip.inGroup(c)}
+	}
+	if ip.inGroup(c) {
+		text = ip.addressUser(c, text, opts)
+	}
+	var outbound *tb.Message
+	if ip.replacesPrompt(c) {
+		// the edited message can't have the reply keyboard, so the user
+		// answers with the plain message.
+		outbound, err = ip.sendOrEdit(c, text)
+	} else {
+		outbound, err = c.Bot().Send(ip.dest(c), text, opts)
+	}
 	if err != nil {
 		return fmt.Errorf("Input.Handle: %w", err)
 	}
-	ip.reg.Wait(c.Sender(), outbound.ID)
-	ip.reg.Register(c.Sender(), outbound.ID)
+	r := ip.recipient(c)
+	ip.reg.Wait(r, outbound.ID)
+	ip.reg.Register(r, outbound.ID)
 	ip.logOutgoingMsg(outbound)
 	return nil
 }
 
+// replacesPrompt returns true if the prompt should replace the message of the
+// controller, that the user came from, i.e. Review, see Form.SetOverwrite.
+func (ip *Input) replacesPrompt(c tb.Context) bool {
+	if !ip.overwrite || ip.request != "" || ip.inGroup(c) {
+		return false
+	}
+	from, ok := fromCtrl(c)
+	if !ok {
+		return false
+	}
+	_, ok = from.OutgoingID(recipientOf(from, c).Recipient())
+	return ok
+}
+
+// inGroup returns true if the input runs in the group mode in a group chat.
+func (ip *Input) inGroup(c tb.Context) bool {
+	return ip.group && c.Chat() != nil && c.Chat().Type != tb.ChatPrivate
+}
+
+// recipient returns the recipient, that is the key of the input state: the
+// sender in the chat in the group mode, or the recipient of the input scope.
+func (ip *Input) recipient(c tb.Context) tb.Recipient {
+	if ip.inGroup(c) {
+		return ScopeChatUser(c)
+	}
+	return ip.commonCtl.recipient(c)
+}
+
+// dest returns the destination of the input messages: the group chat in the
+// group mode, or the sender otherwise.
+func (ip *Input) dest(c tb.Context) tb.Recipient {
+	if ip.inGroup(c) {
+		return c.Chat()
+	}
+	return c.Sender()
+}
+
+// addressUser makes the selective markup target the sender: it replies to the
+// sender's message, or mentions the sender, if the input was started by the
+// button press.  It returns the text of the message.
+func (ip *Input) addressUser(c tb.Context, text string, opts *tb.SendOptions) string {
+	if c.Callback() == nil && c.Message() != nil {
+		opts.ReplyTo = c.Message()
+		return text
+	}
+	name := strings.TrimSpace(c.Sender().FirstName + " " + c.Sender().LastName)
+	if name == "" {
+		name = Userinfo(c.Sender())
+	}
+	opts.Entities = tb.Entities{{
+		Type:   tb.EntityTMention,
+		Length: len(utf16.Encode([]rune(name))),
+		User:   c.Sender(),
+	}}
+	return name + ", " + text
+}
+
 // NewInputError returns an input error with msg.
 func NewInputError(msg string) error {
 	return &Error{Msg: msg, Type: TInputError}
@@ -109,7 +327,8 @@ func NewInputError(msg string) error {
 // process the message only if control awaits for this particular user input.
 func (ip *Input) OnTextMw(fn tb.HandlerFunc) tb.HandlerFunc {
 	return tb.HandlerFunc(func(c tb.Context) error {
-		if !ip.reg.IsWaiting(c.Sender()) {
+		r := ip.recipient(c)
+		if !ip.reg.IsWaiting(r) || (ip.inGroup(c) && !ip.isReply(c, r)) {
 			// not waiting for input, proceed to the next handler, if it's present.
 			if fn != nil {
 				return fn(c)
@@ -117,7 +336,19 @@ func (ip *Input) OnTextMw(fn tb.HandlerFunc) tb.HandlerFunc {
 			return nil
 		}
 
-		valueErr := ip.tc.Callback(WithController(context.Background(), ip), c)
+		var (
+			dataValue string
+			err       error
+		)
+		cc := c
+		if ip.resolveFirst {
+			if dataValue, err = ip.valueResolverFn(c.Message()); err != nil {
+				return ip.resolveError(c, err)
+			}
+			cc = &inputContext{Context: c, value: dataValue}
+		}
+
+		valueErr := ip.tc.Callback(WithController(context.Background(), ip), cc)
 		if valueErr != nil {
 			// wrong input or some other problem
 			lg.Println(valueErr)
@@ -130,28 +361,223 @@ func (ip *Input) OnTextMw(fn tb.HandlerFunc) tb.HandlerFunc {
 			}
 		}
 
-		dataValue, err := ip.valueResolverFn(c.Message())
-		if err != nil {
-			return err
+		if !ip.resolveFirst {
+			if dataValue, err = ip.valueResolverFn(c.Message()); err != nil {
+				return ip.resolveError(c, err)
+			}
 		}
-		ip.SetValue(c.Sender().Recipient(), dataValue)
+		ip.SetValue(r.Recipient(), dataValue)
 
 		ip.logCallbackMsg(c.Message())
-		ip.reg.Unregister(c.Sender(), ip.reg.StopWait(c.Sender())) // stop waiting and unregister message.
+		ip.reg.Unregister(r, ip.reg.StopWait(r)) // stop waiting and unregister message.
 
-		if ip.next != nil && valueErr == nil {
-			// if there are chained controls
-			return ip.next.Handler(c)
+		if valueErr == nil {
+			ip.resetAttempts(r.Recipient())
+			if ip.request != "" {
+				if err := ip.removeKeyboard(c); err != nil {
+					lg.Printf("%s: failed to remove the keyboard: %s", ip.name, err)
+				}
+			}
+			// if there are chained controls, or it's a last control in a form.
+			return ip.nextHandler(c)
 		}
 		return nil
 	})
 }
 
+// resolveError handles the error returned by the value resolver: the user is
+// asked to retry the input, if it is the input error.
+func (ip *Input) resolveError(c tb.Context, err error) error {
+	var e *Error
+	if errors.As(err, &e) && e.Type == TInputError {
+		return ip.processError(c, e.Msg)
+	}
+	return err
+}
+
+// isReply returns true if the message is the reply to the prompt, that awaits
+// the response from the recipient.
+func (ip *Input) isReply(c tb.Context, r tb.Recipient) bool {
+	m := c.Message()
+	return m != nil && m.ReplyTo != nil && m.ReplyTo.ID == ip.reg.WaitMsgID(r)
+}
+
+// inputContext is the context with the resolved input value.
+type inputContext struct {
+	tb.Context
+	value string
+}
+
+// Data returns the value returned by the ValueResolver.
+func (c *inputContext) Data() string { return c.value }
+
+// stopWait stops waiting for the user input.
+func (ip *Input) stopWait(c tb.Context) {
+	r := ip.recipient(c)
+	if ip.reg.IsWaiting(r) {
+		ip.reg.Unregister(r, ip.reg.StopWait(r))
+	}
+}
+
+// expire removes the expired registry entries, forgets the failed attempts and
+// calls the expiry function for each recipient that was awaited for the
+// response.
+func (ip *Input) expire(ctx context.Context, b *tb.Bot, before time.Time) []string {
+	expired := ip.commonCtl.expire(ctx, b, before)
+	for _, recipient := range expired {
+		ip.resetAttempts(recipient)
+	}
+	if ip.onExpire != nil {
+		ctrlCtx := WithController(ctx, ip)
+		for _, recipient := range expired {
+			ip.onExpire(ctrlCtx, b, keyRecipient(recipient))
+		}
+	}
+	return expired
+}
+
+// processError informs the user about the input error and asks to retry, or
+// gives up, if the user has run out of attempts.
 func (ip *Input) processError(c tb.Context, errmsg string) error {
+	if ip.maxAttempts > 0 && ip.attempt(ip.recipient(c).Recipient()) >= ip.maxAttempts {
+		return ip.giveUp(c)
+	}
+	switch ip.retryMode {
+	case RetryReply:
+		return ip.retryReply(c, errmsg)
+	case RetryEdit:
+		return ip.retryEdit(c, errmsg)
+	}
 	if err := c.Send(errmsg); err != nil {
 		return err
 	}
-	c.Bot().Notify(c.Sender(), tb.Typing)
-	time.Sleep(retryDelay)
-	return ip.Handler(c)
+	c.Bot().Notify(ip.dest(c), tb.Typing)
+	ip.resend(c)
+	return nil
+}
+
+// resend resends the prompt after the retry delay without blocking the update
+// handler.  The prompt is resent only if the input still waits for the
+// response to the same prompt, the pending resend of the recipient is
+// cancelled by resetAttempts.
+func (ip *Input) resend(c tb.Context) {
+	r := ip.recipient(c)
+	key, waitID := r.Recipient(), ip.reg.WaitMsgID(r)
+
+	ip.amu.Lock()
+	defer ip.amu.Unlock()
+	if ip.resends == nil {
+		ip.resends = make(map[string]*time.Timer)
+	}
+	if t, ok := ip.resends[key]; ok {
+		t.Stop()
+	}
+	var t *time.Timer
+	t = time.AfterFunc(ip.retryDelay, func() {
+		ip.amu.Lock()
+		if ip.resends[key] == t {
+			delete(ip.resends, key)
+		}
+		ip.amu.Unlock()
+		if !ip.reg.IsWaiting(r) || ip.reg.WaitMsgID(r) != waitID {
+			return
+		}
+		ip.reg.Unregister(r, waitID) // the new prompt replaces the old one.
+		if err := ip.prompt(c); err != nil {
+			lg.Printf("%s: retry: %s", ip.name, err)
+		}
+	})
+	ip.resends[key] = t
+}
+
+// retryReply sends the error message in reply to the prompt.
+func (ip *Input) retryReply(c tb.Context, errmsg string) error {
+	r := ip.recipient(c)
+	msgID, ok := ip.reg.OutgoingID(r.Recipient())
+	if !ok {
+		return ip.prompt(c)
+	}
+	opts := &tb.SendOptions{ReplyTo: &tb.Message{ID: msgID, Chat: c.Chat()}}
+	if ip.request != "" {
+		opts.ReplyMarkup = ip.requestMarkup(c)
+	} else if !ip.noReply || ip.inGroup(c) {
+		opts.ReplyMarkup = &tb.ReplyMarkup{ForceReply: true, Selective: ip.inGroup(c)}
+	}
+	if ip.inGroup(c) {
+		// replying to the user message targets the selective markup, and
+		// the user replies to the error message.
+		opts.ReplyTo = c.Message()
+	}
+	outbound, err := c.Bot().Send(ip.dest(c), errmsg, opts)
+	if err != nil {
+		return err
+	}
+	if ip.inGroup(c) {
+		ip.reg.Wait(r, outbound.ID)
+	}
+	return nil
+}
+
+// retryEdit edits the prompt, adding the error message to the text.  If the
+// prompt already has the same error message, i.e. the user repeats the wrong
+// input, it is left as is.
+func (ip *Input) retryEdit(c tb.Context, errmsg string) error {
+	msgID, ok := ip.reg.OutgoingID(ip.recipient(c).Recipient())
+	if !ok {
+		return ip.prompt(c)
+	}
+	text, err := ip.tc.Text(WithController(context.Background(), ip), c)
+	if err != nil {
+		return fmt.Errorf("error while generating text for controller: %s: %w", ip.name, err)
+	}
+	_, err = c.Bot().Edit(&tb.Message{ID: msgID, Chat: c.Chat()}, errmsg+"\n\n"+text)
+	if err != nil && !isNotModified(err) {
+		return err
+	}
+	return nil
+}
+
+// isNotModified returns true if err is the Telegram error, returned when the
+// edited message is the same as the original one.
+func isNotModified(err error) bool {
+	var e *tb.Error
+	return errors.As(err, &e) && e.Code == http.StatusBadRequest && strings.Contains(e.Description, "message is not modified")
+}
+
+// giveUp stops waiting for the user input, informs the user and calls the
+// OnGiveUp function.
+func (ip *Input) giveUp(c tb.Context) error {
+	ip.stopWait(c)
+	ip.resetAttempts(ip.recipient(c).Recipient())
+	if err := c.Send(PrinterContext(c, ip.fallbackLang).Sprintf(MsgTooManyAttempts)); err != nil {
+		lg.Printf("%s: %s", ip.name, err)
+	}
+	if ip.onGiveUp == nil {
+		return nil
+	}
+	return ip.onGiveUp(WithController(context.Background(), ip), c)
+}
+
+// attempt registers the failed attempt of the recipient and returns the
+// number of failed attempts.
+func (ip *Input) attempt(recipient string) int {
+	ip.amu.Lock()
+	defer ip.amu.Unlock()
+	if ip.attempts == nil {
+		ip.attempts = make(map[string]int)
+	}
+	ip.attempts[recipient]++
+	return ip.attempts[recipient]
+}
+
+// resetAttempts resets the number of failed attempts of the recipient and
+// cancels the pending resend of the prompt.
+func (ip *Input) resetAttempts(recipient string) {
+	ip.amu.Lock()
+	defer ip.amu.Unlock()
+	delete(ip.attempts, recipient)
+	if t, ok := ip.resends[recipient]; ok {
+		t.Stop()
+		delete(ip.resends, recipient)
+	}
 }
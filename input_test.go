@@ -0,0 +1,219 @@
+package tbcomctl
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+)
+
+func TestInput_retry(t *testing.T) {
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	setup := func(t *testing.T, opts ...InputOption) (*tb.Bot, *apiLog, *Input) {
+		b, log := newRecordingBot(t)
+		ip := NewIntInput("age", NewStaticTVC("Age?", nil, func(context.Context, tb.Context) error { return nil }), 1, 150, opts...)
+		ip.retryDelay = 0
+		b.Handle(tb.OnText, ip.OnTextMw(nil))
+		ip.reg.Wait(user, 1)
+		ip.reg.Register(user, 1)
+		return b, log, ip
+	}
+	send := func(b *tb.Bot, text string) {
+		b.ProcessUpdate(tb.Update{Message: &tb.Message{ID: 2, Sender: user, Chat: &tb.Chat{ID: user.ID}, Text: text}})
+	}
+
+	t.Run("resend", func(t *testing.T) {
+		b, log, ip := setup(t)
+		ip.reg.Wait(user, 5)
+		ip.reg.Register(user, 5)
+		send(b, "old")
+		assert.Eventually(t, func() bool { return len(log.methods()) == 3 }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, []string{"sendMessage", "sendChatAction", "sendMessage"}, log.methods())
+		assert.Equal(t, MsgInvalidInt, log.call(0).Params["text"])
+		assert.Equal(t, "Age?", log.call(2).Params["text"])
+		assert.True(t, ip.reg.IsWaiting(user))
+		assert.Eventually(t, func() bool { return ip.reg.WaitMsgID(user) == 1 }, time.Second, 10*time.Millisecond)
+		reqID, _ := ip.reg.RequestInfo(user, 5)
+		assert.Equal(t, registry.Unknown, reqID, "old prompt is unregistered")
+	})
+	t.Run("resend cancelled", func(t *testing.T) {
+		b, log, ip := setup(t)
+		ip.retryDelay = 50 * time.Millisecond
+		send(b, "old")
+		send(b, "42")
+		assert.False(t, ip.reg.IsWaiting(user))
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, []string{"sendMessage", "sendChatAction"}, log.methods(), "prompt is not resent after the valid answer")
+	})
+	t.Run("resend outdated", func(t *testing.T) {
+		b, log, ip := setup(t)
+		ip.retryDelay = 50 * time.Millisecond
+		send(b, "old")
+		ip.reg.Wait(user, 5) // the input was restarted.
+		time.Sleep(100 * time.Millisecond)
+		assert.Equal(t, []string{"sendMessage", "sendChatAction"}, log.methods(), "prompt is not resent for the other prompt")
+	})
+	t.Run("reply", func(t *testing.T) {
+		b, log, _ := setup(t, IOptRetryMode(RetryReply))
+		send(b, "old")
+		assert.Equal(t, []string{"sendMessage"}, log.methods())
+		assert.Equal(t, MsgInvalidInt, log.call(0).Params["text"])
+		assert.Equal(t, "1", log.call(0).Params["reply_to_message_id"])
+	})
+	t.Run("edit", func(t *testing.T) {
+		b, log, _ := setup(t, IOptRetryMode(RetryEdit))
+		send(b, "old")
+		assert.Equal(t, []string{"editMessageText"}, log.methods())
+		assert.Equal(t, MsgInvalidInt+"\n\nAge?", log.call(0).Params["text"])
+	})
+	t.Run("edit not modified", func(t *testing.T) {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			io.WriteString(w, `{"ok":false,"error_code":400,"description":"Bad Request: message is not modified: specified new message content and reply markup are exactly the same as a current content and reply markup of the message"}`)
+		}))
+		t.Cleanup(srv.Close)
+		b, err := tb.NewBot(tb.Settings{URL: srv.URL, Offline: true, Synchronous: true})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ip := NewIntInput("age", NewStaticTVC("Age?", nil, nil), 1, 150, IOptRetryMode(RetryEdit))
+		ip.reg.Wait(user, 1)
+		ip.reg.Register(user, 1)
+		c := b.NewContext(tb.Update{Message: &tb.Message{ID: 2, Sender: user, Chat: &tb.Chat{ID: user.ID}, Text: "old"}})
+		assert.NoError(t, ip.OnTextMw(nil)(c), "the same error message is not an error")
+		assert.True(t, ip.reg.IsWaiting(user))
+	})
+	t.Run("give up", func(t *testing.T) {
+		var cancelled bool
+		b, log, ip := setup(t, IOptMaxAttempts(2), IOptRetryMode(RetryReply), IOptOnGiveUp(func(ctx context.Context, c tb.Context) error {
+			ctrl, _ := ControllerFromCtx(ctx)
+			return ctrl.Form().Cancel(c)
+		}))
+		NewForm(ip).OnCancel(func(context.Context, tb.Context, map[string]string) error {
+			cancelled = true
+			return nil
+		})
+		send(b, "old")
+		assert.True(t, ip.reg.IsWaiting(user))
+		send(b, "200")
+		assert.False(t, ip.reg.IsWaiting(user), "input stops waiting")
+		assert.True(t, cancelled)
+		assert.Equal(t, MsgTooManyAttempts, log.call(1).Params["text"])
+
+		// attempts are counted from scratch on the next run.
+		ip.reg.Wait(user, 1)
+		send(b, "old")
+		assert.True(t, ip.reg.IsWaiting(user))
+	})
+	t.Run("expire", func(t *testing.T) {
+		b, _, ip := setup(t, IOptMaxAttempts(2), IOptRetryMode(RetryReply))
+		send(b, "old")
+		ip.expire(context.Background(), b, time.Now().Add(time.Hour))
+		assert.False(t, ip.reg.IsWaiting(user))
+		assert.Empty(t, ip.attempts, "attempts of the expired recipient are forgotten")
+	})
+}
+
+func TestInput_group(t *testing.T) {
+	alice := &tb.User{ID: 1, FirstName: "Alice"}
+	bob := &tb.User{ID: 2, FirstName: "Bob"}
+	group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+
+	b, log := newRecordingBot(t)
+	ip := NewInputText("name", "Name?", func(context.Context, tb.Context) error { return nil }, IOptGroup(true))
+	var chatter []string
+	b.Handle(tb.OnText, ip.OnTextMw(func(c tb.Context) error {
+		chatter = append(chatter, c.Text())
+		return nil
+	}))
+	msg := func(u *tb.User, chat *tb.Chat, text string, replyTo int) tb.Update {
+		m := &tb.Message{ID: 10, Sender: u, Chat: chat, Text: text}
+		if replyTo > 0 {
+			m.ReplyTo = &tb.Message{ID: replyTo, Chat: chat}
+		}
+		return tb.Update{Message: m}
+	}
+
+	// the prompt is sent to the group in reply to the user.
+	assert.NoError(t, ip.Handler(b.NewContext(msg(alice, group, "/start", 0))))
+	call := log.call(0)
+	assert.Equal(t, "-100", call.Params["chat_id"])
+	assert.Equal(t, "10", call.Params["reply_to_message_id"])
+	assert.Equal(t, "Name?", call.Params["text"])
+	assert.JSONEq(t, `{"force_reply":true,"selective":true}`, call.Params["reply_markup"])
+	assert.True(t, ip.reg.IsWaiting(ChatUser{ChatID: -100, UserID: 1}))
+	assert.False(t, ip.reg.IsWaiting(alice))
+
+	// the prompt started by the button press mentions the user.
+	b.Handle(&tb.Btn{Unique: "start"}, ip.Handler)
+	b.ProcessUpdate(tb.Update{Callback: &tb.Callback{ID: "1", Sender: bob, Message: &tb.Message{ID: 5, Chat: group}, Data: "\fstart"}})
+	call = log.call(1)
+	assert.Equal(t, "Bob, Name?", call.Params["text"])
+	assert.Contains(t, call.Params["entities"], `"type":"text_mention"`)
+	ip.reg.Wait(ChatUser{ChatID: -100, UserID: 2}, 7)
+
+	b.ProcessUpdate(msg(alice, group, "unrelated", 0))
+	b.ProcessUpdate(msg(bob, group, "Alice's prompt", 1))
+	assert.Equal(t, []string{"unrelated", "Alice's prompt"}, chatter, "only the replies to the own prompt are accepted")
+
+	b.ProcessUpdate(msg(alice, group, "Alice", 1))
+	b.ProcessUpdate(msg(bob, group, "Bob", 7))
+	val, _ := ip.Value("-100:1")
+	assert.Equal(t, "Alice", val)
+	val, _ = ip.Value(ChatUser{ChatID: -100, UserID: 2}.Recipient())
+	assert.Equal(t, "Bob", val)
+
+	// private chats work as usual.
+	ip.reg.Wait(alice, 1)
+	b.ProcessUpdate(msg(alice, &tb.Chat{ID: 1, Type: tb.ChatPrivate}, "private", 0))
+	val, _ = ip.Value(alice.Recipient())
+	assert.Equal(t, "private", val)
+}
+
+func TestInput_resolveOrder(t *testing.T) {
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	tests := []struct {
+		name     string
+		opts     []InputOption
+		wantCall []string
+		wantData string // Context.Data in the callback, if set
+	}{
+		{"default", nil, []string{"callback", "resolver"}, ""},
+		{"resolve first", []InputOption{IOptResolveFirst(true)}, []string{"resolver", "callback"}, "HELLO"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := newTestBot(t)
+			var calls []string
+			var text, data string
+			ip := NewInput("text", NewStaticTVC("Text?", nil, func(_ context.Context, c tb.Context) error {
+				calls = append(calls, "callback")
+				text, data = c.Message().Text, c.Data()
+				return nil
+			}), append(tt.opts, IOptValueResolver(func(m *tb.Message) (string, error) {
+				calls = append(calls, "resolver")
+				return strings.ToUpper(m.Text), nil
+			}))...)
+			b.Handle(tb.OnText, ip.OnTextMw(nil))
+
+			ip.reg.Wait(user, 1)
+			b.ProcessUpdate(tb.Update{Message: &tb.Message{ID: 2, Sender: user, Chat: &tb.Chat{ID: user.ID}, Text: "hello"}})
+			assert.Equal(t, tt.wantCall, calls)
+			assert.Equal(t, "hello", text, "callback receives the message")
+			if tt.wantData != "" {
+				assert.Equal(t, tt.wantData, data)
+			}
+			val, _ := ip.Value(user.Recipient())
+			assert.Equal(t, "HELLO", val, "resolved value is stored")
+		})
+	}
+}
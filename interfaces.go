@@ -59,6 +59,40 @@ type Valuer interface {
 	Values(ctx context.Context, c tb.Context) ([]string, error)
 }
 
+// Choice is the value with the label, that is shown to the user.
+type Choice struct {
+	Key   string // Key is the value stored in the registry and passed to the Callback as the callback data.
+	Label string // Label is the button label, it is translated with the package Printer.
+}
+
+// ChoiceValuer is the optional interface for the Picklist values, that
+// separates the stored values from the button labels.  If the
+// TextValueCallbacker implements it, the Picklist uses Choices instead of the
+// Values.
+type ChoiceValuer interface {
+	// Choices should return a list of choices to present to the user and an
+	// error.
+	Choices(ctx context.Context, c tb.Context) ([]Choice, error)
+}
+
+// stringChoices converts values to choices with the same key and label.
+func stringChoices(values []string) []Choice {
+	choices := make([]Choice, len(values))
+	for i, v := range values {
+		choices[i] = Choice{Key: v, Label: v}
+	}
+	return choices
+}
+
+// choiceKeys returns the keys of choices.
+func choiceKeys(choices []Choice) []string {
+	keys := make([]string, len(choices))
+	for i, ch := range choices {
+		keys[i] = ch.Key
+	}
+	return keys
+}
+
 // Callbacker defines the interface for the callback function.
 type Callbacker interface {
 	// Callback should process the handler's callback.
@@ -112,6 +146,26 @@ func NewStaticTVC(text string, values []string, callbackFn HandleContextFunc) *T
 	}
 }
 
+// choiceTVC is the TVC with static choices.
+type choiceTVC struct {
+	*TVC
+	choices []Choice
+}
+
+// NewStaticChoiceTVC is a convenience constructor for TextValueCallbacker with
+// static text and choices, see ChoiceValuer.
+func NewStaticChoiceTVC(text string, choices []Choice, callbackFn HandleContextFunc) TextValueCallbacker {
+	return &choiceTVC{
+		TVC:     NewStaticTVC(text, choiceKeys(choices), callbackFn),
+		choices: choices,
+	}
+}
+
+// Choices returns the static choices.
+func (t *choiceTVC) Choices(context.Context, tb.Context) ([]Choice, error) {
+	return t.choices, nil
+}
+
 // Text callse the TextFn with contexts.
 // ctx is legacy from the times when there was not telebot.Context.
 func (t *TVC) Text(ctx context.Context, c tb.Context) (string, error) {
@@ -0,0 +1,133 @@
+// Package codec implements the compact encoding of the inline button callback
+// data.
+//
+// Telegram limits the callback data to 64 bytes, and telebot routes the
+// callback to the handler by the unique prefix of the data, so the full
+// callback data of the button is:
+//
+//	\f<id>|<version>:<signature>:<payload>
+//
+// where id is the control ID, version identifies the set of buttons the
+// message was sent with, and signature is the truncated HMAC of id, version
+// and payload, it is empty, if the secret is not set.
+package codec
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	// MaxLen is the maximum length of the callback data allowed by Telegram.
+	MaxLen = 64
+
+	idLen      = 8 // length of the control ID.
+	versionLen = 4 // length of the version generated by Version.
+	sigBytes   = 8 // number of bytes of the HMAC in the signature.
+	sep        = ":"
+	// overhead is the number of bytes telebot adds to the data: \f and |.
+	overhead = 2
+)
+
+var (
+	ErrTooLong   = errors.New("callback data is too long")
+	ErrFormat    = errors.New("invalid callback data format")
+	ErrSignature = errors.New("invalid callback data signature")
+)
+
+var enc = base64.RawURLEncoding
+
+// Data is the callback data of the button.
+type Data struct {
+	ID      string // control ID, see ID.
+	Version string // version of the button set, see Version.
+	Payload string // button specific data.
+}
+
+// Codec encodes and decodes the callback data.  Zero value is the codec that
+// does not sign the data.
+type Codec struct {
+	secret []byte
+}
+
+// New creates the new codec, if secret is not empty, the data is signed with
+// HMAC-SHA256.
+func New(secret []byte) *Codec {
+	return &Codec{secret: secret}
+}
+
+// ID returns the short control ID for the name, that is safe to use as the
+// telebot unique.
+func ID(name string) string {
+	return hashString(name, idLen)
+}
+
+// Version returns the short version string for the items, i.e. the values of
+// the buttons.  The version changes, if the items change.
+func Version(items ...string) string {
+	return hashString(strings.Join(items, "\x00"), versionLen)
+}
+
+// hashString returns the first n characters of the encoded SHA1 of s.
+func hashString(s string, n int) string {
+	sum := sha1.Sum([]byte(s))
+	return enc.EncodeToString(sum[:])[:n]
+}
+
+// Encode encodes the data, it returns the encoded data without the ID, which
+// should be used as the telebot button unique.  If the data with the ID exceeds
+// MaxLen, ErrTooLong is returned.
+func (c *Codec) Encode(d Data) (string, error) {
+	if !validToken(d.ID) || (d.Version != "" && !validToken(d.Version)) {
+		return "", fmt.Errorf("%w: id and version must only contain letters, digits, '-' and '_'", ErrFormat)
+	}
+	s := d.Version + sep + c.sign(d) + sep + d.Payload
+	if len(d.ID)+overhead+len(s) > MaxLen {
+		return "", fmt.Errorf("%w: %d bytes", ErrTooLong, len(d.ID)+overhead+len(s))
+	}
+	return s, nil
+}
+
+// Decode decodes the callback data s of the control id and verifies the
+// signature.
+func (c *Codec) Decode(id string, s string) (Data, error) {
+	parts := strings.SplitN(s, sep, 3)
+	if len(parts) != 3 {
+		return Data{}, ErrFormat
+	}
+	d := Data{ID: id, Version: parts[0], Payload: parts[2]}
+	if !hmac.Equal([]byte(parts[1]), []byte(c.sign(d))) {
+		return Data{}, ErrSignature
+	}
+	return d, nil
+}
+
+// sign returns the signature of the data, or an empty string, if the secret is
+// not set.
+func (c *Codec) sign(d Data) string {
+	if c == nil || len(c.secret) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, c.secret)
+	mac.Write([]byte(d.ID + sep + d.Version + sep + d.Payload))
+	return enc.EncodeToString(mac.Sum(nil)[:sigBytes])
+}
+
+// validToken returns true if s is not empty and consists of characters allowed
+// in the telebot unique.
+func validToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
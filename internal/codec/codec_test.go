@@ -0,0 +1,90 @@
+package codec
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// cbackRx is the telebot callback data regexp.
+var cbackRx = regexp.MustCompile(`^\f([-\w]+)(\|(.+))?$`)
+
+func TestCodec_roundtrip(t *testing.T) {
+	tests := []struct {
+		name   string
+		secret []byte
+		d      Data
+	}{
+		{"unsigned", nil, Data{ID: ID("picklist:colour"), Version: Version("red", "green"), Payload: "1"}},
+		{"signed", []byte("secret"), Data{ID: ID("picklist:colour"), Version: Version("red", "green"), Payload: "1"}},
+		{"payload with separators", []byte("secret"), Data{ID: "x", Version: "1", Payload: "a:b|c"}},
+		{"no version", nil, Data{ID: "x", Payload: "label"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := New(tt.secret)
+			s, err := c.Encode(tt.d)
+			if err != nil {
+				t.Fatal(err)
+			}
+			full := "\f" + tt.d.ID + "|" + s
+			assert.LessOrEqual(t, len(full), MaxLen)
+			m := cbackRx.FindStringSubmatch(full)
+			if assert.NotNil(t, m, "telebot must route the data") {
+				assert.Equal(t, tt.d.ID, m[1])
+				assert.Equal(t, s, m[3])
+			}
+			got, err := c.Decode(tt.d.ID, s)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.d, got)
+		})
+	}
+}
+
+func TestCodec_Encode_errors(t *testing.T) {
+	c := New([]byte("secret"))
+	_, err := c.Encode(Data{ID: "x", Version: "1", Payload: strings.Repeat("a", MaxLen)})
+	assert.True(t, errors.Is(err, ErrTooLong))
+	_, err = c.Encode(Data{ID: "x|y", Version: "1"})
+	assert.True(t, errors.Is(err, ErrFormat))
+	_, err = c.Encode(Data{ID: "x", Version: "1:2"})
+	assert.True(t, errors.Is(err, ErrFormat))
+}
+
+func TestCodec_Decode_errors(t *testing.T) {
+	signed := New([]byte("secret"))
+	s, err := signed.Encode(Data{ID: "x", Version: "1", Payload: "0"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		c       *Codec
+		id      string
+		s       string
+		wantErr error
+	}{
+		{"raw data", signed, "x", "0", ErrFormat},
+		{"tampered payload", signed, "x", s[:len(s)-1] + "1", ErrSignature},
+		{"other control", signed, "y", s, ErrSignature},
+		{"other secret", New([]byte("other")), "x", s, ErrSignature},
+		{"unsigned codec", New(nil), "x", s, ErrSignature},
+		{"unsigned data", signed, "x", "1::0", ErrSignature},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.c.Decode(tt.id, tt.s)
+			assert.True(t, errors.Is(err, tt.wantErr), "got %v, want %v", err, tt.wantErr)
+		})
+	}
+}
+
+func TestID(t *testing.T) {
+	assert.Len(t, ID("picklist:colour"), idLen)
+	assert.NotEqual(t, ID("picklist:colour"), ID("picklist:size"))
+	assert.Equal(t, Version("a", "b"), Version("a", "b"))
+	assert.NotEqual(t, Version("a", "b"), Version("ab"))
+}
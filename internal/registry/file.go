@@ -0,0 +1,201 @@
+package registry
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	tb "gopkg.in/telebot.v3"
+)
+
+// File is the in-memory registry that persists its state to a file on every
+// change.
+type File struct {
+	*Memory
+
+	filename string
+	errFn    func(error) // called if the state can't be saved.
+	fmu      sync.Mutex  // serialises file writes.
+}
+
+// state is the serialisable state of the Memory registry.
+type state struct {
+	Cache      map[string]map[int]uuid.UUID `json:"cache"`
+	WaitMsgID  map[string]int               `json:"wait"`
+	Values     map[string]string            `json:"values"`
+	MessageIDs map[string]int               `json:"message_ids"`
+
+	WaitAt    map[string]time.Time `json:"wait_at,omitempty"`
+	ValueAt   map[string]time.Time `json:"value_at,omitempty"`
+	MessageAt map[string]time.Time `json:"message_at,omitempty"`
+}
+
+// NewFileRegistry initialises the new file registry.  If the file exists, the
+// state is loaded from it.  errFn is called with the error, if the state can't
+// be saved, it may be nil.
+func NewFileRegistry(filename string, errFn func(error)) (*File, error) {
+	if errFn == nil {
+		errFn = func(error) {}
+	}
+	f := &File{
+		Memory:   NewMemRegistry(),
+		filename: filename,
+		errFn:    errFn,
+	}
+	if err := f.load(); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	return f, nil
+}
+
+// load loads the state from the file.
+func (f *File) load() error {
+	data, err := os.ReadFile(f.filename)
+	if err != nil {
+		return err
+	}
+	var st state
+	if err := json.Unmarshal(data, &st); err != nil {
+		return fmt.Errorf("registry: %s: %w", f.filename, err)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if st.Cache != nil {
+		f.cache = st.Cache
+	}
+	if st.WaitMsgID != nil {
+		f.waitMsgID = st.WaitMsgID
+	}
+	if st.Values != nil {
+		f.values = st.Values
+	}
+	if st.MessageIDs != nil {
+		f.messageIDs = st.MessageIDs
+	}
+	// entries saved without update times are considered updated at load time.
+	now := timeNow()
+	for k := range f.waitMsgID {
+		f.waitAt[k] = timeOr(st.WaitAt, k, now)
+	}
+	for k := range f.values {
+		f.valueAt[k] = timeOr(st.ValueAt, k, now)
+	}
+	for k := range f.messageIDs {
+		f.messageAt[k] = timeOr(st.MessageAt, k, now)
+	}
+	return nil
+}
+
+// timeOr returns the time for the key k from times, or dflt, if it's absent.
+func timeOr(times map[string]time.Time, k string, dflt time.Time) time.Time {
+	if at, ok := times[k]; ok {
+		return at
+	}
+	return dflt
+}
+
+// save writes the state to the temporary file and replaces the registry file
+// with it.  The file lock is held from the snapshot till the end of the write,
+// so that the older snapshot never replaces the newer one.
+func (f *File) save() {
+	f.fmu.Lock()
+	defer f.fmu.Unlock()
+
+	f.mu.RLock()
+	data, err := json.Marshal(state{
+		Cache:      f.cache,
+		WaitMsgID:  f.waitMsgID,
+		Values:     f.values,
+		MessageIDs: f.messageIDs,
+		WaitAt:     f.waitAt,
+		ValueAt:    f.valueAt,
+		MessageAt:  f.messageAt,
+	})
+	f.mu.RUnlock()
+	if err != nil {
+		f.errFn(err)
+		return
+	}
+	if err := writeFile(f.filename, data); err != nil {
+		f.errFn(err)
+	}
+}
+
+// writeFile atomically replaces the filename with data.
+func writeFile(filename string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(filename), filepath.Base(filename)+".*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), filename)
+}
+
+// Register inserts the message into cache assigning it a random request id.
+func (f *File) Register(r tb.Recipient, msgID int) uuid.UUID {
+	defer f.save()
+	return f.Memory.Register(r, msgID)
+}
+
+// Unregister removes the request from cache.
+func (f *File) Unregister(r tb.Recipient, msgID int) {
+	defer f.save()
+	f.Memory.Unregister(r, msgID)
+}
+
+// SetValue sets the Controller value.
+func (f *File) SetValue(recipient string, value string) {
+	defer f.save()
+	f.Memory.SetValue(recipient, value)
+}
+
+// Wait places the outbound message ID to the waiting list.
+func (f *File) Wait(r tb.Recipient, outboundID int) {
+	defer f.save()
+	f.Memory.Wait(r, outboundID)
+}
+
+// StopWait removes the recipient from the wait list.
+func (f *File) StopWait(r tb.Recipient) int {
+	defer f.save()
+	return f.Memory.StopWait(r)
+}
+
+// Expire removes the entries that were last updated before the given time.
+// The file is saved only if some entries were removed.
+func (f *File) Expire(prefix string, before time.Time) []string {
+	expired, removed := f.Memory.expire(prefix, before, true)
+	if removed > 0 {
+		f.save()
+	}
+	return expired
+}
+
+// ExpireRequests is the Expire, that keeps the values of the recipients.
+func (f *File) ExpireRequests(prefix string, before time.Time) []string {
+	expired, removed := f.Memory.expire(prefix, before, false)
+	if removed > 0 {
+		f.save()
+	}
+	return expired
+}
+
+// Clear removes all entries of the recipient.
+func (f *File) Clear(recipient string) {
+	defer f.save()
+	f.Memory.Clear(recipient)
+}
@@ -0,0 +1,98 @@
+package registry
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestFile_restore(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.json")
+	user := &tb.User{ID: 42}
+
+	f, err := NewFileRegistry(filename, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	reqID := f.Register(user, 100)
+	f.Wait(user, 100)
+	f.SetValue(user.Recipient(), "value")
+
+	// simulating the restart
+	restored, err := NewFileRegistry(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, ok := restored.RequestFor(user, 100)
+	assert.True(t, ok)
+	assert.Equal(t, reqID, got)
+	assert.True(t, restored.IsWaiting(user))
+	assert.Equal(t, 100, restored.WaitMsgID(user))
+	val, ok := restored.Value(user.Recipient())
+	assert.True(t, ok)
+	assert.Equal(t, "value", val)
+	id, ok := restored.OutgoingID(user.Recipient())
+	assert.True(t, ok)
+	assert.Equal(t, 100, id)
+}
+
+func TestFile_concurrentSave(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.json")
+	f, err := NewFileRegistry(filename, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	const n = 50
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			f.SetValue(strconv.Itoa(i), "value")
+		}(i)
+	}
+	wg.Wait()
+
+	// the last write must have the latest state.
+	restored, err := NewFileRegistry(filename, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < n; i++ {
+		_, ok := restored.Value(strconv.Itoa(i))
+		assert.True(t, ok, "value %d", i)
+	}
+}
+
+func TestNewFileRegistry_corrupt(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.json")
+	if err := writeFile(filename, []byte("{")); err != nil {
+		t.Fatal(err)
+	}
+	_, err := NewFileRegistry(filename, nil)
+	assert.Error(t, err)
+}
+
+func TestFile_Expire(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.json")
+	f, err := NewFileRegistry(filename, func(err error) { t.Error(err) })
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetValue("42", "value")
+	if err := os.Remove(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	assert.Empty(t, f.Expire("", time.Now().Add(-time.Hour)))
+	assert.NoFileExists(t, filename, "file must not be saved if nothing has expired")
+
+	f.Expire("", time.Now().Add(time.Hour))
+	assert.FileExists(t, filename)
+}
@@ -1,6 +1,7 @@
 package registry
 
 import (
+	"strings"
 	"sync"
 	"time"
 
@@ -13,13 +14,31 @@ const (
 	nothing = 0
 )
 
+// timeNow is the function that returns the current time.
+var timeNow = time.Now
+
+// SetTimeFunc replaces the function that returns the current time of the
+// registries, and returns the function that restores the previous one.  It is
+// used in tests.
+func SetTimeFunc(fn func() time.Time) (restore func()) {
+	old := timeNow
+	timeNow = fn
+	return func() { timeNow = old }
+}
+
 // Memory holds the state of the user interaction in-memory.
 type Memory struct {
 	cache      map[string]map[int]uuid.UUID // requests cache, maps message ID to request.
 	waitMsgID  map[string]int               // await maps userID to the messageID and indicates that we're waiting for user to reply.
 	values     map[string]string            // values entered, maps userID to the value
 	messageIDs map[string]int               // messages sent, maps userID to the message_id
-	mu         sync.RWMutex
+
+	// update times of the entries, request times are stored in the UUIDs.
+	waitAt    map[string]time.Time
+	valueAt   map[string]time.Time
+	messageAt map[string]time.Time
+
+	mu sync.RWMutex
 }
 
 // NewMemRegistry initialises new in-memory message and user registry.
@@ -29,6 +48,9 @@ func NewMemRegistry() *Memory {
 		waitMsgID:  make(map[string]int),
 		values:     make(map[string]string),
 		messageIDs: make(map[string]int),
+		waitAt:     make(map[string]time.Time),
+		valueAt:    make(map[string]time.Time),
+		messageAt:  make(map[string]time.Time),
 	}
 }
 
@@ -42,6 +64,7 @@ func (reg *Memory) Register(r tb.Recipient, msgID int) uuid.UUID {
 	reqID := uuid.Must(uuid.NewUUID())
 	reg.cache[r.Recipient()][msgID] = reqID
 	reg.messageIDs[r.Recipient()] = msgID
+	reg.messageAt[r.Recipient()] = timeNow()
 	return reqID
 }
 
@@ -61,8 +84,6 @@ func (reg *Memory) RequestFor(r tb.Recipient, msgID int) (uuid.UUID, bool) {
 	reg.mu.RLock()
 	defer reg.mu.RUnlock()
 
-	reg.requestEnsure(r)
-
 	reqID, ok := reg.cache[r.Recipient()][msgID]
 	return reqID, ok
 }
@@ -90,9 +111,6 @@ func (reg *Memory) Value(recipient string) (string, bool) {
 	reg.mu.RLock()
 	defer reg.mu.RUnlock()
 
-	if reg.values == nil {
-		reg.values = make(map[string]string)
-	}
 	v, ok := reg.values[recipient]
 	return v, ok
 }
@@ -103,8 +121,10 @@ func (reg *Memory) SetValue(recipient string, value string) {
 	defer reg.mu.Unlock()
 	if reg.values == nil {
 		reg.values = make(map[string]string)
+		reg.valueAt = make(map[string]time.Time)
 	}
 	reg.values[recipient] = value
+	reg.valueAt[recipient] = timeNow()
 }
 
 // OutgoingID returns the controller's outgoing message ID for the user.
@@ -128,14 +148,20 @@ func (reg *Memory) Wait(r tb.Recipient, outboundID int) {
 
 	if reg.waitMsgID == nil {
 		reg.waitMsgID = make(map[string]int)
+		reg.waitAt = make(map[string]time.Time)
 	}
 	reg.waitMsgID[r.Recipient()] = outboundID
+	reg.waitAt[r.Recipient()] = timeNow()
 }
 
 // StopWait removes the recipient from the wait list.
 func (reg *Memory) StopWait(r tb.Recipient) int {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
 	outboundID := reg.waitMsgID[r.Recipient()]
-	reg.waitMsgID[r.Recipient()] = nothing
+	delete(reg.waitMsgID, r.Recipient())
+	delete(reg.waitAt, r.Recipient())
 	return outboundID
 }
 
@@ -153,3 +179,83 @@ func (reg *Memory) IsWaiting(r tb.Recipient) bool {
 	defer reg.mu.RUnlock()
 	return reg.waitMsgID[r.Recipient()] != nothing
 }
+
+//
+// expiry
+//
+
+// Expire removes the entries of the recipients with keys starting with prefix,
+// that were last updated before the given time.  It returns the keys of the
+// recipients, that were awaiting the response, with the prefix trimmed.
+func (reg *Memory) Expire(prefix string, before time.Time) []string {
+	expired, _ := reg.expire(prefix, before, true)
+	return expired
+}
+
+// ExpireRequests is the Expire, that keeps the values of the recipients, it
+// removes only the requests, the outgoing messages and the waits.
+func (reg *Memory) ExpireRequests(prefix string, before time.Time) []string {
+	expired, _ := reg.expire(prefix, before, false)
+	return expired
+}
+
+// expire is the implementation of Expire, it also returns the number of the
+// removed entries.  The values are removed only if values is true.
+func (reg *Memory) expire(prefix string, before time.Time, values bool) (expired []string, removed int) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	for key, at := range reg.waitAt {
+		if strings.HasPrefix(key, prefix) && at.Before(before) {
+			if reg.waitMsgID[key] != nothing {
+				expired = append(expired, strings.TrimPrefix(key, prefix))
+			}
+			delete(reg.waitMsgID, key)
+			delete(reg.waitAt, key)
+			removed++
+		}
+	}
+	for key, at := range reg.valueAt {
+		if values && strings.HasPrefix(key, prefix) && at.Before(before) {
+			delete(reg.values, key)
+			delete(reg.valueAt, key)
+			removed++
+		}
+	}
+	for key, at := range reg.messageAt {
+		if strings.HasPrefix(key, prefix) && at.Before(before) {
+			delete(reg.messageIDs, key)
+			delete(reg.messageAt, key)
+			removed++
+		}
+	}
+	for key, requests := range reg.cache {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		for msgID, reqID := range requests {
+			if time.Unix(reqID.Time().UnixTime()).Before(before) {
+				delete(requests, msgID)
+				removed++
+			}
+		}
+		if len(requests) == 0 {
+			delete(reg.cache, key)
+		}
+	}
+	return expired, removed
+}
+
+// Clear removes all entries of the recipient.
+func (reg *Memory) Clear(recipient string) {
+	reg.mu.Lock()
+	defer reg.mu.Unlock()
+
+	delete(reg.cache, recipient)
+	delete(reg.waitMsgID, recipient)
+	delete(reg.waitAt, recipient)
+	delete(reg.values, recipient)
+	delete(reg.valueAt, recipient)
+	delete(reg.messageIDs, recipient)
+	delete(reg.messageAt, recipient)
+}
@@ -0,0 +1,29 @@
+package registry_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+	"github.com/rusq/tbcomctl/v4/internal/registry/registrytest"
+)
+
+// The SQL registry is tested in the sqlite module, so that the SQLite driver
+// is not the dependency of this module.
+
+func TestConformance(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		registrytest.Run(t, func(t *testing.T) registrytest.Registry {
+			return registry.NewMemRegistry()
+		})
+	})
+	t.Run("file", func(t *testing.T) {
+		registrytest.Run(t, func(t *testing.T) registrytest.Registry {
+			reg, err := registry.NewFileRegistry(filepath.Join(t.TempDir(), "registry.json"), func(err error) { t.Error(err) })
+			if err != nil {
+				t.Fatal(err)
+			}
+			return reg
+		})
+	})
+}
@@ -0,0 +1,177 @@
+// Package registrytest contains the conformance suite for the registry
+// implementations.  It is used by the tests of the registries that live in the
+// separate modules, i.e. the SQL registry tested with the SQLite driver.
+package registrytest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+)
+
+// Registry is the set of methods that all registries must implement.
+type Registry interface {
+	Register(r tb.Recipient, msgID int) uuid.UUID
+	Unregister(r tb.Recipient, msgID int)
+	RequestInfo(r tb.Recipient, msgID int) (string, time.Time)
+	Value(recipient string) (string, bool)
+	SetValue(recipient string, value string)
+	OutgoingID(recipient string) (int, bool)
+	Wait(r tb.Recipient, outboundID int)
+	StopWait(r tb.Recipient) int
+	WaitMsgID(r tb.Recipient) int
+	IsWaiting(r tb.Recipient) bool
+	Clear(recipient string)
+	Expire(prefix string, before time.Time) []string
+	ExpireRequests(prefix string, before time.Time) []string
+}
+
+// Run runs the conformance suite for the registry returned by newFn.
+func Run(t *testing.T, newFn func(t *testing.T) Registry) {
+	var (
+		user  = &tb.User{ID: 42}
+		other = &tb.User{ID: 43}
+	)
+	tests := []struct {
+		name string
+		fn   func(t *testing.T, reg Registry)
+	}{
+		{"empty registry", func(t *testing.T, reg Registry) {
+			_, ok := reg.Value(user.Recipient())
+			assert.False(t, ok)
+			_, ok = reg.OutgoingID(user.Recipient())
+			assert.False(t, ok)
+			assert.False(t, reg.IsWaiting(user))
+			assert.Equal(t, 0, reg.WaitMsgID(user))
+			reqID, at := reg.RequestInfo(user, 1)
+			assert.Equal(t, registry.Unknown, reqID)
+			assert.True(t, at.IsZero())
+		}},
+		{"register and unregister", func(t *testing.T, reg Registry) {
+			want := reg.Register(user, 100)
+			reqID, at := reg.RequestInfo(user, 100)
+			assert.Equal(t, want.String(), reqID)
+			assert.WithinDuration(t, time.Now(), at, 2*time.Second)
+			id, ok := reg.OutgoingID(user.Recipient())
+			assert.True(t, ok)
+			assert.Equal(t, 100, id)
+
+			reg.Register(user, 101)
+			id, _ = reg.OutgoingID(user.Recipient())
+			assert.Equal(t, 101, id)
+
+			reg.Unregister(user, 100)
+			reqID, _ = reg.RequestInfo(user, 100)
+			assert.Equal(t, registry.Unknown, reqID)
+			// outgoing ID is retained after unregister
+			id, ok = reg.OutgoingID(user.Recipient())
+			assert.True(t, ok)
+			assert.Equal(t, 101, id)
+		}},
+		{"values", func(t *testing.T, reg Registry) {
+			reg.SetValue(user.Recipient(), "one")
+			reg.SetValue(user.Recipient(), "two")
+			reg.SetValue(other.Recipient(), "")
+			v, ok := reg.Value(user.Recipient())
+			assert.True(t, ok)
+			assert.Equal(t, "two", v)
+			v, ok = reg.Value(other.Recipient())
+			assert.True(t, ok)
+			assert.Equal(t, "", v)
+		}},
+		{"waiting", func(t *testing.T, reg Registry) {
+			reg.Wait(user, 200)
+			assert.True(t, reg.IsWaiting(user))
+			assert.False(t, reg.IsWaiting(other))
+			assert.Equal(t, 200, reg.WaitMsgID(user))
+			assert.Equal(t, 200, reg.StopWait(user))
+			assert.False(t, reg.IsWaiting(user))
+			assert.Equal(t, 0, reg.StopWait(user))
+		}},
+		{"clear", func(t *testing.T, reg Registry) {
+			reg.Register(user, 300)
+			reg.Wait(user, 300)
+			reg.SetValue(user.Recipient(), "value")
+			reg.SetValue(other.Recipient(), "other")
+			reg.Clear(user.Recipient())
+
+			_, ok := reg.Value(user.Recipient())
+			assert.False(t, ok)
+			_, ok = reg.OutgoingID(user.Recipient())
+			assert.False(t, ok)
+			assert.False(t, reg.IsWaiting(user))
+			reqID, _ := reg.RequestInfo(user, 300)
+			assert.Equal(t, registry.Unknown, reqID)
+			_, ok = reg.Value(other.Recipient())
+			assert.True(t, ok)
+		}},
+		{"expire", func(t *testing.T, reg Registry) {
+			var (
+				old   = nsr("a:42")
+				fresh = nsr("a:43")
+				other = nsr("b:42")
+			)
+			setTime(t, time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+			reg.Register(old, 1)
+			reg.Wait(old, 1)
+			reg.SetValue(old.Recipient(), "old")
+			reg.Wait(other, 2)
+			reg.SetValue(other.Recipient(), "other")
+
+			setTime(t, time.Date(2020, 1, 1, 12, 0, 0, 0, time.UTC))
+			reg.SetValue(fresh.Recipient(), "fresh")
+
+			expired := reg.Expire("a:", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC))
+			assert.Equal(t, []string{"42"}, expired)
+
+			assert.False(t, reg.IsWaiting(old))
+			_, ok := reg.Value(old.Recipient())
+			assert.False(t, ok)
+			_, ok = reg.OutgoingID(old.Recipient())
+			assert.False(t, ok)
+			// fresh entries and entries with other prefixes are retained.
+			_, ok = reg.Value(fresh.Recipient())
+			assert.True(t, ok)
+			assert.True(t, reg.IsWaiting(other))
+			_, ok = reg.Value(other.Recipient())
+			assert.True(t, ok)
+		}},
+		{"expire requests", func(t *testing.T, reg Registry) {
+			old := nsr("a:42")
+			setTime(t, time.Date(2020, 1, 1, 10, 0, 0, 0, time.UTC))
+			reg.Register(old, 1)
+			reg.Wait(old, 1)
+			reg.SetValue(old.Recipient(), "old")
+
+			expired := reg.ExpireRequests("a:", time.Date(2020, 1, 1, 11, 0, 0, 0, time.UTC))
+			assert.Equal(t, []string{"42"}, expired)
+
+			assert.False(t, reg.IsWaiting(old))
+			_, ok := reg.OutgoingID(old.Recipient())
+			assert.False(t, ok)
+			val, _ := reg.Value(old.Recipient())
+			assert.Equal(t, "old", val, "values are retained")
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.fn(t, newFn(t))
+		})
+	}
+}
+
+// nsr is the recipient with the arbitrary key.
+type nsr string
+
+func (r nsr) Recipient() string { return string(r) }
+
+// setTime sets the registry time to the given value for the duration of the
+// test.
+func setTime(t *testing.T, now time.Time) {
+	t.Cleanup(registry.SetTimeFunc(func() time.Time { return now }))
+}
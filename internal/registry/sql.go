@@ -0,0 +1,360 @@
+package registry
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Placeholder is the style of query parameter placeholders used by the
+// database driver.
+type Placeholder int
+
+const (
+	Question Placeholder = iota // "?" placeholders, i.e. SQLite and MySQL.
+	Dollar                      // "$1" placeholders, i.e. PostgreSQL.
+)
+
+// SQL is the registry that stores the state in the SQL database.  Several
+// instances of the bot may share the same database.
+type SQL struct {
+	db    *sql.DB
+	ph    Placeholder
+	errFn func(error) // called on database errors.
+}
+
+// migrations is the list of the schema migrations.  The index of the
+// migration + 1 is the schema version.  Migrations must never be changed once
+// released, only new ones can be appended.
+var migrations = []string{
+	`CREATE TABLE tbcomctl_requests (
+		recipient VARCHAR(255) NOT NULL,
+		msg_id BIGINT NOT NULL,
+		req_id VARCHAR(36) NOT NULL,
+		PRIMARY KEY (recipient, msg_id)
+	)`,
+	`CREATE TABLE tbcomctl_values (
+		recipient VARCHAR(255) NOT NULL PRIMARY KEY,
+		value TEXT NOT NULL
+	)`,
+	`CREATE TABLE tbcomctl_messages (
+		recipient VARCHAR(255) NOT NULL PRIMARY KEY,
+		msg_id BIGINT NOT NULL
+	)`,
+	`CREATE TABLE tbcomctl_waits (
+		recipient VARCHAR(255) NOT NULL PRIMARY KEY,
+		msg_id BIGINT NOT NULL
+	)`,
+	// update times (unix seconds), entries created before these migrations
+	// are expired on the first sweep.
+	`ALTER TABLE tbcomctl_requests ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE tbcomctl_values ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE tbcomctl_messages ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+	`ALTER TABLE tbcomctl_waits ADD COLUMN updated_at BIGINT NOT NULL DEFAULT 0`,
+}
+
+// tables is the list of tables that hold the registry entries.
+var tables = []string{"tbcomctl_requests", "tbcomctl_values", "tbcomctl_messages", "tbcomctl_waits"}
+
+// requestTables is the list of tables that hold the registry entries, except
+// the values, see ExpireRequests.
+var requestTables = []string{"tbcomctl_requests", "tbcomctl_messages", "tbcomctl_waits"}
+
+// NewSQLRegistry initialises the SQL registry, creating or migrating the
+// schema if necessary.  errFn is called with the database errors that occur
+// in methods that can't return them, it may be nil.
+func NewSQLRegistry(ctx context.Context, db *sql.DB, ph Placeholder, errFn func(error)) (*SQL, error) {
+	if errFn == nil {
+		errFn = func(error) {}
+	}
+	reg := &SQL{db: db, ph: ph, errFn: errFn}
+	if err := reg.migrate(ctx); err != nil {
+		return nil, fmt.Errorf("registry: migration failed: %w", err)
+	}
+	return reg, nil
+}
+
+// migrate applies all migrations that were not yet applied.  The schema table
+// has a single row, that is locked while the migrations are applied, so that
+// the instances of the bot starting at the same time don't apply them twice.
+func (reg *SQL) migrate(ctx context.Context) error {
+	if _, err := reg.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS tbcomctl_schema (id INTEGER NOT NULL PRIMARY KEY, version INTEGER NOT NULL)`); err != nil {
+		return err
+	}
+	// the primary key allows only one of the concurrent inserts to succeed.
+	if _, err := reg.db.ExecContext(ctx, `INSERT INTO tbcomctl_schema (id, version) VALUES (1, 0)`); err != nil {
+		var n int
+		if qerr := reg.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM tbcomctl_schema WHERE id = 1`).Scan(&n); qerr != nil || n == 0 {
+			return err
+		}
+	}
+	return reg.tx(ctx, func(tx *sql.Tx) error {
+		// the update locks the row until the end of the transaction.
+		if _, err := tx.ExecContext(ctx, `UPDATE tbcomctl_schema SET version = version WHERE id = 1`); err != nil {
+			return err
+		}
+		var version int
+		if err := tx.QueryRowContext(ctx, `SELECT version FROM tbcomctl_schema WHERE id = 1`).Scan(&version); err != nil {
+			return err
+		}
+		if version > len(migrations) {
+			return fmt.Errorf("database schema version %d is newer than supported %d", version, len(migrations))
+		}
+		for i := version; i < len(migrations); i++ {
+			if _, err := tx.ExecContext(ctx, migrations[i]); err != nil {
+				return fmt.Errorf("migration %d: %w", i+1, err)
+			}
+		}
+		_, err := tx.ExecContext(ctx, reg.rebind(`UPDATE tbcomctl_schema SET version = ? WHERE id = 1`), len(migrations))
+		return err
+	})
+}
+
+// rebind replaces the "?" placeholders in the query with the ones supported by
+// the driver.
+func (reg *SQL) rebind(query string) string {
+	if reg.ph == Question {
+		return query
+	}
+	var buf strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			buf.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		buf.WriteRune(r)
+	}
+	return buf.String()
+}
+
+// tx runs fn in the transaction.
+func (reg *SQL) tx(ctx context.Context, fn func(tx *sql.Tx) error) error {
+	tx, err := reg.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	if err := fn(tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit()
+}
+
+// upsert replaces the value for the recipient in the table, that must have
+// the recipient column as a primary key.
+func (reg *SQL) upsert(ctx context.Context, tx *sql.Tx, table, column string, recipient string, value interface{}) error {
+	if _, err := tx.ExecContext(ctx, reg.rebind(`DELETE FROM `+table+` WHERE recipient = ?`), recipient); err != nil {
+		return err
+	}
+	_, err := tx.ExecContext(ctx, reg.rebind(`INSERT INTO `+table+` (recipient, `+column+`, updated_at) VALUES (?, ?, ?)`), recipient, value, timeNow().Unix())
+	return err
+}
+
+// Register inserts the message into the database assigning it a random
+// request id.
+func (reg *SQL) Register(r tb.Recipient, msgID int) uuid.UUID {
+	ctx := context.Background()
+
+	reqID := uuid.Must(uuid.NewUUID())
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		if _, err := tx.ExecContext(ctx, reg.rebind(`DELETE FROM tbcomctl_requests WHERE recipient = ? AND msg_id = ?`), r.Recipient(), msgID); err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, reg.rebind(`INSERT INTO tbcomctl_requests (recipient, msg_id, req_id, updated_at) VALUES (?, ?, ?, ?)`), r.Recipient(), msgID, reqID.String(), timeNow().Unix()); err != nil {
+			return err
+		}
+		return reg.upsert(ctx, tx, "tbcomctl_messages", "msg_id", r.Recipient(), msgID)
+	}); err != nil {
+		reg.errFn(fmt.Errorf("register: %w", err))
+	}
+	return reqID
+}
+
+// RequestFor returns a request id for message ID and a bool. Bool will be true if
+// message is registered and false otherwise.
+func (reg *SQL) RequestFor(r tb.Recipient, msgID int) (uuid.UUID, bool) {
+	var s string
+	if err := reg.db.QueryRow(reg.rebind(`SELECT req_id FROM tbcomctl_requests WHERE recipient = ? AND msg_id = ?`), r.Recipient(), msgID).Scan(&s); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			reg.errFn(fmt.Errorf("request for: %w", err))
+		}
+		return uuid.UUID{}, false
+	}
+	reqID, err := uuid.Parse(s)
+	if err != nil {
+		reg.errFn(fmt.Errorf("request for: %w", err))
+		return uuid.UUID{}, false
+	}
+	return reqID, true
+}
+
+// Unregister removes the request from the database.
+func (reg *SQL) Unregister(r tb.Recipient, msgID int) {
+	if _, err := reg.db.Exec(reg.rebind(`DELETE FROM tbcomctl_requests WHERE recipient = ? AND msg_id = ?`), r.Recipient(), msgID); err != nil {
+		reg.errFn(fmt.Errorf("unregister: %w", err))
+	}
+}
+
+// RequestInfo returns a request ID (or <unknown>) and a time of the request (or
+// zero time) by calling parsing functions of the UUID instance.
+func (reg *SQL) RequestInfo(r tb.Recipient, msgID int) (string, time.Time) {
+	reqID, ok := reg.RequestFor(r, msgID)
+	if !ok {
+		return Unknown, time.Time{}
+	}
+	return reqID.String(), time.Unix(reqID.Time().UnixTime())
+}
+
+// Value returns the Controller Value for the recipient.
+func (reg *SQL) Value(recipient string) (string, bool) {
+	var v string
+	if err := reg.db.QueryRow(reg.rebind(`SELECT value FROM tbcomctl_values WHERE recipient = ?`), recipient).Scan(&v); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			reg.errFn(fmt.Errorf("value: %w", err))
+		}
+		return "", false
+	}
+	return v, true
+}
+
+// SetValue sets the Controller value.
+func (reg *SQL) SetValue(recipient string, value string) {
+	ctx := context.Background()
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		return reg.upsert(ctx, tx, "tbcomctl_values", "value", recipient, value)
+	}); err != nil {
+		reg.errFn(fmt.Errorf("set value: %w", err))
+	}
+}
+
+// OutgoingID returns the controller's outgoing message ID for the user.
+func (reg *SQL) OutgoingID(recipient string) (int, bool) {
+	var id int
+	if err := reg.db.QueryRow(reg.rebind(`SELECT msg_id FROM tbcomctl_messages WHERE recipient = ?`), recipient).Scan(&id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			reg.errFn(fmt.Errorf("outgoing id: %w", err))
+		}
+		return 0, false
+	}
+	return id, true
+}
+
+// Wait places the outbound message ID to the waiting list.
+func (reg *SQL) Wait(r tb.Recipient, outboundID int) {
+	ctx := context.Background()
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		return reg.upsert(ctx, tx, "tbcomctl_waits", "msg_id", r.Recipient(), outboundID)
+	}); err != nil {
+		reg.errFn(fmt.Errorf("wait: %w", err))
+	}
+}
+
+// StopWait removes the recipient from the wait list.
+func (reg *SQL) StopWait(r tb.Recipient) int {
+	ctx := context.Background()
+	var outboundID int
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		err := tx.QueryRowContext(ctx, reg.rebind(`SELECT msg_id FROM tbcomctl_waits WHERE recipient = ?`), r.Recipient()).Scan(&outboundID)
+		if err != nil {
+			if errors.Is(err, sql.ErrNoRows) {
+				return nil
+			}
+			return err
+		}
+		_, err = tx.ExecContext(ctx, reg.rebind(`DELETE FROM tbcomctl_waits WHERE recipient = ?`), r.Recipient())
+		return err
+	}); err != nil {
+		reg.errFn(fmt.Errorf("stop wait: %w", err))
+	}
+	return outboundID
+}
+
+// WaitMsgID returns the ID of the message that was sent to the recipient, for
+// which the control awaits the user response.
+func (reg *SQL) WaitMsgID(r tb.Recipient) int {
+	var id int
+	if err := reg.db.QueryRow(reg.rebind(`SELECT msg_id FROM tbcomctl_waits WHERE recipient = ?`), r.Recipient()).Scan(&id); err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			reg.errFn(fmt.Errorf("wait msg id: %w", err))
+		}
+		return nothing
+	}
+	return id
+}
+
+// IsWaiting returns true if we yet expecting to hear from the recipient.
+func (reg *SQL) IsWaiting(r tb.Recipient) bool {
+	return reg.WaitMsgID(r) != nothing
+}
+
+// Expire removes the entries of the recipients with keys starting with prefix,
+// that were last updated before the given time.  It returns the keys of the
+// recipients, that were awaiting the response, with the prefix trimmed.
+func (reg *SQL) Expire(prefix string, before time.Time) []string {
+	return reg.expire(prefix, before, tables)
+}
+
+// ExpireRequests is the Expire, that keeps the values of the recipients.
+func (reg *SQL) ExpireRequests(prefix string, before time.Time) []string {
+	return reg.expire(prefix, before, requestTables)
+}
+
+// expire removes the expired entries from the tables.
+func (reg *SQL) expire(prefix string, before time.Time, tables []string) []string {
+	ctx := context.Background()
+	const cond = ` WHERE SUBSTR(recipient, 1, ?) = ? AND updated_at < ?`
+
+	var expired []string
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		expired = nil
+		rows, err := tx.QueryContext(ctx, reg.rebind(`SELECT recipient FROM tbcomctl_waits`+cond), len(prefix), prefix, before.Unix())
+		if err != nil {
+			return err
+		}
+		for rows.Next() {
+			var key string
+			if err := rows.Scan(&key); err != nil {
+				rows.Close()
+				return err
+			}
+			expired = append(expired, strings.TrimPrefix(key, prefix))
+		}
+		if err := rows.Close(); err != nil {
+			return err
+		}
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, reg.rebind(`DELETE FROM `+table+cond), len(prefix), prefix, before.Unix()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		reg.errFn(fmt.Errorf("expire: %w", err))
+		return nil
+	}
+	return expired
+}
+
+// Clear removes all entries of the recipient.
+func (reg *SQL) Clear(recipient string) {
+	ctx := context.Background()
+	if err := reg.tx(ctx, func(tx *sql.Tx) error {
+		for _, table := range tables {
+			if _, err := tx.ExecContext(ctx, reg.rebind(`DELETE FROM `+table+` WHERE recipient = ?`), recipient); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		reg.errFn(fmt.Errorf("clear: %w", err))
+	}
+}
@@ -0,0 +1,14 @@
+package registry
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSQL_rebind(t *testing.T) {
+	reg := &SQL{ph: Dollar}
+	assert.Equal(t, "SELECT a FROM b WHERE c = $1 AND d = $2", reg.rebind("SELECT a FROM b WHERE c = ? AND d = ?"))
+	reg.ph = Question
+	assert.Equal(t, "SELECT ?", reg.rebind("SELECT ?"))
+}
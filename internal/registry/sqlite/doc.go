@@ -0,0 +1,4 @@
+// Package sqlite runs the tests of the SQL registry against the SQLite
+// database.  It is the separate module, so that the SQLite driver, that
+// requires cgo, is not the dependency of the library.
+package sqlite
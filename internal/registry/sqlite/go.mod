@@ -0,0 +1,19 @@
+module github.com/rusq/tbcomctl/v4/internal/registry/sqlite
+
+go 1.17
+
+require (
+	github.com/mattn/go-sqlite3 v1.14.16
+	github.com/rusq/tbcomctl/v4 v4.0.0
+	github.com/stretchr/testify v1.8.0
+)
+
+require (
+	github.com/davecgh/go-spew v1.1.1 // indirect
+	github.com/google/uuid v1.3.0 // indirect
+	github.com/pmezard/go-difflib v1.0.0 // indirect
+	gopkg.in/telebot.v3 v3.4.1-beta // indirect
+	gopkg.in/yaml.v3 v3.0.1 // indirect
+)
+
+replace github.com/rusq/tbcomctl/v4 => ../../..
@@ -0,0 +1,94 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	_ "github.com/mattn/go-sqlite3"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+	"github.com/rusq/tbcomctl/v4/internal/registry/registrytest"
+)
+
+// openDB opens the database in the file, the connection waits for the locks
+// held by other connections.
+func openDB(t *testing.T, filename string) *sql.DB {
+	db, err := sql.Open("sqlite3", "file:"+filename+"?_busy_timeout=10000")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+	return db
+}
+
+func TestConformance(t *testing.T) {
+	registrytest.Run(t, func(t *testing.T) registrytest.Registry {
+		db := openDB(t, filepath.Join(t.TempDir(), "registry.db"))
+		reg, err := registry.NewSQLRegistry(context.Background(), db, registry.Question, func(err error) { t.Error(err) })
+		if err != nil {
+			t.Fatal(err)
+		}
+		return reg
+	})
+}
+
+func TestNewSQLRegistry_idempotent(t *testing.T) {
+	db := openDB(t, filepath.Join(t.TempDir(), "registry.db"))
+	ctx := context.Background()
+
+	reg, err := registry.NewSQLRegistry(ctx, db, registry.Question, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reg.SetValue("42", "value")
+	var want int
+	if err := db.QueryRow(`SELECT version FROM tbcomctl_schema`).Scan(&want); err != nil {
+		t.Fatal(err)
+	}
+
+	// opening the same database again must not fail and must retain the data.
+	reg, err = registry.NewSQLRegistry(ctx, db, registry.Question, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	v, ok := reg.Value("42")
+	assert.True(t, ok)
+	assert.Equal(t, "value", v)
+
+	var version int
+	if err := db.QueryRow(`SELECT version FROM tbcomctl_schema`).Scan(&version); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, want, version)
+}
+
+func TestNewSQLRegistry_concurrent(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "registry.db")
+	const instances = 4
+
+	// instances of the bot, starting at the same time.
+	var wg sync.WaitGroup
+	errs := make([]error, instances)
+	for i := 0; i < instances; i++ {
+		db := openDB(t, filename)
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, errs[i] = registry.NewSQLRegistry(context.Background(), db, registry.Question, nil)
+		}(i)
+	}
+	wg.Wait()
+	for i, err := range errs {
+		assert.NoError(t, err, "instance %d", i)
+	}
+
+	var rows int
+	if err := openDB(t, filename).QueryRow(`SELECT COUNT(*) FROM tbcomctl_schema`).Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, 1, rows)
+}
@@ -0,0 +1,328 @@
+package tbcomctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"reflect"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Step types of the form definition.
+const (
+	StepInput    = "input"
+	StepPicklist = "picklist"
+	StepMessage  = "message"
+)
+
+// FormDef is the declarative form definition, that can be loaded from YAML or
+// JSON document with LoadForm.
+//
+// Example:
+//
+//	overwrite: true
+//	steps:
+//	  - type: input
+//	    name: name
+//	    text: What's your name?
+//	    callback: checkName
+//	  - type: picklist
+//	    name: colour
+//	    text: Pick your favourite colour
+//	    values: [red, green, blue, other]
+//	    buttons: [3, 1]
+//	    back: "« Back"
+//	    remove_buttons: true
+//	  - type: message
+//	    name: thanks
+//	    text: Thank you!
+type FormDef struct {
+	Overwrite     bool      `yaml:"overwrite,omitempty" json:"overwrite,omitempty"`
+	RemoveButtons bool      `yaml:"remove_buttons,omitempty" json:"remove_buttons,omitempty"`
+	Steps         []StepDef `yaml:"steps" json:"steps"`
+}
+
+// StepDef is the definition of a single form step.
+type StepDef struct {
+	Type string `yaml:"type" json:"type"` // one of StepInput, StepPicklist or StepMessage.
+	Name string `yaml:"name" json:"name"` // unique controller name.
+	Text string `yaml:"text" json:"text"` // text shown to the user, it is translated with the message catalog, it is not a format string.
+
+	Values   []string `yaml:"values,omitempty" json:"values,omitempty"`     // picklist values.
+	Buttons  []uint   `yaml:"buttons,omitempty" json:"buttons,omitempty"`   // picklist button pattern, see PickOptBtnPattern.
+	Back     string   `yaml:"back,omitempty" json:"back,omitempty"`         // picklist back button text.
+	Callback string   `yaml:"callback,omitempty" json:"callback,omitempty"` // name of the handler in Handlers.
+
+	// Overwrite and RemoveButtons override the form-level flags for the step.
+	Overwrite     *bool `yaml:"overwrite,omitempty" json:"overwrite,omitempty"`
+	RemoveButtons *bool `yaml:"remove_buttons,omitempty" json:"remove_buttons,omitempty"`
+
+	NoReply     bool `yaml:"no_reply,omitempty" json:"no_reply,omitempty"`         // input: do not force reply.
+	PrivateOnly bool `yaml:"private_only,omitempty" json:"private_only,omitempty"` // input, picklist: private chats only.
+}
+
+// Handlers is the registry of callback functions, that the steps of the form
+// definition refer to by name.
+type Handlers map[string]HandleContextFunc
+
+// StepError is the error in the step definition.
+type StepError struct {
+	Index int    // index of the step, zero based
+	Name  string // name of the step, if any
+	Err   error
+}
+
+func (e *StepError) Error() string {
+	return fmt.Sprintf("steps[%d] (%s): %s", e.Index, e.Name, e.Err)
+}
+
+func (e *StepError) Unwrap() error { return e.Err }
+
+// LoadForm reads the YAML or JSON form definition from r and builds the Form.
+// Callbacks are looked up in h.
+func LoadForm(r io.Reader, h Handlers) (*Form, error) {
+	def, err := ParseFormDef(r)
+	if err != nil {
+		return nil, err
+	}
+	return def.Build(h)
+}
+
+// LoadFormFile is a convenience wrapper around LoadForm, that reads the form
+// definition from the file.
+func LoadFormFile(filename string, h Handlers) (*Form, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadForm(f, h)
+}
+
+// ParseFormDef parses the YAML or JSON form definition.  Unknown fields are
+// reported as errors, if the unknown field is in the step definition, the
+// returned error is StepError.
+func ParseFormDef(r io.Reader) (*FormDef, error) {
+	var doc yaml.Node
+	if err := yaml.NewDecoder(r).Decode(&doc); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, errors.New("form definition: empty document")
+		}
+		return nil, fmt.Errorf("form definition: %w", err)
+	}
+	if err := checkFields(doc.Content[0]); err != nil {
+		return nil, err
+	}
+	var def FormDef
+	if err := doc.Decode(&def); err != nil {
+		return nil, fmt.Errorf("form definition: %w", err)
+	}
+	return &def, nil
+}
+
+// checkFields reports the unknown fields of the form definition and its steps.
+func checkFields(root *yaml.Node) error {
+	if err := unknownField(root, reflect.TypeOf(FormDef{})); err != nil {
+		return fmt.Errorf("form definition: %w", err)
+	}
+	steps := mappingValue(root, "steps")
+	if steps == nil || steps.Kind != yaml.SequenceNode {
+		return nil
+	}
+	for i, st := range steps.Content {
+		if err := unknownField(st, reflect.TypeOf(StepDef{})); err != nil {
+			var name string
+			if n := mappingValue(st, "name"); n != nil {
+				name = n.Value
+			}
+			return &StepError{Index: i, Name: name, Err: err}
+		}
+	}
+	return nil
+}
+
+// unknownField returns an error, if the mapping node has the key, that is not
+// the yaml field name of the struct type t.
+func unknownField(node *yaml.Node, t reflect.Type) error {
+	if node.Kind != yaml.MappingNode {
+		return nil // reported by the decoder.
+	}
+	for i := 0; i < len(node.Content); i += 2 {
+		key := node.Content[i]
+		if !hasYAMLField(t, key.Value) {
+			return fmt.Errorf("line %d: field %s not found in type %s", key.Line, key.Value, t)
+		}
+	}
+	return nil
+}
+
+// hasYAMLField returns true if the struct type t has the field with the yaml
+// name.
+func hasYAMLField(t reflect.Type, name string) bool {
+	for i := 0; i < t.NumField(); i++ {
+		if tag := strings.Split(t.Field(i).Tag.Get("yaml"), ",")[0]; tag == name {
+			return true
+		}
+	}
+	return false
+}
+
+// mappingValue returns the value of the key in the mapping node or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// Validate checks the form definition.  All callbacks must be present in h.
+// If the step is invalid, the returned error is StepError.
+func (def *FormDef) Validate(h Handlers) error {
+	if len(def.Steps) == 0 {
+		return errors.New("form definition: no steps")
+	}
+	seen := make(map[string]bool, len(def.Steps))
+	for i, st := range def.Steps {
+		if err := st.validate(h); err != nil {
+			return &StepError{Index: i, Name: st.Name, Err: err}
+		}
+		if seen[st.Name] {
+			return &StepError{Index: i, Name: st.Name, Err: errors.New("duplicate step name")}
+		}
+		seen[st.Name] = true
+	}
+	return nil
+}
+
+// validate checks the step definition.
+func (st *StepDef) validate(h Handlers) error {
+	if st.Name == "" {
+		return errors.New("name is required")
+	}
+	if st.Text == "" {
+		return errors.New("text is required")
+	}
+	switch st.Type {
+	case StepInput:
+		if len(st.Values) > 0 || len(st.Buttons) > 0 || st.Back != "" || st.RemoveButtons != nil {
+			return errors.New("values, buttons, back and remove_buttons are only allowed for picklist")
+		}
+	case StepPicklist:
+		if len(st.Values) == 0 {
+			return errors.New("values are required for picklist")
+		}
+		if st.NoReply {
+			return errors.New("no_reply is only allowed for input")
+		}
+		var sum int
+		for _, n := range st.Buttons {
+			if n == 0 || n > maxButtons {
+				return fmt.Errorf("buttons: number of buttons in a row must be between 1 and %d", maxButtons)
+			}
+			sum += int(n)
+		}
+		// the back button is on the separate row, see NewPicklist.
+		if len(st.Buttons) > 0 && sum < len(st.Values) {
+			return fmt.Errorf("buttons: can't fit %d values in the pattern %v", len(st.Values), st.Buttons)
+		}
+	case StepMessage:
+		if len(st.Values) > 0 || len(st.Buttons) > 0 || st.Back != "" || st.RemoveButtons != nil ||
+			st.Callback != "" || st.NoReply || st.PrivateOnly {
+			return errors.New("message only allows name, text and overwrite")
+		}
+	case "":
+		return errors.New("type is required")
+	default:
+		return fmt.Errorf("unknown type: %q", st.Type)
+	}
+	if st.Callback != "" {
+		if _, ok := h[st.Callback]; !ok {
+			return fmt.Errorf("unknown callback: %q", st.Callback)
+		}
+	}
+	return nil
+}
+
+// Build validates the form definition and creates the Form.
+func (def *FormDef) Build(h Handlers) (*Form, error) {
+	if err := def.Validate(h); err != nil {
+		return nil, err
+	}
+	ctrls := make([]Controller, len(def.Steps))
+	for i := range def.Steps {
+		ctrls[i] = def.Steps[i].controller(h)
+	}
+	fm := NewForm(ctrls...).SetOverwrite(def.Overwrite).SetRemoveButtons(def.RemoveButtons)
+
+	// step flags override the form flags.
+	for i, st := range def.Steps {
+		if st.Overwrite != nil {
+			if ow, ok := ctrls[i].(overwriter); ok {
+				ow.setOverwrite(*st.Overwrite)
+			}
+		}
+		if st.RemoveButtons != nil {
+			if p, ok := ctrls[i].(*Picklist); ok {
+				p.removeButtons = *st.RemoveButtons
+			}
+		}
+	}
+	return fm, nil
+}
+
+// controller creates the controller for the valid step definition.
+func (st *StepDef) controller(h Handlers) Controller {
+	// cc is the common part of the created controller, the text is
+	// translated with its fallback language.
+	var cc *commonCtl
+	text := st.Text
+	textFn := func(_ context.Context, c tb.Context) (string, error) {
+		return translate(c, text, cc.fallbackLang), nil
+	}
+	cb := HandleContextFunc(func(context.Context, tb.Context) error { return nil })
+	if st.Callback != "" {
+		cb = h[st.Callback]
+	}
+
+	switch st.Type {
+	case StepInput:
+		opts := []InputOption{IOptPrivateOnly(st.PrivateOnly)}
+		if st.NoReply {
+			opts = append(opts, IOptNoReply(true))
+		}
+		ip := NewInput(st.Name, &TVC{TextFn: textFn, CBfn: cb}, opts...)
+		cc = &ip.commonCtl
+		return ip
+	case StepPicklist:
+		values := st.Values
+		opts := []PicklistOption{PickOptPrivateOnly(st.PrivateOnly)}
+		if len(st.Buttons) > 0 {
+			opts = append(opts, PickOptBtnPattern(append([]uint(nil), st.Buttons...)))
+		}
+		if st.Back != "" {
+			opts = append(opts, PickOptBtnBack(NewTexter(st.Back)))
+		}
+		p := NewPicklist(st.Name, &TVC{
+			TextFn:   textFn,
+			ValuesFn: func(context.Context, tb.Context) ([]string, error) { return values, nil },
+			CBfn:     cb,
+		}, opts...)
+		cc = &p.commonCtl
+		return p
+	case StepMessage:
+		m := NewMessage(st.Name, &TVC{TextFn: textFn})
+		cc = &m.commonCtl
+		return m
+	}
+	panic("unknown step type: " + st.Type) // can't happen after validation.
+}
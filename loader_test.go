@@ -0,0 +1,148 @@
+package tbcomctl
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+	tb "gopkg.in/telebot.v3"
+)
+
+const testFormYAML = `
+overwrite: true
+steps:
+  - type: input
+    name: name
+    text: What's your name?
+    callback: checkName
+  - type: picklist
+    name: colour
+    text: Pick your favourite colour
+    values: [red, green, blue, other]
+    buttons: [3, 1]
+    back: "« Back"
+    remove_buttons: true
+  - type: message
+    name: thanks
+    text: Thank you!
+    overwrite: false
+`
+
+const testFormJSON = `{
+  "steps": [
+    {"type": "input", "name": "name", "text": "What's your name?", "no_reply": true},
+    {"type": "picklist", "name": "colour", "text": "Pick", "values": ["red", "green"], "callback": "checkName"}
+  ]
+}`
+
+func TestLoadForm(t *testing.T) {
+	var called bool
+	h := Handlers{"checkName": func(context.Context, tb.Context) error { called = true; return nil }}
+
+	t.Run("yaml", func(t *testing.T) {
+		fm, err := LoadForm(strings.NewReader(testFormYAML), h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !assert.Len(t, fm.ctrls, 3) {
+			return
+		}
+		ip := fm.ctrls[0].(*Input)
+		assert.Equal(t, "name", ip.Name())
+		assert.True(t, ip.overwrite)
+		called = false
+		assert.NoError(t, ip.tc.Callback(context.Background(), nil))
+		assert.True(t, called)
+
+		pl := fm.ctrls[1].(*Picklist)
+		assert.Equal(t, []uint{3, 1, 1}, pl.btnPattern, "pattern with the back button row")
+		assert.True(t, pl.backBtn)
+		assert.True(t, pl.removeButtons)
+		assert.True(t, pl.overwrite)
+		values, err := pl.tvc.Values(context.Background(), nil)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"red", "green", "blue", "other"}, values)
+		assert.NoError(t, pl.tvc.Callback(context.Background(), nil), "picklist without callback")
+
+		msg := fm.ctrls[2].(*Message)
+		assert.False(t, msg.overwrite, "step flag overrides the form flag")
+		text, err := msg.txt.Text(context.Background(), newTestContext(&tb.User{LanguageCode: "en"}))
+		assert.NoError(t, err)
+		assert.Equal(t, "Thank you!", text)
+	})
+	t.Run("json", func(t *testing.T) {
+		fm, err := LoadForm(strings.NewReader(testFormJSON), h)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Len(t, fm.ctrls, 2)
+		assert.True(t, fm.ctrls[0].(*Input).noReply)
+	})
+}
+
+func TestLoadForm_text(t *testing.T) {
+	const text = "Get 50% off"
+	must(message.SetString(language.Russian, text, "Скидка 50%"))
+
+	fm, err := LoadForm(strings.NewReader("steps:\n  - {type: message, name: promo, text: \""+text+"\"}\n"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	msg := fm.ctrls[0].(*Message)
+	got, err := msg.txt.Text(context.Background(), newTestContext(&tb.User{LanguageCode: "en"}))
+	assert.NoError(t, err)
+	assert.Equal(t, text, got, "text is not a format string")
+	got, _ = msg.txt.Text(context.Background(), newTestContext(&tb.User{LanguageCode: "ru"}))
+	assert.Equal(t, "Скидка 50%", got)
+
+	msg.fallbackLang = "ru"
+	got, _ = msg.txt.Text(context.Background(), newTestContext(&tb.User{}))
+	assert.Equal(t, "Скидка 50%", got, "control fallback language is used")
+}
+
+func TestLoadForm_errors(t *testing.T) {
+	h := Handlers{"ok": func(context.Context, tb.Context) error { return nil }}
+	tests := []struct {
+		name      string
+		doc       string
+		wantStep  int // -1 if not a step error
+		wantInErr string
+	}{
+		{"empty", ``, -1, "empty document"},
+		{"no steps", `overwrite: true`, -1, "no steps"},
+		{"unknown field", "steps:\n  - {type: input, name: a, text: a}\n  - type: input\n    name: b\n    text: b\n    colour: red\n", 1, "line 6: field colour not found"},
+		{"unknown form field", "steps:\n  - {type: input, name: a, text: a}\ncolour: red\n", -1, "field colour not found"},
+		{"no type", "steps:\n  - name: a\n    text: a\n", 0, "type is required"},
+		{"unknown type", "steps:\n  - {type: input, name: a, text: a}\n  - {type: select, name: b, text: b}\n", 1, "unknown type"},
+		{"no name", "steps:\n  - {type: input, text: a}\n", 0, "name is required"},
+		{"no text", "steps:\n  - {type: input, name: a}\n", 0, "text is required"},
+		{"duplicate", "steps:\n  - {type: input, name: a, text: a}\n  - {type: input, name: a, text: b}\n", 1, "duplicate"},
+		{"no values", "steps:\n  - {type: picklist, name: a, text: a}\n", 0, "values are required"},
+		{"bad pattern", "steps:\n  - {type: picklist, name: a, text: a, values: [x], buttons: [0]}\n", 0, "buttons"},
+		{"small pattern", "steps:\n  - {type: picklist, name: a, text: a, values: [x, y, z], buttons: [1, 1], back: Back}\n", 0, "can't fit 3 values"},
+		{"values on input", "steps:\n  - {type: input, name: a, text: a, values: [x]}\n", 0, "only allowed for picklist"},
+		{"callback on message", "steps:\n  - {type: message, name: a, text: a, callback: ok}\n", 0, "message only allows"},
+		{"unknown callback", "steps:\n  - {type: input, name: a, text: a, callback: nope}\n", 0, "unknown callback"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadForm(strings.NewReader(tt.doc), h)
+			if !assert.Error(t, err) {
+				return
+			}
+			assert.Contains(t, err.Error(), tt.wantInErr)
+			var se *StepError
+			if tt.wantStep < 0 {
+				assert.False(t, errors.As(err, &se))
+				return
+			}
+			if assert.True(t, errors.As(err, &se)) {
+				assert.Equal(t, tt.wantStep, se.Index)
+			}
+		})
+	}
+}
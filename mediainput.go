@@ -0,0 +1,258 @@
+package tbcomctl
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"golang.org/x/text/message"
+	tb "gopkg.in/telebot.v3"
+)
+
+// Media types of the MediaRef.
+const (
+	MediaPhoto    = "photo"
+	MediaDocument = "document"
+	MediaVoice    = "voice"
+	MediaLocation = "location"
+	MediaContact  = "contact"
+)
+
+// MediaRef is the reference to the media sent by the user, the media inputs
+// store it in the registry encoded as JSON.  Use ParseMediaRef to decode the
+// value.  Only the fields relevant to the Type are set.
+type MediaRef struct {
+	Type string `json:"type"`
+
+	// photo, document and voice.
+	FileID   string `json:"file_id,omitempty"`
+	UniqueID string `json:"unique_id,omitempty"`
+	Size     int64  `json:"size,omitempty"`
+	MIME     string `json:"mime,omitempty"`
+	FileName string `json:"file_name,omitempty"`
+	Caption  string `json:"caption,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	Duration int    `json:"duration,omitempty"`
+
+	// location.
+	Lat float32 `json:"lat,omitempty"`
+	Lng float32 `json:"lng,omitempty"`
+
+	// contact.
+	Phone     string `json:"phone,omitempty"`
+	FirstName string `json:"first_name,omitempty"`
+	LastName  string `json:"last_name,omitempty"`
+	UserID    int64  `json:"user_id,omitempty"`
+}
+
+// String returns the short description of the media for the user.
+func (r MediaRef) String() string {
+	switch r.Type {
+	case MediaLocation:
+		return fmt.Sprintf("%.5f, %.5f", r.Lat, r.Lng)
+	case MediaContact:
+		return strings.TrimSpace(r.FirstName + " " + r.LastName + " " + r.Phone)
+	case MediaDocument:
+		if r.FileName != "" {
+			return r.FileName
+		}
+	}
+	return r.Type
+}
+
+// ParseMediaRef decodes the value stored by the media input.
+func ParseMediaRef(s string) (MediaRef, error) {
+	var ref MediaRef
+	if err := json.Unmarshal([]byte(s), &ref); err != nil {
+		return MediaRef{}, fmt.Errorf("invalid media reference: %w", err)
+	}
+	return ref, nil
+}
+
+// MediaEndpoints are the telebot endpoints of the media inputs, see
+// Form.OnMediaMiddleware.  OnMedia receives other media, so that the user is
+// asked to retry.
+var MediaEndpoints = []string{tb.OnPhoto, tb.OnDocument, tb.OnVoice, tb.OnLocation, tb.OnContact, tb.OnMedia}
+
+// IOptMaxSize sets the maximum size of the file in bytes for the photo,
+// document and voice inputs.
+func IOptMaxSize(n int64) InputOption {
+	return func(ip *Input) {
+		ip.maxSize = n
+	}
+}
+
+// IOptMIMETypes sets the accepted MIME types for the document and voice
+// inputs.  The subtype can be a wildcard, i.e. "image/*".
+func IOptMIMETypes(types ...string) InputOption {
+	return func(ip *Input) {
+		ip.mimeTypes = types
+	}
+}
+
+// mediaFunc returns the reference to the media in the message, or false, if
+// the message doesn't have the media of the expected type.
+type mediaFunc func(m *tb.Message) (MediaRef, bool)
+
+// mediaInput creates the Input that expects the media returned by fn.  If the
+// user sends something else, they are asked to retry with the message msg.
+func mediaInput(name string, tc TextCallbacker, msg string, fn mediaFunc, opts ...InputOption) *Input {
+	ip := NewInput(name, tc, opts...)
+	ip.media, ip.resolveFirst = true, true
+	ip.valueResolverFn = func(m *tb.Message) (string, error) {
+		pr := ip.printer(m)
+		ref, ok := fn(m)
+		if !ok {
+			return "", NewInputError(pr.Sprintf(msg))
+		}
+		if err := ip.checkFile(pr, ref); err != nil {
+			return "", err
+		}
+		data, err := json.Marshal(ref)
+		if err != nil {
+			return "", err
+		}
+		return string(data), nil
+	}
+	return ip
+}
+
+// printer returns the Printer for the language of the message sender.
+func (ip *Input) printer(m *tb.Message) *message.Printer {
+	var lang string
+	if m.Sender != nil {
+		lang = m.Sender.LanguageCode
+	}
+	return Printer(lang, ip.fallbackLang)
+}
+
+// checkFile checks the file size and the MIME type limits.
+func (ip *Input) checkFile(pr *message.Printer, ref MediaRef) error {
+	if ip.maxSize > 0 && ip.maxSize < ref.Size {
+		return NewInputError(pr.Sprintf(MsgFileTooBig, formatSize(ip.maxSize)))
+	}
+	if len(ip.mimeTypes) > 0 && !matchMIME(ip.mimeTypes, ref.MIME) {
+		return NewInputError(pr.Sprintf(MsgFileType, strings.Join(ip.mimeTypes, ", ")))
+	}
+	return nil
+}
+
+// matchMIME returns true if mime matches one of the patterns.
+func matchMIME(patterns []string, mime string) bool {
+	mime = strings.ToLower(mime)
+	for _, p := range patterns {
+		p = strings.ToLower(p)
+		if p == mime || (strings.HasSuffix(p, "/*") && strings.HasPrefix(mime, p[:len(p)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// formatSize formats the size in bytes for the user.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit && exp < 2; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.3g %cB", float64(n)/float64(div), "KMG"[exp])
+}
+
+// NewPhotoInput creates the Input that expects a photo.  The stored value is
+// the MediaRef of the largest photo size.
+func NewPhotoInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return mediaInput(name, tc, MsgSendPhoto, func(m *tb.Message) (MediaRef, bool) {
+		if m.Photo == nil {
+			return MediaRef{}, false
+		}
+		return MediaRef{
+			Type:     MediaPhoto,
+			FileID:   m.Photo.FileID,
+			UniqueID: m.Photo.UniqueID,
+			Size:     m.Photo.FileSize,
+			MIME:     "image/jpeg",
+			Caption:  m.Caption,
+			Width:    m.Photo.Width,
+			Height:   m.Photo.Height,
+		}, true
+	}, opts...)
+}
+
+// NewDocumentInput creates the Input that expects a file.
+func NewDocumentInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return mediaInput(name, tc, MsgSendDocument, func(m *tb.Message) (MediaRef, bool) {
+		if m.Document == nil {
+			return MediaRef{}, false
+		}
+		return MediaRef{
+			Type:     MediaDocument,
+			FileID:   m.Document.FileID,
+			UniqueID: m.Document.UniqueID,
+			Size:     m.Document.FileSize,
+			MIME:     m.Document.MIME,
+			FileName: m.Document.FileName,
+			Caption:  m.Caption,
+		}, true
+	}, opts...)
+}
+
+// NewVoiceInput creates the Input that expects a voice message.
+func NewVoiceInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return mediaInput(name, tc, MsgSendVoice, func(m *tb.Message) (MediaRef, bool) {
+		if m.Voice == nil {
+			return MediaRef{}, false
+		}
+		return MediaRef{
+			Type:     MediaVoice,
+			FileID:   m.Voice.FileID,
+			UniqueID: m.Voice.UniqueID,
+			Size:     m.Voice.FileSize,
+			MIME:     m.Voice.MIME,
+			Duration: m.Voice.Duration,
+		}, true
+	}, opts...)
+}
+
+// NewLocationInput creates the Input that expects a location.
+func NewLocationInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return mediaInput(name, tc, MsgSendLocation, func(m *tb.Message) (MediaRef, bool) {
+		if m.Location == nil {
+			return MediaRef{}, false
+		}
+		return MediaRef{Type: MediaLocation, Lat: m.Location.Lat, Lng: m.Location.Lng}, true
+	}, opts...)
+}
+
+// NewContactInput creates the Input that expects a shared contact.
+func NewContactInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return mediaInput(name, tc, MsgSendContact, func(m *tb.Message) (MediaRef, bool) {
+		if m.Contact == nil {
+			return MediaRef{}, false
+		}
+		return MediaRef{
+			Type:      MediaContact,
+			Phone:     m.Contact.PhoneNumber,
+			FirstName: m.Contact.FirstName,
+			LastName:  m.Contact.LastName,
+			UserID:    m.Contact.UserID,
+		}, true
+	}, opts...)
+}
+
+// OnMediaMw returns the middleware for the media handlers, see
+// Form.OnMediaMiddleware.  The text inputs pass the message to fn.
+func (ip *Input) OnMediaMw(fn tb.HandlerFunc) tb.HandlerFunc {
+	if !ip.media {
+		if fn == nil {
+			return func(tb.Context) error { return nil }
+		}
+		return fn
+	}
+	return ip.OnTextMw(fn)
+}
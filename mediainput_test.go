@@ -0,0 +1,133 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestMediaInputs(t *testing.T) {
+	tc := NewStaticTVC("Send it", nil, nil)
+	doc := &tb.Document{File: tb.File{FileID: "doc1", UniqueID: "u1", FileSize: 2048}, MIME: "application/pdf", FileName: "cv.pdf"}
+	tests := []struct {
+		name    string
+		ip      *Input
+		msg     *tb.Message
+		want    MediaRef
+		wantErr string
+	}{
+		{
+			"photo",
+			NewPhotoInput("p", tc),
+			&tb.Message{Photo: &tb.Photo{File: tb.File{FileID: "ph1", UniqueID: "u1", FileSize: 100}, Width: 640, Height: 480}, Caption: "me"},
+			MediaRef{Type: MediaPhoto, FileID: "ph1", UniqueID: "u1", Size: 100, MIME: "image/jpeg", Caption: "me", Width: 640, Height: 480},
+			"",
+		},
+		{"photo expected", NewPhotoInput("p", tc), &tb.Message{Text: "hello"}, MediaRef{}, "📷 Отправьте фотографию."},
+		{
+			"document",
+			NewDocumentInput("d", tc, IOptMaxSize(2048), IOptMIMETypes("application/pdf", "image/*")),
+			&tb.Message{Document: doc},
+			MediaRef{Type: MediaDocument, FileID: "doc1", UniqueID: "u1", Size: 2048, MIME: "application/pdf", FileName: "cv.pdf"},
+			"",
+		},
+		{"document too big", NewDocumentInput("d", tc, IOptMaxSize(1536)), &tb.Message{Document: doc}, MediaRef{}, "❌ Файл слишком большой, максимальный размер: 1.5 KB."},
+		{"document type", NewDocumentInput("d", tc, IOptMIMETypes("image/*")), &tb.Message{Document: doc}, MediaRef{}, "❌ Этот тип файла не принимается, допустимые типы: image/*."},
+		{
+			"voice",
+			NewVoiceInput("v", tc),
+			&tb.Message{Voice: &tb.Voice{File: tb.File{FileID: "v1"}, Duration: 3, MIME: "audio/ogg"}},
+			MediaRef{Type: MediaVoice, FileID: "v1", MIME: "audio/ogg", Duration: 3},
+			"",
+		},
+		{
+			"location",
+			NewLocationInput("l", tc),
+			&tb.Message{Location: &tb.Location{Lat: 51.5, Lng: -0.125}},
+			MediaRef{Type: MediaLocation, Lat: 51.5, Lng: -0.125},
+			"",
+		},
+		{"location expected", NewLocationInput("l", tc), &tb.Message{Photo: &tb.Photo{}}, MediaRef{}, "📍 Отправьте местоположение."},
+		{
+			"contact",
+			NewContactInput("c", tc),
+			&tb.Message{Contact: &tb.Contact{PhoneNumber: "+14155552671", FirstName: "John", UserID: 42}},
+			MediaRef{Type: MediaContact, Phone: "+14155552671", FirstName: "John", UserID: 42},
+			"",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.msg.Sender = &tb.User{LanguageCode: "ru"}
+			got, err := tt.ip.valueResolverFn(tt.msg)
+			if tt.wantErr != "" {
+				var e *Error
+				if assert.ErrorAs(t, err, &e) {
+					assert.Equal(t, tt.wantErr, e.Msg)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			ref, err := ParseMediaRef(got)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, ref)
+		})
+	}
+}
+
+func Test_formatSize(t *testing.T) {
+	assert.Equal(t, "512 B", formatSize(512))
+	assert.Equal(t, "1.5 KB", formatSize(1536))
+	assert.Equal(t, "20 MB", formatSize(20<<20))
+	assert.Equal(t, "2 GB", formatSize(2<<30))
+}
+
+func TestForm_OnMediaMiddleware(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	var done bool
+	name := NewInputText("name", "Name?", nil)
+	photo := NewPhotoInput("photo", NewStaticTVC("Photo?", nil, func(context.Context, tb.Context) error { return nil }))
+	fm := NewForm(name, photo).
+		OnComplete(func(context.Context, tb.Context, map[string]string) error {
+			done = true
+			return nil
+		})
+
+	var other, photos int
+	for _, ep := range MediaEndpoints {
+		b.Handle(ep, fm.OnMediaMiddleware(nil))
+	}
+	b.Handle(tb.OnPhoto, fm.OnMediaMiddleware(func(tb.Context) error {
+		photos++
+		return nil
+	}))
+	b.Handle(tb.OnText, fm.OnTextMiddleware(func(tb.Context) error {
+		other++
+		return nil
+	}))
+	msg := func(m *tb.Message) {
+		m.ID, m.Sender, m.Chat = 2, user, &tb.Chat{ID: user.ID}
+		b.ProcessUpdate(tb.Update{Message: m})
+	}
+
+	// photo is ignored, if the form does not wait for it.
+	msg(&tb.Message{Photo: &tb.Photo{File: tb.File{FileID: "ph1"}}})
+	_, ok := photo.Value(user.Recipient())
+	assert.False(t, ok)
+	assert.Equal(t, 1, photos, "photo is passed to the OnPhoto handler")
+
+	photo.reg.Wait(user, 1)
+	msg(&tb.Message{Photo: &tb.Photo{File: tb.File{FileID: "ph1"}}})
+	assert.True(t, done)
+	ref, err := ParseMediaRef(fm.Data(user)["photo"])
+	assert.NoError(t, err)
+	assert.Equal(t, "ph1", ref.FileID)
+	assert.Equal(t, 1, photos, "awaited photo is not passed to the OnPhoto handler")
+
+	msg(&tb.Message{Text: "hello"})
+	assert.Equal(t, 1, other, "text is passed to the OnText handler")
+}
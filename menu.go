@@ -0,0 +1,283 @@
+package tbcomctl
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"runtime/trace"
+	"strconv"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// Menu is the tree of inline menus, navigated by editing one message in place.
+// The message shows the breadcrumb of the current menu, i.e. "Main › Orders ›
+// Open", the buttons of the child nodes, and the Back and Home buttons.  The
+// leaf nodes run the handler or start the form.
+type Menu struct {
+	commonCtl
+	*buttons
+
+	backBtnTxt Texter
+	homeBtnTxt Texter
+
+	nodes  []*MenuNode // nodes in the depth-first order, root is the first.
+	parent []int       // index of the parent of each node, -1 for the root.
+}
+
+// MenuNode is the node of the Menu tree.  The node that has neither Handler,
+// nor Form, is the submenu.
+type MenuNode struct {
+	// Name identifies the node, it should be unique among the siblings.
+	Name string
+	// Label is shown on the button and in the breadcrumb, it is translated
+	// with the package Printer.
+	Label string
+	// Text is shown below the breadcrumb of the submenu, it is translated with
+	// the package Printer.  It is the plain text, HTML special characters are
+	// escaped.
+	Text string
+	// Children are the nodes of the submenu.
+	Children []*MenuNode
+	// Handler is called when the user presses the leaf node, the button press
+	// is answered before the call.
+	Handler tb.HandlerFunc
+	// Form is started when the user presses the leaf node, see Handler.
+	Form *Form
+	// Visible reports if the node is shown to the user, the node is always
+	// shown, if it's not set.
+	Visible func(ctx context.Context, c tb.Context) bool
+}
+
+// breadcrumbSep separates the labels in the breadcrumb.
+const breadcrumbSep = " › "
+
+type MenuOption func(*Menu)
+
+// MenuOptMaxInlineButtons sets the number of the node buttons in a row.
+func MenuOptMaxInlineButtons(n int) MenuOption {
+	return func(m *Menu) {
+		m.buttons.SetMaxButtons(n)
+	}
+}
+
+// MenuOptBtnBack sets the text of the Back button.
+func MenuOptBtnBack(texter Texter) MenuOption {
+	return func(m *Menu) {
+		m.backBtnTxt = texter
+	}
+}
+
+// MenuOptBtnHome sets the text of the Home button.
+func MenuOptBtnHome(texter Texter) MenuOption {
+	return func(m *Menu) {
+		m.homeBtnTxt = texter
+	}
+}
+
+func MenuOptPrivateOnly(b bool) MenuOption {
+	return func(m *Menu) {
+		optPrivateOnly(b)(&m.commonCtl)
+	}
+}
+
+func MenuOptFallbackLang(lang string) MenuOption {
+	return func(m *Menu) {
+		optFallbackLang(lang)(&m.commonCtl)
+	}
+}
+
+// MenuOptRegistry sets the registry that stores the menu state.
+func MenuOptRegistry(reg Registry) MenuOption {
+	return func(m *Menu) {
+		optRegistry(reg)(&m.commonCtl)
+	}
+}
+
+// MenuOptScope sets the scope of the menu state, see Scope.
+func MenuOptScope(s Scope) MenuOption {
+	return func(m *Menu) {
+		optScope(s)(&m.commonCtl)
+	}
+}
+
+// NewMenu creates a new menu with the root node.  The label of the root is the
+// first item of the breadcrumb.
+func NewMenu(name string, root *MenuNode, opts ...MenuOption) *Menu {
+	m := &Menu{
+		commonCtl:  newCommonCtl(name),
+		buttons:    &buttons{maxButtons: 2},
+		backBtnTxt: NewTexter(MsgBack),
+		homeBtnTxt: NewTexter(MsgHome),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	m.add(root, -1)
+	return m
+}
+
+// add adds the node and its children to the nodes list.
+func (m *Menu) add(n *MenuNode, parent int) {
+	idx := len(m.nodes)
+	m.nodes, m.parent = append(m.nodes, n), append(m.parent, parent)
+	for _, child := range n.Children {
+		m.add(child, idx)
+	}
+}
+
+// Handler shows the root menu.
+func (m *Menu) Handler(c tb.Context) error {
+	if m.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	text, markup, err := m.render(c, 0)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, m.fallbackLang))
+		return fmt.Errorf("error while generating markup for controller: %s: %w", m.name, err)
+	}
+	outbound, err := m.sendOrEdit(c, text, m.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = m.reg.Register(m.recipient(c), outbound.ID)
+	m.logOutgoingMsg(outbound, "menu")
+	return nil
+}
+
+// path returns the indexes of the nodes from the root to the node idx.
+func (m *Menu) path(idx int) []int {
+	var path []int
+	for ; idx >= 0; idx = m.parent[idx] {
+		path = append([]int{idx}, path...)
+	}
+	return path
+}
+
+// visible returns true if the node and all its parents are visible to the
+// user.
+func (m *Menu) visible(c tb.Context, idx int) bool {
+	ctx := WithController(context.Background(), m)
+	for _, i := range m.path(idx) {
+		if fn := m.nodes[i].Visible; fn != nil && !fn(ctx, c) {
+			return false
+		}
+	}
+	return true
+}
+
+// callbackID returns the ID of the menu for the callback data.
+func (m *Menu) callbackID() string {
+	return callbackID("menu", m.name)
+}
+
+// Register registers the menu callback and the callbacks of the forms of the
+// leaf nodes with the dispatcher of the bot, see Picklist.Register.
+func (m *Menu) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(m.callbackID(), m, m.callback)
+	for _, n := range m.nodes {
+		if n.Form != nil {
+			n.Form.Register(b)
+		}
+	}
+}
+
+// version returns the version of the menu tree for the callback data.
+func (m *Menu) version() string {
+	names := make([]string, len(m.nodes))
+	for i, n := range m.nodes {
+		names[i] = strconv.Itoa(m.parent[i]) + "/" + n.Name
+	}
+	return codec.Version(names...)
+}
+
+// render returns the text and the markup of the submenu idx.  Buttons have the
+// index of the node that they open as the payload.
+func (m *Menu) render(c tb.Context, idx int) (string, *tb.ReplyMarkup, error) {
+	pr := PrinterContext(c, m.fallbackLang)
+
+	path := m.path(idx)
+	crumbs := make([]string, len(path))
+	for i, p := range path {
+		crumbs[i] = html.EscapeString(pr.Sprintf(m.nodes[p].Label))
+	}
+	text := strings.Join(crumbs, breadcrumbSep)
+	if node := m.nodes[idx]; node.Text != "" {
+		text += "\n\n" + html.EscapeString(pr.Sprintf(node.Text))
+	}
+
+	var labels, payloads []string
+	for i := idx + 1; i < len(m.nodes); i++ {
+		if m.parent[i] == idx && m.visible(c, i) {
+			labels, payloads = append(labels, pr.Sprintf(m.nodes[i].Label)), append(payloads, strconv.Itoa(i))
+		}
+	}
+	children := len(labels)
+	if idx > 0 {
+		txt, err := m.backBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("backTextFn returned an error: %s", err)
+		}
+		labels, payloads = append(labels, pr.Sprintf(txt)), append(payloads, strconv.Itoa(m.parent[idx]))
+	}
+	if len(path) > 2 {
+		txt, err := m.homeBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("homeTextFn returned an error: %s", err)
+		}
+		labels, payloads = append(labels, pr.Sprintf(txt)), append(payloads, "0")
+	}
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, m.callbackID(), m.version(), labels, payloads)
+	if err != nil {
+		return "", nil, err
+	}
+	rows := OrganizeButtons(btns[:children], m.maxButtons)
+	if len(btns) > children {
+		rows = append(rows, btns[children:])
+	}
+	markup.Inline(rows...)
+	m.Register(bot(c.Bot()))
+	return text, markup, nil
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (m *Menu) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Menu.Callback")
+	defer task.End()
+
+	m.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, m.callbackID(), m.fallbackLang)
+	if !ok {
+		return nil
+	}
+	idx, err := strconv.Atoi(d.Payload)
+	if err != nil || idx < 0 || len(m.nodes) <= idx || d.Version != m.version() || !m.visible(c, idx) {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, m.fallbackLang)
+	}
+	if err := c.Respond(&tb.CallbackResponse{}); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+
+	node := m.nodes[idx]
+	switch {
+	case node.Form != nil:
+		return node.Form.Handler(c)
+	case node.Handler != nil:
+		return node.Handler(c)
+	}
+	text, markup, err := m.render(c, idx)
+	if err != nil {
+		return err
+	}
+	if err := c.Edit(text, m.withMarkup(markup)); err != nil {
+		trace.Log(ctx, "Edit", err.Error())
+	}
+	return nil
+}
@@ -0,0 +1,90 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestMenu(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	admin := &tb.User{ID: 1, LanguageCode: "ru"}
+
+	var opened []string
+	isAdmin := func(_ context.Context, c tb.Context) bool { return c.Sender().ID == admin.ID }
+	menu := NewMenu("main", &MenuNode{Name: "main", Label: "Main", Children: []*MenuNode{
+		{Name: "orders", Label: "Orders", Text: "Pick the <orders>", Children: []*MenuNode{
+			{Name: "open", Label: "Open", Children: []*MenuNode{
+				{Name: "list", Label: "List", Handler: func(c tb.Context) error {
+					opened = append(opened, "list")
+					return nil
+				}},
+			}},
+			{Name: "closed", Label: "Closed", Visible: isAdmin},
+		}},
+		{Name: "feedback", Label: "Feedback", Form: NewForm(NewMessage("thanks", NewTexter("Thanks!")))},
+		{Name: "admin", Label: "Admin", Visible: isAdmin},
+	}})
+
+	render := func(u *tb.User, idx int) (string, *tb.ReplyMarkup) {
+		text, m, err := menu.render(newTestCallback(b, u, ""), idx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return text, m
+	}
+
+	text, m := render(user, 0)
+	assert.Equal(t, "Main", text)
+	assert.Equal(t, [][]string{{"Orders", "Feedback"}}, markupLabels(m), "hidden nodes are not shown")
+	_, am := render(admin, 0)
+	assert.Equal(t, [][]string{{"Orders", "Feedback"}, {"Admin"}}, markupLabels(am))
+
+	// submenu is shown in place.
+	assert.NoError(t, menu.callback(pressButton(t, b, user, m, "Orders")))
+	edit := log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageText", edit.Method)
+	assert.Equal(t, "Main › Orders\n\nPick the &lt;orders&gt;", edit.Params["text"], "text is escaped")
+
+	text, m = render(user, 2)
+	assert.Equal(t, "Main › Orders › Open", text)
+	assert.Equal(t, [][]string{{"List"}, {MsgBack, MsgHome}}, markupLabels(m))
+	_, om := render(admin, 1)
+	assert.Equal(t, [][]string{{"Open", "Closed"}, {"⬅ Назад"}}, markupLabels(om), "no Home button in the first level")
+
+	assert.NoError(t, menu.callback(pressButton(t, b, user, m, "List")))
+	assert.Equal(t, []string{"list"}, opened)
+
+	assert.NoError(t, menu.callback(pressButton(t, b, user, m, MsgHome)))
+	assert.Equal(t, "Main", log.call(len(log.methods()) - 1).Params["text"])
+	assert.NoError(t, menu.callback(pressButton(t, b, user, m, MsgBack)))
+	assert.Contains(t, log.call(len(log.methods()) - 1).Params["text"], "Main › Orders")
+
+	// leaf form is started.
+	_, m = render(user, 0)
+	assert.NoError(t, menu.callback(pressButton(t, b, user, m, "Feedback")))
+	assert.Equal(t, "Thanks!", log.call(len(log.methods()) - 1).Params["text"])
+
+	// the button of the hidden node.
+	press := pressButton(t, b, user, am, "Admin")
+	assert.NoError(t, menu.callback(press))
+	assert.Equal(t, MsgBtnStale, press.lastResponse().Text)
+}
+
+func TestMenu_escape(t *testing.T) {
+	b := newTestBot(t)
+	menu := NewMenu("legal", &MenuNode{Name: "legal", Label: "Legal", Children: []*MenuNode{
+		{Name: "terms", Label: "T&C", Text: "<b> is not a tag", Children: []*MenuNode{
+			{Name: "accept", Label: "Accept & continue"},
+		}},
+	}})
+	text, m, err := menu.render(newTestCallback(b, &tb.User{ID: 42}, ""), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "Legal › T&amp;C\n\n&lt;b&gt; is not a tag", text)
+	assert.Equal(t, [][]string{{"Accept & continue"}, {MsgBack}}, markupLabels(m), "button labels are not escaped")
+}
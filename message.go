@@ -8,7 +8,9 @@ import (
 )
 
 // Message is the controller that sends a message.  It can be used to send a
-// confirmation message at the end of the Form.
+// confirmation message at the end of the Form.  If the Message is not the last
+// controller in the Form, the next controller is called right after the
+// message is sent.
 type Message struct {
 	commonCtl
 	txt  Texter
@@ -23,11 +25,17 @@ var _ Controller = &Message{} // assertion
 func NewMessage(name string, tx Texter, sendOpts ...interface{}) *Message {
 	return &Message{
 		commonCtl: newCommonCtl(name),
-		opts:      sendOpts,
 		txt:       tx,
+		opts:      sendOpts,
 	}
 }
 
+// SetRegistry sets the registry that stores the message state.
+func (m *Message) SetRegistry(reg Registry) *Message {
+	m.setRegistry(reg)
+	return m
+}
+
 // NewMessageText is a convenience wrapper for NewMessage with a fixed text.
 func NewMessageText(name, text string, sendOpts ...interface{}) *Message {
 	return NewMessage(name, NewTexter(text), sendOpts...)
@@ -45,7 +53,7 @@ func (m *Message) Handler(c tb.Context) error {
 	if err != nil {
 		return fmt.Errorf("tbcomctl: message: send error: %s: %w", Userinfo(c.Sender()), err)
 	}
-	m.reg.Register(c.Sender(), outbound.ID)
-	m.reg.Unregister(c.Sender(), outbound.ID)
-	return nil
+	m.reg.Register(m.recipient(c), outbound.ID)
+	m.reg.Unregister(m.recipient(c), outbound.ID)
+	return m.nextHandler(c)
 }
@@ -5,9 +5,12 @@ import (
 	"errors"
 	"fmt"
 	"runtime/trace"
+	"strconv"
 	"strings"
 
 	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
 )
 
 const (
@@ -23,11 +26,49 @@ type Picklist struct {
 	noUpdate      bool
 	msgChoose     bool
 	backBtn       bool
+	cancelBtn     bool
 
-	tvc        TextValueCallbacker
-	backBtnTxt Texter
+	tvc          TextValueCallbacker
+	backBtnTxt   Texter
+	cancelBtnTxt Texter
 
 	btnPattern []uint
+
+	pageSize int // number of values on a page, 0 - no paging.
+}
+
+// page is the page of the values of the paginated picklist.  The page number
+// is carried in the callback data of the buttons, so the picklist doesn't keep
+// the paging state.
+type page struct {
+	num   int // page number, zero based
+	total int // total number of values
+}
+
+// Labels of the page navigation buttons.
+const (
+	pagePrev = "◀"
+	pageNext = "▶"
+)
+
+// PageValuer is the optional interface that the TextValueCallbacker of the
+// paginated Picklist (see PickOptPageSize) may implement to fetch values one
+// page at a time, i.e. from the database.  If it's not implemented, the page is
+// sliced from the Values.
+type PageValuer interface {
+	// PageValues should return at most limit values starting from offset, and
+	// the total number of values.
+	PageValues(ctx context.Context, c tb.Context, offset, limit int) ([]string, int, error)
+}
+
+// PageChoicer is the PageValuer counterpart of ChoiceValuer: it fetches the
+// choices one page at a time.  If the TextValueCallbacker implements it, it is
+// used instead of PageValuer.  The labels are translated with the package
+// Printer.
+type PageChoicer interface {
+	// PageChoices should return at most limit choices starting from offset,
+	// and the total number of choices.
+	PageChoices(ctx context.Context, c tb.Context, offset, limit int) ([]Choice, int, error)
 }
 
 var _ Controller = &Picklist{}
@@ -111,6 +152,24 @@ func PickOptBtnBack(texter Texter) PicklistOption {
 	}
 }
 
+// PickOptBtnCancel adds the cancel button to the picklist.  When pressed, it
+// cancels the form, see Form.Cancel.
+func PickOptBtnCancel(texter Texter) PicklistOption {
+	return func(p *Picklist) {
+		p.cancelBtn = true
+		p.cancelBtnTxt = texter
+	}
+}
+
+// PickOptPageSize enables paging, showing at most n values at a time with the
+// page navigation buttons.  Navigating between pages edits the message in
+// place.  If the button pattern is set, it should fit the full page.
+func PickOptPageSize(n int) PicklistOption {
+	return func(p *Picklist) {
+		p.pageSize = n
+	}
+}
+
 // PickOptDefaultSendOptions allows to set the default send options
 func PickOptDefaultSendOptions(opts *tb.SendOptions) PicklistOption {
 	return func(p *Picklist) {
@@ -118,6 +177,20 @@ func PickOptDefaultSendOptions(opts *tb.SendOptions) PicklistOption {
 	}
 }
 
+// PickOptRegistry sets the registry that stores the picklist state.
+func PickOptRegistry(reg Registry) PicklistOption {
+	return func(p *Picklist) {
+		optRegistry(reg)(&p.commonCtl)
+	}
+}
+
+// PickOptScope sets the scope of the picklist state, see Scope.
+func PickOptScope(s Scope) PicklistOption {
+	return func(p *Picklist) {
+		optScope(s)(&p.commonCtl)
+	}
+}
+
 // NewPicklist creates a new picklist.
 func NewPicklist(name string, tvc TextValueCallbacker, opts ...PicklistOption) *Picklist {
 	p := &Picklist{
@@ -128,8 +201,14 @@ func NewPicklist(name string, tvc TextValueCallbacker, opts ...PicklistOption) *
 	for _, opt := range opts {
 		opt(p)
 	}
-	if p.backBtn {
-		p.btnPattern = append(p.btnPattern, 1)
+	if len(p.btnPattern) > 0 {
+		// back and cancel buttons are on the separate rows.
+		if p.backBtn {
+			p.btnPattern = append(p.btnPattern, 1)
+		}
+		if p.cancelBtn {
+			p.btnPattern = append(p.btnPattern, 1)
+		}
 	}
 	return p
 }
@@ -144,7 +223,7 @@ func (p *Picklist) Handler(c tb.Context) error {
 
 	ctrlCtx := WithController(context.Background(), p)
 
-	values, err := p.tvc.Values(ctrlCtx, c)
+	choices, pg, err := p.choices(ctrlCtx, c, 0)
 	if err != nil {
 		p.processErr(c, err)
 		return err
@@ -153,17 +232,21 @@ func (p *Picklist) Handler(c tb.Context) error {
 	// send message with markup
 	text, err := p.tvc.Text(ctrlCtx, c)
 	if err != nil {
-		c.Send(unexpectedErrorText(c))
+		c.Send(unexpectedErrorText(c, p.fallbackLang))
 		return fmt.Errorf("error while generating text for controller: %s: %w", p.name, err)
 	}
 
-	outbound, err := p.sendOrEdit(c, text, p.withMarkup(p.inlineMarkup(c, values)))
+	markup, err := p.inlineMarkup(c, choices, pg)
+	if err != nil {
+		return fmt.Errorf("error while generating markup for controller: %s: %w", p.name, err)
+	}
+	outbound, err := p.sendOrEdit(c, text, p.withMarkup(markup))
 	if err != nil {
 		return err
 	}
-	_ = p.reg.Register(c.Sender(), outbound.ID)
+	_ = p.reg.Register(p.recipient(c), outbound.ID)
 
-	p.logOutgoingMsg(outbound, fmt.Sprintf("picklist: %q", strings.Join(values, "*")))
+	p.logOutgoingMsg(outbound, fmt.Sprintf("picklist: %q", strings.Join(choiceKeys(choices), "*")))
 
 	return nil
 }
@@ -181,24 +264,53 @@ func (p *Picklist) callback(c tb.Context) error {
 	ctx, task := trace.NewTask(context.Background(), "Picklist.Callback")
 	defer task.End()
 
-	cb := c.Callback()
-	p.logCallback(cb)
-
-	var resp tb.CallbackResponse
+	p.logCallback(c.Callback())
 
-	if p.backBtn {
-		// back button is enabled, check if the callback data contains back button text.
-		txt, err := p.backBtnTxt.Text(ctx, c)
+	d, ok := decodeCallback(c, p.callbackID(), p.fallbackLang)
+	if !ok {
+		return nil
+	}
+	switch {
+	case d.Payload == payloadBack:
+		trace.Log(ctx, "callback", "back is pressed (option)")
+		return p.handleBackButton(ctx, c)
+	case d.Payload == payloadCancel:
+		trace.Log(ctx, "callback", "cancel is pressed")
+		return p.handleCancelButton(ctx, c)
+	case d.Payload == payloadNoop:
+		// the page counter is pressed.
+		return c.Respond(&tb.CallbackResponse{})
+	case strings.HasPrefix(d.Payload, payloadPage):
+		page, err := strconv.Atoi(strings.TrimPrefix(d.Payload, payloadPage))
 		if err != nil {
-			trace.Logf(ctx, "back button", "err=%s", err)
-		}
-		if c.Data() == txt {
-			trace.Log(ctx, "callback", "back is pressed (option)")
-			return p.handleBackButton(ctx, c)
+			return respondStale(c, p.fallbackLang)
 		}
+		return p.showPage(ctx, c, page)
 	}
 
-	err := p.tvc.Callback(WithController(ctx, p), c)
+	// resolving the pressed value, the button is stale if the choices have
+	// changed since the message was sent.
+	idx, err := strconv.Atoi(d.Payload)
+	if err != nil || idx < 0 {
+		trace.Log(ctx, "callback", "invalid payload")
+		return respondStale(c, p.fallbackLang)
+	}
+	num := p.pageOf(idx)
+	choices, _, err := p.choices(WithController(ctx, p), c, num)
+	if err != nil {
+		p.processErr(c, err)
+		return err
+	}
+	if idx -= num * p.pageSize; len(choices) <= idx || d.Version != choicesVersion(choices) {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, p.fallbackLang)
+	}
+	c = withCallbackData(c, choices[idx].Key)
+	cb := c.Callback()
+
+	var resp tb.CallbackResponse
+
+	err = p.tvc.Callback(WithController(ctx, p), c)
 	if err != nil {
 		if errors.Is(err, BackPressed) {
 			// user callback function might return "back button is pressed" as well
@@ -206,12 +318,12 @@ func (p *Picklist) callback(c tb.Context) error {
 			return p.handleBackButton(ctx, c)
 		}
 		if e, ok := err.(*Error); !ok {
-			p.editMsg(ctx, c)
+			p.editMsg(ctx, c, num)
 			pr := PrinterContext(c, p.fallbackLang)
 			if err := c.Respond(&tb.CallbackResponse{Text: pr.Sprintf(MsgUnexpected), ShowAlert: true}); err != nil {
 				trace.Log(ctx, "respond", err.Error())
 			}
-			p.reg.Unregister(c.Sender(), cb.Message.ID)
+			p.reg.Unregister(p.recipient(c), cb.Message.ID)
 			return e
 		} else {
 			switch e.Type {
@@ -228,21 +340,22 @@ func (p *Picklist) callback(c tb.Context) error {
 		resp = tb.CallbackResponse{Text: MsgOK}
 	}
 
-	p.SetValue(c.Sender().Recipient(), cb.Data)
+	p.SetValue(p.recipient(c).Recipient(), cb.Data)
 	// edit message
-	if err := p.editMsg(ctx, c); err != nil {
+	if err := p.editMsg(ctx, c, num); err != nil {
 		lg.Printf("%s: error editing message: %s", caller(0), err)
 	}
 	if err := c.Respond(&resp); err != nil {
 		trace.Log(ctx, "respond", err.Error())
 	}
 	err = p.nextHandler(c)
-	p.reg.Unregister(c.Sender(), cb.Message.ID)
+	p.reg.Unregister(p.recipient(c), cb.Message.ID)
 	return err
 }
 
-// editMsg edits the existing message, returning true, if the message was edited without errors.
-func (p *Picklist) editMsg(ctx context.Context, c tb.Context) error {
+// editMsg edits the existing message, showing the page num, returning true, if
+// the message was edited without errors.
+func (p *Picklist) editMsg(ctx context.Context, c tb.Context, num int) error {
 	text, err := p.tvc.Text(WithController(ctx, p), c)
 	if err != nil {
 		trace.Log(ctx, "editMsg", err.Error())
@@ -263,16 +376,20 @@ func (p *Picklist) editMsg(ctx context.Context, c tb.Context) error {
 		return nil
 	}
 
-	values, err := p.tvc.Values(WithController(ctx, p), c)
+	choices, pg, err := p.choices(WithController(ctx, p), c, num)
 	if err != nil {
 		trace.Log(ctx, "vFn", err.Error())
 		p.processErr(c, err)
 		return err
 	}
 
+	markup, err := p.inlineMarkup(c, choices, pg)
+	if err != nil {
+		return err
+	}
 	if err := c.Edit(
 		p.format(c.Sender(), text),
-		p.commonCtl.withMarkup(p.inlineMarkup(c, values)),
+		p.commonCtl.withMarkup(markup),
 	); err != nil {
 		return err
 	}
@@ -289,23 +406,226 @@ func (p *Picklist) format(u *tb.User, text string) string {
 	return text
 }
 
-// inlineMarkup generates the inline markup for the values.
-func (p *Picklist) inlineMarkup(c tb.Context, values []string) *tb.ReplyMarkup {
+// Callback data payloads of the picklist service buttons, value buttons have
+// the index of the value as the payload.
+const (
+	payloadBack   = "b"
+	payloadCancel = "c"
+	payloadPage   = "p" // followed by the page number.
+)
+
+// callbackID returns the ID of the picklist for the callback data.
+func (p *Picklist) callbackID() string {
+	return callbackID("picklist", p.name)
+}
+
+// Register registers the picklist callback with the dispatcher of the bot, see
+// Dispatcher.  Buttons are registered on the first render, but it should be
+// called before the bot is started, if the picklist state survives the
+// restart.
+func (p *Picklist) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(p.callbackID(), p, p.callback)
+}
+
+// choicesVersion returns the version of the choices for the callback data.
+func choicesVersion(choices []Choice) string {
+	return codec.Version(choiceKeys(choices)...)
+}
+
+// inlineMarkup generates the inline markup for the choices on the page pg.
+func (p *Picklist) inlineMarkup(c tb.Context, choices []Choice, pg page) (*tb.ReplyMarkup, error) {
+	var labels, payloads []string
+	for i, ch := range choices {
+		labels = append(labels, ch.Label)
+		payloads = append(payloads, strconv.Itoa(pg.num*p.pageSize+i))
+	}
+	var extra int // back and cancel buttons
 	if p.backBtn {
 		txt, err := p.backBtnTxt.Text(context.Background(), c)
 		if err != nil {
 			dlg.Println("backTextFn returned an error: %s", err)
 		}
-		values = append(values, txt)
+		labels, payloads = append(labels, txt), append(payloads, payloadBack)
+		extra++
+	}
+	if p.cancelBtn {
+		txt, err := p.cancelBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("cancelTextFn returned an error: %s", err)
+		}
+		labels, payloads = append(labels, txt), append(payloads, payloadCancel)
+		extra++
 	}
+
+	markup := new(tb.ReplyMarkup)
+	id, version := p.callbackID(), choicesVersion(choices)
+	btns, err := encodedButtons(markup, id, version, labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	// values, navigation, back and cancel buttons go on the separate rows,
+	// so that the short last page does not shift the service buttons.
+	var rows []tb.Row
 	if len(p.btnPattern) == 0 {
-		return ButtonMarkup(c, values, p.maxButtons, p.callback)
+		rows = OrganizeButtons(btns[:len(choices)], p.maxButtons)
+	} else if len(choices) > 0 {
+		// the pattern ends with the rows of the back and cancel buttons.
+		pattern := p.btnPattern[:len(p.btnPattern)-extra]
+		if rows, err = organizeButtonsPattern(btns[:len(choices)], pattern); err != nil {
+			return nil, err
+		}
+	}
+	nav, err := p.navRow(markup, version, pg)
+	if err != nil {
+		return nil, err
+	}
+	if nav != nil {
+		rows = append(rows, nav)
+	}
+	for _, btn := range btns[len(choices):] {
+		rows = append(rows, tb.Row{btn})
+	}
+	markup.Inline(rows...)
+	p.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// choices returns the choices for the user.  If paging is enabled, only the
+// choices on the page num are returned.
+func (p *Picklist) choices(ctx context.Context, c tb.Context, num int) ([]Choice, page, error) {
+	if p.pageSize <= 0 {
+		choices, err := p.allChoices(ctx, c)
+		return choices, page{}, err
+	}
+	pg := page{num: num}
+	offset := num * p.pageSize
+
+	var choices []Choice
+	if pc, ok := p.tvc.(PageChoicer); ok {
+		pageChoices, total, err := pc.PageChoices(ctx, c, offset, p.pageSize)
+		if err != nil {
+			return nil, pg, err
+		}
+		choices, pg.total = p.translate(c, pageChoices), total
+	} else if pv, ok := p.tvc.(PageValuer); ok {
+		values, total, err := pv.PageValues(ctx, c, offset, p.pageSize)
+		if err != nil {
+			return nil, pg, err
+		}
+		choices, pg.total = stringChoices(values), total
+	} else {
+		all, err := p.allChoices(ctx, c)
+		if err != nil {
+			return nil, pg, err
+		}
+		pg.total = len(all)
+		if offset > len(all) {
+			offset = len(all)
+		}
+		end := offset + p.pageSize
+		if end > len(all) {
+			end = len(all)
+		}
+		choices = all[offset:end:end]
 	}
-	m, err := ButtonPatternMarkup(c, values, p.btnPattern, p.callback)
+	return choices, pg, nil
+}
+
+// allChoices returns all choices of the picklist.  If tvc implements
+// ChoiceValuer, the labels are translated for the user.
+func (p *Picklist) allChoices(ctx context.Context, c tb.Context) ([]Choice, error) {
+	cv, ok := p.tvc.(ChoiceValuer)
+	if !ok {
+		values, err := p.tvc.Values(ctx, c)
+		if err != nil {
+			return nil, err
+		}
+		return stringChoices(values), nil
+	}
+	choices, err := cv.Choices(ctx, c)
 	if err != nil {
-		panic(err) // TODO handle this more gracefully.
+		return nil, err
 	}
-	return m
+	return p.translate(c, choices), nil
+}
+
+// translate returns the choices with the labels translated for the user.
+func (p *Picklist) translate(c tb.Context, choices []Choice) []Choice {
+	pr := PrinterContext(c, p.fallbackLang)
+	translated := make([]Choice, len(choices))
+	for i, ch := range choices {
+		translated[i] = Choice{Key: ch.Key, Label: pr.Sprintf(ch.Label)}
+	}
+	return translated
+}
+
+// pageOf returns the number of the page with the value index idx.
+func (p *Picklist) pageOf(idx int) int {
+	if p.pageSize <= 0 {
+		return 0
+	}
+	return idx / p.pageSize
+}
+
+// numPages returns the number of pages for total values.
+func (p *Picklist) numPages(total int) int {
+	if total == 0 {
+		return 1
+	}
+	return (total + p.pageSize - 1) / p.pageSize
+}
+
+// navRow returns the page navigation buttons for the page pg, or nil, if paging
+// is disabled or all values fit on a single page.
+func (p *Picklist) navRow(m *tb.ReplyMarkup, version string, pg page) (tb.Row, error) {
+	if p.pageSize <= 0 {
+		return nil, nil
+	}
+	pages := p.numPages(pg.total)
+	if pages <= 1 {
+		return nil, nil
+	}
+	var labels, payloads []string
+	add := func(label string, payload string) {
+		labels = append(labels, label)
+		payloads = append(payloads, payload)
+	}
+	if pg.num > 0 {
+		add(pagePrev, payloadPage+strconv.Itoa(pg.num-1))
+	}
+	add(fmt.Sprintf("%d/%d", pg.num+1, pages), payloadNoop)
+	if pg.num < pages-1 {
+		add(pageNext, payloadPage+strconv.Itoa(pg.num+1))
+	}
+	return encodedButtons(m, p.callbackID(), version, labels, payloads)
+}
+
+// showPage shows the requested page editing the message, it is called when the
+// page navigation button is pressed.
+func (p *Picklist) showPage(ctx context.Context, c tb.Context, num int) error {
+	if num < 0 {
+		return c.Respond(&tb.CallbackResponse{})
+	}
+	choices, pg, err := p.choices(WithController(ctx, p), c, num)
+	if err != nil {
+		p.processErr(c, err)
+		return err
+	}
+	if last := p.numPages(pg.total) - 1; num > last {
+		// the number of values has decreased, showing the last page.
+		if choices, pg, err = p.choices(WithController(ctx, p), c, last); err != nil {
+			p.processErr(c, err)
+			return err
+		}
+	}
+	markup, err := p.inlineMarkup(c, choices, pg)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+		trace.Log(ctx, "EditReplyMarkup", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{})
 }
 
 // processErr logs the error, and if the error handling function errFn is not
@@ -329,16 +649,9 @@ func (p *Picklist) processErr(c tb.Context, err error) {
 // 	return msg
 // }
 
-// nextHandler runs the next handler, if it's available.
-func (p *Picklist) nextHandler(c tb.Context) error {
-	if p.next != nil {
-		return p.next.Handler(c)
-	}
-	return nil
-}
-
 // handleBackButton sends the empty response to telegram to acknowledge button
-// action and runs the previous handler if it's available. It will not return the
+// action and runs the handler of the controller that the user came from, if
+// it's available. It will not return the
 // error on the response.
 func (p *Picklist) handleBackButton(ctx context.Context, c tb.Context) error {
 	if err := c.Respond(&tb.CallbackResponse{}); err != nil {
@@ -346,8 +659,22 @@ func (p *Picklist) handleBackButton(ctx context.Context, c tb.Context) error {
 		trace.Log(ctx, "respond", err.Error())
 	}
 	p.setBackPressed(c)
-	if p.prev != nil {
-		return p.prev.Handler(c)
+	return p.prevHandler(c)
+}
+
+// handleCancelButton acknowledges the button action, removes the buttons and
+// cancels the form, if the picklist is part of it.
+func (p *Picklist) handleCancelButton(ctx context.Context, c tb.Context) error {
+	if err := c.Respond(&tb.CallbackResponse{}); err != nil {
+		lg.Printf("%s: %s", caller(0), err)
+		trace.Log(ctx, "respond", err.Error())
+	}
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+		trace.Log(ctx, "EditReplyMarkup", err.Error())
+	}
+	p.reg.Unregister(p.recipient(c), c.Callback().Message.ID)
+	if p.form != nil {
+		return p.form.Cancel(c)
 	}
 	return nil
 }
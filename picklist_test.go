@@ -0,0 +1,166 @@
+package tbcomctl
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+// testCatalog is the PageValuer that records the requested pages.
+type testCatalog struct {
+	*TVC
+	items    []string
+	requests [][2]int // offset, limit
+}
+
+func newTestCatalog(n int) *testCatalog {
+	cat := &testCatalog{TVC: NewStaticTVC("Pick", nil, nil)}
+	for i := 0; i < n; i++ {
+		cat.items = append(cat.items, fmt.Sprintf("item%d", i))
+	}
+	return cat
+}
+
+func (cat *testCatalog) PageValues(_ context.Context, _ tb.Context, offset, limit int) ([]string, int, error) {
+	cat.requests = append(cat.requests, [2]int{offset, limit})
+	end := offset + limit
+	if end > len(cat.items) {
+		end = len(cat.items)
+	}
+	return cat.items[offset:end], len(cat.items), nil
+}
+
+// testChoiceCatalog is the PageChoicer, the items are the labels of the choices.
+type testChoiceCatalog struct {
+	*testCatalog
+}
+
+func (cat testChoiceCatalog) PageChoices(ctx context.Context, c tb.Context, offset, limit int) ([]Choice, int, error) {
+	items, total, err := cat.PageValues(ctx, c, offset, limit)
+	choices := make([]Choice, len(items))
+	for i, item := range items {
+		choices[i] = Choice{Key: fmt.Sprintf("id%d", offset+i), Label: item}
+	}
+	return choices, total, err
+}
+
+func TestPicklist_paging(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42}
+	ctx := context.Background()
+
+	markup := func(p *Picklist, c tb.Context, num int) *tb.ReplyMarkup {
+		choices, pg, err := p.choices(ctx, c, num)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := p.inlineMarkup(c, choices, pg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	t.Run("page valuer", func(t *testing.T) {
+		cat := newTestCatalog(5)
+		p := NewPicklist("items", cat, PickOptPageSize(2), PickOptMaxInlineButtons(2), PickOptBtnBack(NewTexter("Back")))
+		c := newTestCallback(b, user, "")
+
+		m := markup(p, c, 0)
+		assert.Equal(t, [][]string{{"item0", "item1"}, {"1/3", pageNext}, {"Back"}}, markupLabels(m))
+		assert.NoError(t, p.callback(pressButton(t, b, user, m, pageNext)))
+		edit := log.call(len(log.methods()) - 1)
+		assert.Equal(t, "editMessageReplyMarkup", edit.Method)
+		assert.Contains(t, edit.Params["reply_markup"], `"text":"item2"`)
+		m = markup(p, c, 1)
+		assert.Equal(t, [][]string{{"item2", "item3"}, {pagePrev, "2/3", pageNext}, {"Back"}}, markupLabels(m))
+		assert.Equal(t, [][2]int{{0, 2}, {2, 2}, {2, 2}}, cat.requests, "values are fetched per page")
+
+		// the page counter does nothing.
+		n := len(log.methods())
+		assert.NoError(t, p.callback(pressButton(t, b, user, m, "2/3")))
+		assert.Len(t, log.methods(), n)
+
+		var got string
+		cat.CBfn = func(_ context.Context, c tb.Context) error { got = c.Data(); return nil }
+		assert.NoError(t, p.callback(pressButton(t, b, user, m, "item3")))
+		assert.Equal(t, "item3", got)
+		assert.Equal(t, [2]int{2, 2}, cat.requests[len(cat.requests)-1], "the page is taken from the button")
+	})
+	t.Run("page choicer", func(t *testing.T) {
+		cat := testChoiceCatalog{newTestCatalog(3)}
+		cat.items[2] = MsgYes
+		p := NewPicklist("choices", cat, PickOptPageSize(2))
+		ru := &tb.User{ID: 42, LanguageCode: "ru"}
+		c := newTestCallback(b, ru, "")
+
+		m := markup(p, c, 1)
+		assert.Equal(t, [][]string{{"Да"}, {pagePrev, "2/2"}}, markupLabels(m), "labels are translated")
+
+		var got string
+		cat.CBfn = func(_ context.Context, c tb.Context) error { got = c.Data(); return nil }
+		assert.NoError(t, p.callback(pressButton(t, b, ru, m, "Да")))
+		assert.Equal(t, "id2", got)
+	})
+	t.Run("sliced values", func(t *testing.T) {
+		p := NewPicklist("sliced", NewStaticTVC("Pick", []string{"a", "b", "c"}, nil), PickOptPageSize(2))
+		c := newTestCallback(b, user, "")
+		assert.Equal(t, [][]string{{"c"}, {pagePrev, "2/2"}}, markupLabels(markup(p, c, 1)))
+
+		// page beyond the last one shows the last page.
+		assert.NoError(t, p.showPage(ctx, pressButton(t, b, user, markup(p, c, 0), pageNext), 5))
+		assert.Contains(t, log.call(len(log.methods()) - 1).Params["reply_markup"], `"text":"2/2"`)
+	})
+	t.Run("pattern", func(t *testing.T) {
+		p := NewPicklist("pattern", NewStaticTVC("Pick", []string{"a", "b", "c", "d", "e"}, nil),
+			PickOptPageSize(3), PickOptBtnPattern([]uint{1, 2}), PickOptBtnBack(NewTexter("Back")), PickOptBtnCancel(NewTexter("Cancel")))
+		c := newTestCallback(b, user, "")
+		assert.Equal(t, [][]string{{"a"}, {"b", "c"}, {"1/2", pageNext}, {"Back"}, {"Cancel"}}, markupLabels(markup(p, c, 0)))
+		assert.Equal(t, [][]string{{"d"}, {"e"}, {pagePrev, "2/2"}, {"Back"}, {"Cancel"}}, markupLabels(markup(p, c, 1)), "partial page")
+	})
+	t.Run("single page", func(t *testing.T) {
+		p := NewPicklist("single", NewStaticTVC("Pick", []string{"a", "b"}, nil), PickOptPageSize(2))
+		c := newTestCallback(b, user, "")
+		assert.Equal(t, [][]string{{"a", "b"}}, markupLabels(markup(p, c, 0)), "no navigation")
+	})
+}
+
+func TestPicklist_choices(t *testing.T) {
+	b := newTestBot(t)
+	var got string
+	tvc := NewStaticChoiceTVC("Agreed?", []Choice{
+		{Key: "true", Label: MsgYes},
+		{Key: "false", Label: MsgNo},
+		{Key: "maybe", Label: MsgNo},
+	}, func(ctx context.Context, c tb.Context) error {
+		got = c.Data()
+		return nil
+	})
+	p := NewPicklist("agreed", tvc)
+	user := &tb.User{ID: 42, LanguageCode: "ru"}
+	c := newTestCallback(b, user, "")
+	choices, pg, err := p.choices(context.Background(), c, 0)
+	assert.NoError(t, err)
+
+	m, err := p.inlineMarkup(c, choices, pg)
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"Да", "Нет", "Нет"}}, markupLabels(m), "labels are translated")
+	btns := m.InlineKeyboard[0]
+	assert.NotEqual(t, btns[1].Data, btns[2].Data, "equal labels do not collide")
+
+	assert.NoError(t, p.callback(newTestCallback(b, user, btns[0].Data)))
+	assert.Equal(t, "true", got, "key is passed to the callback")
+	val, _ := p.Value(user.Recipient())
+	assert.Equal(t, "true", val, "key is stored")
+
+	// choices changed since the message was sent.
+	tvc.(*choiceTVC).choices = []Choice{{Key: "maybe", Label: "Maybe"}, {Key: "true", Label: MsgYes}}
+	got = ""
+	c = newTestCallback(b, user, btns[0].Data)
+	assert.NoError(t, p.callback(c))
+	assert.Equal(t, "", got, "stale button is rejected")
+	assert.Equal(t, "⌛ Эта кнопка устарела, попробуйте еще раз.", c.lastResponse().Text)
+}
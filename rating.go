@@ -40,6 +40,33 @@ func RBOptShowPostRating(b bool) RBOption {
 	}
 }
 
+// RBOptName sets the name of the rating, the default name is "rating".  The
+// name is a part of the callback data of the buttons, so each Rating on the
+// bot must have a unique name.  Changing the name of the existing Rating makes
+// the buttons of the published posts stale.
+func RBOptName(name string) RBOption {
+	return func(rb *Rating) {
+		if name == "" {
+			panic("rating name can't be empty")
+		}
+		rb.name = name
+	}
+}
+
+// RBOptScope sets the scope of the rating state, see Scope.
+func RBOptScope(s Scope) RBOption {
+	return func(rb *Rating) {
+		optScope(s)(&rb.commonCtl)
+	}
+}
+
+// RBOptRegistry sets the registry that stores the rating state.
+func RBOptRegistry(reg Registry) RBOption {
+	return func(rb *Rating) {
+		optRegistry(reg)(&rb.commonCtl)
+	}
+}
+
 type RatingType int
 
 func NewRating(fn RatingFunc, opts ...RBOption) *Rating {
@@ -53,22 +80,33 @@ func NewRating(fn RatingFunc, opts ...RBOption) *Rating {
 	return rb
 }
 
+// rbPrefix is the prefix of the rating callback ID.
+const rbPrefix = "rating"
+
 func (rb *Rating) Markup(b *tb.Bot, btns [2]Button) *tb.ReplyMarkup {
-	const rbPrefix = "rating"
 	return rb.multibuttonMarkup(b, btns[:], rb.hasCounter, rbPrefix, rb.callback)
 }
 
+// Register registers the rating callback with the dispatcher of the bot.  It
+// should be called before the bot is started, so that the votes on the posts
+// published before the restart are counted.
+func (rb *Rating) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(callbackID(rbPrefix, rb.name), &rb.commonCtl, rb.callback)
+}
+
 var ErrAlreadyVoted = errors.New("already voted")
 
 func (rb *Rating) callback(c tb.Context) error {
 	respErr := tb.CallbackResponse{Text: MsgUnexpected}
-	data := c.Data()
 
-	btnIdx, err := strconv.Atoi(data)
-	if err != nil {
-		lg.Printf("failed to get the button index from data: %s", data)
-		c.Respond(&respErr)
-		return err
+	d, ok := decodeCallback(c, callbackID(rbPrefix, rb.name), rb.fallbackLang)
+	if !ok {
+		return nil
+	}
+	btnIdx, err := strconv.Atoi(d.Payload)
+	if err != nil || d.Version != multibuttonVersion {
+		lg.Printf("failed to get the button index from data: %s", c.Data())
+		return respondStale(c, rb.fallbackLang)
 	}
 
 	// get existing value for the post
@@ -0,0 +1,233 @@
+package tbcomctl
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+)
+
+// Registry is the interface for the storage of the controller state: the
+// values entered by users, the outgoing message IDs, and the messages for which
+// the controller awaits the user response.  By default, each controller uses
+// its own in-memory registry, which is lost when the bot restarts.  Caller may
+// supply a persistent implementation, i.e. the one returned by
+// NewFileRegistry, or a custom one.
+//
+// One registry may be shared between several controllers, i.e. within a Form:
+// controllers prefix the keys with their name, so the controller names must be
+// unique.
+type Registry interface {
+	// Register inserts the message into the registry assigning it a random
+	// request id, and records it as the last outgoing message for the
+	// recipient.
+	Register(r tb.Recipient, msgID int) uuid.UUID
+	// Unregister removes the request from the registry.
+	Unregister(r tb.Recipient, msgID int)
+	// RequestInfo returns the request ID and the time of the request for the
+	// message.  If the message is not registered, it should return
+	// "[unknown]" and a zero time.
+	RequestInfo(r tb.Recipient, msgID int) (string, time.Time)
+	// Value returns the value for the recipient.
+	Value(recipient string) (string, bool)
+	// SetValue sets the value for the recipient.
+	SetValue(recipient string, value string)
+	// OutgoingID returns the ID of the last outgoing message for the
+	// recipient.
+	OutgoingID(recipient string) (int, bool)
+	// Wait places the outbound message ID to the waiting list, meaning that
+	// the user response is expected.
+	Wait(r tb.Recipient, outboundID int)
+	// StopWait removes the recipient from the waiting list and returns the
+	// outbound message ID.
+	StopWait(r tb.Recipient) int
+	// WaitMsgID returns the ID of the message that awaits the user response.
+	WaitMsgID(r tb.Recipient) int
+	// IsWaiting returns true if the response from the recipient is expected.
+	IsWaiting(r tb.Recipient) bool
+	// Clear removes all entries of the recipient.
+	Clear(recipient string)
+}
+
+// Expirer is the interface that a Registry may implement to support the expiry
+// of the stale entries.  All built-in registries implement it.
+type Expirer interface {
+	// Expire removes the entries of the recipients with the keys starting with
+	// prefix, that were last updated before the given time.  It must return
+	// the keys of the recipients (with prefix trimmed) that were awaiting for
+	// the response.
+	Expire(prefix string, before time.Time) []string
+}
+
+// requestExpirer is the Expirer, that can keep the values of the recipients.
+type requestExpirer interface {
+	// ExpireRequests is the Expire, that removes only the requests, the
+	// outgoing messages and the waits, the values are kept.
+	ExpireRequests(prefix string, before time.Time) []string
+}
+
+// interface assertions
+var (
+	_ Registry = &registry.Memory{}
+	_ Registry = &registry.File{}
+	_ Registry = &registry.SQL{}
+	_ Expirer  = &registry.Memory{}
+	_ Expirer  = &registry.File{}
+	_ Expirer  = &registry.SQL{}
+	_ Expirer  = &nsRegistry{}
+
+	_ requestExpirer = &registry.Memory{}
+	_ requestExpirer = &registry.File{}
+	_ requestExpirer = &registry.SQL{}
+	_ requestExpirer = &nsRegistry{}
+)
+
+// NewMemRegistry returns the new in-memory Registry.  This is the registry
+// that controllers use by default.
+func NewMemRegistry() Registry {
+	return registry.NewMemRegistry()
+}
+
+// NewFileRegistry returns the in-memory Registry that persists its state to
+// the filename on every change.  If the file exists, the state is restored from
+// it, so that users are able to continue the half-finished forms after the bot
+// restart.  Errors that occur while saving the state are logged.
+func NewFileRegistry(filename string) (Registry, error) {
+	return registry.NewFileRegistry(filename, func(err error) {
+		lg.Printf("registry: %s", err)
+	})
+}
+
+// SQLPlaceholder is the style of the query parameter placeholders that the
+// database driver supports.
+type SQLPlaceholder int
+
+const (
+	SQLQuestion SQLPlaceholder = iota // "?" placeholders (SQLite, MySQL).
+	SQLDollar                         // "$1" placeholders (PostgreSQL).
+)
+
+// NewSQLRegistry returns the Registry that stores the state in the database.
+// It creates the schema or migrates it to the latest version, if necessary.
+// The database can be shared between several instances of the bot, i.e. ones
+// running behind the same webhook.  Errors that occur while accessing the
+// database are logged.
+func NewSQLRegistry(ctx context.Context, db *sql.DB, ph SQLPlaceholder) (Registry, error) {
+	return registry.NewSQLRegistry(ctx, db, registry.Placeholder(ph), func(err error) {
+		lg.Printf("registry: %s", err)
+	})
+}
+
+// registrar is the interface for the controllers that allow to replace the
+// registry.
+type registrar interface {
+	setRegistry(reg Registry)
+}
+
+// nsRegistry is the Registry that prefixes all recipient keys with the
+// namespace, so that a single registry can be shared between the controllers.
+type nsRegistry struct {
+	ns  string
+	reg Registry
+}
+
+// nsEscaper escapes the separators in the namespace, so that the namespace of
+// one controller is never the prefix of the namespace of the other, i.e. the
+// keys of "a" don't match the keys of "a:b".
+var nsEscaper = strings.NewReplacer(`\`, `\\`, `:`, `\:`)
+
+// namespaced returns the reg wrapped into the namespace ns.
+func namespaced(ns string, reg Registry) Registry {
+	return &nsRegistry{ns: nsEscaper.Replace(ns) + ":", reg: reg}
+}
+
+// formNamespaced returns the reg wrapped into the namespace of the form with
+// the first controller name.  The namespace starts with the separator, which
+// the escaped controller names can't start with, so the form keys never match
+// the keys of the controllers.
+func formNamespaced(name string, reg Registry) Registry {
+	return &nsRegistry{ns: ":" + nsEscaper.Replace(name) + ":", reg: reg}
+}
+
+// nsRecipient is the namespaced recipient.
+type nsRecipient string
+
+func (r nsRecipient) Recipient() string { return string(r) }
+
+func (n *nsRegistry) key(recipient string) string {
+	return n.ns + recipient
+}
+
+func (n *nsRegistry) rcpt(r tb.Recipient) tb.Recipient {
+	return nsRecipient(n.key(r.Recipient()))
+}
+
+func (n *nsRegistry) Register(r tb.Recipient, msgID int) uuid.UUID {
+	return n.reg.Register(n.rcpt(r), msgID)
+}
+
+func (n *nsRegistry) Unregister(r tb.Recipient, msgID int) {
+	n.reg.Unregister(n.rcpt(r), msgID)
+}
+
+func (n *nsRegistry) RequestInfo(r tb.Recipient, msgID int) (string, time.Time) {
+	return n.reg.RequestInfo(n.rcpt(r), msgID)
+}
+
+func (n *nsRegistry) Value(recipient string) (string, bool) {
+	return n.reg.Value(n.key(recipient))
+}
+
+func (n *nsRegistry) SetValue(recipient string, value string) {
+	n.reg.SetValue(n.key(recipient), value)
+}
+
+func (n *nsRegistry) OutgoingID(recipient string) (int, bool) {
+	return n.reg.OutgoingID(n.key(recipient))
+}
+
+func (n *nsRegistry) Wait(r tb.Recipient, outboundID int) {
+	n.reg.Wait(n.rcpt(r), outboundID)
+}
+
+func (n *nsRegistry) StopWait(r tb.Recipient) int {
+	return n.reg.StopWait(n.rcpt(r))
+}
+
+func (n *nsRegistry) WaitMsgID(r tb.Recipient) int {
+	return n.reg.WaitMsgID(n.rcpt(r))
+}
+
+func (n *nsRegistry) IsWaiting(r tb.Recipient) bool {
+	return n.reg.IsWaiting(n.rcpt(r))
+}
+
+func (n *nsRegistry) Clear(recipient string) {
+	n.reg.Clear(n.key(recipient))
+}
+
+// Expire calls the Expire of the underlying registry within the namespace.  If
+// the underlying registry does not implement Expirer, it does nothing.
+func (n *nsRegistry) Expire(prefix string, before time.Time) []string {
+	e, ok := n.reg.(Expirer)
+	if !ok {
+		return nil
+	}
+	return e.Expire(n.key(prefix), before)
+}
+
+// ExpireRequests calls the ExpireRequests of the underlying registry within
+// the namespace.  If the underlying registry does not implement it, it does
+// nothing.
+func (n *nsRegistry) ExpireRequests(prefix string, before time.Time) []string {
+	e, ok := n.reg.(requestExpirer)
+	if !ok {
+		return nil
+	}
+	return e.ExpireRequests(n.key(prefix), before)
+}
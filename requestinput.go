@@ -0,0 +1,94 @@
+package tbcomctl
+
+import (
+	tb "gopkg.in/telebot.v3"
+)
+
+// IOptRequestButton sets the label of the request button of the
+// request-contact and request-location inputs.  The label is translated with
+// the package Printer.
+func IOptRequestButton(label string) InputOption {
+	return func(ip *Input) {
+		ip.requestLabel = label
+	}
+}
+
+// IOptSkip adds the skip button with the label to the reply keyboard of the
+// request-contact and request-location inputs.  If the user presses it, the
+// empty value is stored.  The label is translated with the package Printer,
+// if it is empty, MsgSkip is used.
+func IOptSkip(label string) InputOption {
+	return func(ip *Input) {
+		if label == "" {
+			label = MsgSkip
+		}
+		ip.skipLabel = label
+	}
+}
+
+// NewRequestContactInput creates the contact input, that shows the reply
+// keyboard with the button, that shares the contact of the user.  Only the
+// contact of the sender is accepted.  The keyboard is removed once the contact
+// is received.  The request buttons work only in the private chats, so
+// IOptGroup is ignored: the prompt is sent to the private chat of the user.
+func NewRequestContactInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	ip := NewContactInput(name, tc, append([]InputOption{IOptRequestButton(MsgShareContact)}, opts...)...)
+	resolve := ip.valueResolverFn
+	ip.request, ip.group = MediaContact, false
+	ip.valueResolverFn = ip.skipResolver(func(m *tb.Message) (string, error) {
+		if m.Contact != nil && (m.Sender == nil || m.Contact.UserID != m.Sender.ID) {
+			return "", NewInputError(ip.printer(m).Sprintf(MsgNotOwnContact))
+		}
+		return resolve(m)
+	})
+	return ip
+}
+
+// NewRequestLocationInput creates the location input, that shows the reply
+// keyboard with the button, that shares the location of the user.  The
+// keyboard is removed once the location is received.  IOptGroup is ignored,
+// see NewRequestContactInput.
+func NewRequestLocationInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	ip := NewLocationInput(name, tc, append([]InputOption{IOptRequestButton(MsgShareLocation)}, opts...)...)
+	ip.request, ip.group = MediaLocation, false
+	ip.valueResolverFn = ip.skipResolver(ip.valueResolverFn)
+	return ip
+}
+
+// skipResolver returns the resolver that returns an empty value, if the user
+// pressed the skip button, or calls fn otherwise.
+func (ip *Input) skipResolver(fn ValueResolver) ValueResolver {
+	return func(m *tb.Message) (string, error) {
+		if ip.skipLabel != "" && m.Text == ip.printer(m).Sprintf(ip.skipLabel) {
+			return "", nil
+		}
+		return fn(m)
+	}
+}
+
+// requestMarkup returns the reply keyboard with the request button and the
+// skip button, if it's enabled.
+func (ip *Input) requestMarkup(c tb.Context) *tb.ReplyMarkup {
+	pr := PrinterContext(c, ip.fallbackLang)
+	m := &tb.ReplyMarkup{ResizeKeyboard: true, OneTimeKeyboard: true}
+	var btn tb.Btn
+	if ip.request == MediaContact {
+		btn = m.Contact(pr.Sprintf(ip.requestLabel))
+	} else {
+		btn = m.Location(pr.Sprintf(ip.requestLabel))
+	}
+	rows := []tb.Row{{btn}}
+	if ip.skipLabel != "" {
+		rows = append(rows, tb.Row{m.Text(pr.Sprintf(ip.skipLabel))})
+	}
+	m.Reply(rows...)
+	return m
+}
+
+// removeKeyboard removes the reply keyboard of the request input in the private
+// chat of the user.
+func (ip *Input) removeKeyboard(c tb.Context) error {
+	pr := PrinterContext(c, ip.fallbackLang)
+	_, err := c.Bot().Send(c.Sender(), pr.Sprintf(MsgOK), &tb.ReplyMarkup{RemoveKeyboard: true})
+	return err
+}
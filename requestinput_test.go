@@ -0,0 +1,94 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestInput_requestMarkup(t *testing.T) {
+	c := newTestContext(&tb.User{ID: 42, LanguageCode: "ru"})
+
+	m := NewRequestContactInput("phone", NewTexter("Phone?").(TextCallbacker), IOptSkip("")).requestMarkup(c)
+	assert.True(t, m.OneTimeKeyboard)
+	if assert.Len(t, m.ReplyKeyboard, 2) {
+		assert.Equal(t, "📱 Поделиться контактом", m.ReplyKeyboard[0][0].Text)
+		assert.True(t, m.ReplyKeyboard[0][0].Contact)
+		assert.Equal(t, "Пропустить", m.ReplyKeyboard[1][0].Text)
+	}
+
+	m = NewRequestLocationInput("where", NewTexter("Where?").(TextCallbacker), IOptRequestButton("Here")).requestMarkup(c)
+	if assert.Len(t, m.ReplyKeyboard, 1) {
+		assert.Equal(t, "Here", m.ReplyKeyboard[0][0].Text)
+		assert.True(t, m.ReplyKeyboard[0][0].Location)
+	}
+}
+
+func TestRequestContactInput(t *testing.T) {
+	user := &tb.User{ID: 42, LanguageCode: "ru"}
+
+	setup := func(t *testing.T) (*tb.Bot, *Input, *bool) {
+		b := newTestBot(t)
+		ip := NewRequestContactInput("phone", NewStaticTVC("Phone?", nil, func(context.Context, tb.Context) error { return nil }), IOptSkip(""))
+		var done bool
+		fm := NewForm(ip).
+			OnComplete(func(context.Context, tb.Context, map[string]string) error {
+				done = true
+				return nil
+			})
+		for _, ep := range MediaEndpoints {
+			b.Handle(ep, fm.OnMediaMiddleware(nil))
+		}
+		b.Handle(tb.OnText, fm.OnTextMiddleware(nil))
+		ip.reg.Wait(user, 1)
+		return b, ip, &done
+	}
+	send := func(b *tb.Bot, m *tb.Message) {
+		m.ID, m.Sender, m.Chat = 2, user, &tb.Chat{ID: user.ID}
+		b.ProcessUpdate(tb.Update{Message: m})
+	}
+
+	t.Run("own contact", func(t *testing.T) {
+		b, ip, done := setup(t)
+		send(b, &tb.Message{Contact: &tb.Contact{PhoneNumber: "+79991234567", UserID: 42}})
+		assert.True(t, *done)
+		ref, err := ParseMediaRef(ip.Form().Data(user)["phone"])
+		assert.NoError(t, err)
+		assert.Equal(t, "+79991234567", ref.Phone)
+	})
+	t.Run("other contact", func(t *testing.T) {
+		ip := NewRequestContactInput("phone", NewTexter("Phone?").(TextCallbacker))
+		_, err := ip.valueResolverFn(&tb.Message{Sender: user, Contact: &tb.Contact{PhoneNumber: "+79991234567", UserID: 43}})
+		var e *Error
+		if assert.ErrorAs(t, err, &e) {
+			assert.Equal(t, "❌ Поделитесь своим контактом с помощью кнопки ниже.", e.Msg)
+		}
+	})
+	t.Run("group", func(t *testing.T) {
+		b, log := newRecordingBot(t)
+		ip := NewRequestContactInput("phone", NewStaticTVC("Phone?", nil, func(context.Context, tb.Context) error { return nil }), IOptGroup(true))
+		b.Handle(tb.OnContact, ip.OnMediaMw(nil))
+		group := &tb.Chat{ID: -100, Type: tb.ChatGroup}
+		assert.NoError(t, ip.Handler(b.NewContext(tb.Update{Message: &tb.Message{ID: 10, Sender: user, Chat: group, Text: "/phone"}})))
+		call := log.call(0)
+		assert.Equal(t, "42", call.Params["chat_id"], "prompt is sent to the private chat")
+		assert.Empty(t, call.Params["reply_to_message_id"])
+		assert.True(t, ip.reg.IsWaiting(user))
+
+		b.ProcessUpdate(tb.Update{Message: &tb.Message{ID: 11, Sender: user, Chat: &tb.Chat{ID: 42, Type: tb.ChatPrivate}, Contact: &tb.Contact{PhoneNumber: "+79991234567", UserID: 42}}})
+		assert.False(t, ip.reg.IsWaiting(user))
+		call = log.call(len(log.methods()) - 1)
+		assert.Equal(t, "42", call.Params["chat_id"])
+		assert.JSONEq(t, `{"remove_keyboard":true}`, call.Params["reply_markup"])
+	})
+	t.Run("skip", func(t *testing.T) {
+		b, ip, done := setup(t)
+		send(b, &tb.Message{Text: "Пропустить"})
+		assert.True(t, *done)
+		val, ok := ip.Value(user.Recipient())
+		assert.True(t, ok)
+		assert.Equal(t, "", val)
+	})
+}
@@ -0,0 +1,335 @@
+package tbcomctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html/template"
+	"runtime/trace"
+	"strconv"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+)
+
+// defReviewTemplate is the default template of the Review message.
+const defReviewTemplate = `{{.Title}}
+{{range .Fields}}
+<b>{{.Label}}:</b> {{.Value}}{{end}}`
+
+// Review is the controller that summarises the answers of the form before
+// submitting it.  It shows every answered controller of the form, the Confirm
+// button, that completes the form (or invokes the next controller), and the Edit
+// button for each of the answers.  Edit button invokes the controller, and once
+// the user has answered, the form returns straight to the Review.
+//
+// Review must be a part of the form.  Set the overwrite flag on the form, so
+// that the edited controllers replace the review message and vice versa.
+type Review struct {
+	commonCtl
+	*buttons
+
+	removeButtons bool
+
+	tmpl       *template.Template
+	confirmTxt Texter
+	labels     map[string]string // controller name->label
+	fields     []string          // names of the controllers to review, all if empty.
+}
+
+var _ Controller = &Review{}
+
+// ReviewField is the answer shown in the review.
+type ReviewField struct {
+	Name  string // controller name
+	Label string // translated label
+	Value string // value as shown to the user
+}
+
+// ReviewData is the data of the Review template.
+type ReviewData struct {
+	Title  string // translated MsgReviewTitle
+	Fields []ReviewField
+	Data   map[string]string // form data
+}
+
+type ReviewOption func(*Review)
+
+// RVOptTemplate sets the html/template of the Review message, the template is
+// executed with ReviewData.  It panics if the template is invalid.
+func RVOptTemplate(text string) ReviewOption {
+	return func(rv *Review) {
+		rv.tmpl = template.Must(template.New(rv.name).Parse(text))
+	}
+}
+
+// RVOptLabels sets the labels of the controllers, the labels are translated
+// with the package Printer.  By default, the controller name is used.
+func RVOptLabels(labels map[string]string) ReviewOption {
+	return func(rv *Review) {
+		rv.labels = labels
+	}
+}
+
+// RVOptFields sets the names of the controllers to review, in the order they
+// are shown.  By default, all answered controllers are shown in the form order.
+func RVOptFields(names ...string) ReviewOption {
+	return func(rv *Review) {
+		rv.fields = names
+	}
+}
+
+// RVOptBtnConfirm sets the text of the Confirm button.
+func RVOptBtnConfirm(texter Texter) ReviewOption {
+	return func(rv *Review) {
+		rv.confirmTxt = texter
+	}
+}
+
+// RVOptRemoveButtons sets the Remove Buttons option.  If set (the default),
+// the buttons are removed once the user confirms the answers.  The buttons of
+// the confirmed review are outdated in any case.
+func RVOptRemoveButtons(b bool) ReviewOption {
+	return func(rv *Review) {
+		rv.removeButtons = b
+	}
+}
+
+func RVOptOverwrite(b bool) ReviewOption {
+	return func(rv *Review) {
+		rv.commonCtl.setOverwrite(b)
+	}
+}
+
+func RVOptFallbackLang(lang string) ReviewOption {
+	return func(rv *Review) {
+		optFallbackLang(lang)(&rv.commonCtl)
+	}
+}
+
+// RVOptRegistry sets the registry that stores the review state.
+func RVOptRegistry(reg Registry) ReviewOption {
+	return func(rv *Review) {
+		optRegistry(reg)(&rv.commonCtl)
+	}
+}
+
+// RVOptScope sets the scope of the review state, see Scope.
+func RVOptScope(s Scope) ReviewOption {
+	return func(rv *Review) {
+		optScope(s)(&rv.commonCtl)
+	}
+}
+
+// NewReview creates a new Review controller.
+func NewReview(name string, opts ...ReviewOption) *Review {
+	rv := &Review{
+		commonCtl:     newCommonCtl(name),
+		buttons:       &buttons{maxButtons: 2},
+		confirmTxt:    NewTexter(MsgConfirm),
+		removeButtons: true,
+	}
+	for _, opt := range opts {
+		opt(rv)
+	}
+	if rv.tmpl == nil {
+		rv.tmpl = template.Must(template.New(name).Parse(defReviewTemplate))
+	}
+	return rv
+}
+
+// Handler renders the review of the answers.
+func (rv *Review) Handler(c tb.Context) error {
+	if rv.form == nil {
+		return errors.New("review: " + rv.name + " is not a part of the form")
+	}
+	fields := rv.answered(c)
+	text, err := rv.render(c, fields)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, rv.fallbackLang))
+		return fmt.Errorf("error while rendering the review: %s: %w", rv.name, err)
+	}
+	markup, err := rv.inlineMarkup(c, fields)
+	if err != nil {
+		return fmt.Errorf("error while generating markup for controller: %s: %w", rv.name, err)
+	}
+	outbound, err := rv.sendOrEdit(c, text, rv.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = rv.reg.Register(rv.recipient(c), outbound.ID)
+	rv.logOutgoingMsg(outbound, "review")
+	return nil
+}
+
+// answered returns the answered fields.
+func (rv *Review) answered(c tb.Context) []ReviewField {
+	pr := PrinterContext(c, rv.fallbackLang)
+	names := rv.fields
+	if len(names) == 0 {
+		for _, ctrl := range rv.form.ctrls {
+			names = append(names, ctrl.Name())
+		}
+	}
+	var fields []ReviewField
+	for _, name := range names {
+		ctrl, ok := rv.form.cm[name]
+		if !ok || name == rv.name {
+			continue
+		}
+		switch ctrl.(type) {
+		case *Message, *Review:
+			continue
+		}
+		val, ok := ctrl.Value(recipientOf(ctrl, c).Recipient())
+		if !ok {
+			continue
+		}
+		label := name
+		if l, ok := rv.labels[name]; ok {
+			label = l
+		}
+		fields = append(fields, ReviewField{Name: name, Label: pr.Sprintf(label), Value: displayValue(ctrl, val)})
+	}
+	return fields
+}
+
+// displayValue returns the value of the controller as shown to the user.
+func displayValue(ctrl Controller, val string) string {
+	switch ctrl := ctrl.(type) {
+	case *Checklist:
+		if items, err := decodeList(val); err == nil {
+			return strings.Join(items, ", ")
+		}
+	case *Input:
+		if ctrl.media && val != "" {
+			if ref, err := ParseMediaRef(val); err == nil {
+				return ref.String()
+			}
+		}
+	}
+	if val == "" {
+		return "—"
+	}
+	return val
+}
+
+// render executes the review template.
+func (rv *Review) render(c tb.Context, fields []ReviewField) (string, error) {
+	var buf strings.Builder
+	err := rv.tmpl.Execute(&buf, ReviewData{
+		Title:  PrinterContext(c, rv.fallbackLang).Sprintf(MsgReviewTitle),
+		Fields: fields,
+		Data:   rv.form.DataContext(c),
+	})
+	return buf.String(), err
+}
+
+// payloadConfirm is the callback data payload of the Confirm button, Edit
+// buttons have the index of the field as the payload.
+const payloadConfirm = "y"
+
+// callbackID returns the ID of the review for the callback data.
+func (rv *Review) callbackID() string {
+	return callbackID("review", rv.name)
+}
+
+// Register registers the review callback with the dispatcher of the bot, see
+// Picklist.Register.
+func (rv *Review) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(rv.callbackID(), rv, rv.callback)
+}
+
+// fieldsVersion returns the version of the fields for the callback data.
+func fieldsVersion(fields []ReviewField) string {
+	names := make([]string, len(fields))
+	for i := range fields {
+		names[i] = fields[i].Name
+	}
+	return codec.Version(names...)
+}
+
+// inlineMarkup returns the markup with the Edit buttons and the Confirm button.
+func (rv *Review) inlineMarkup(c tb.Context, fields []ReviewField) (*tb.ReplyMarkup, error) {
+	pr := PrinterContext(c, rv.fallbackLang)
+	labels := make([]string, 0, len(fields)+1)
+	payloads := make([]string, 0, len(fields)+1)
+	for i, f := range fields {
+		labels = append(labels, pr.Sprintf(MsgEditField, f.Label))
+		payloads = append(payloads, strconv.Itoa(i))
+	}
+	confirmTxt, err := rv.confirmTxt.Text(context.Background(), c)
+	if err != nil {
+		dlg.Printf("confirmTextFn returned an error: %s", err)
+	}
+	labels = append(labels, pr.Sprintf(confirmTxt))
+	payloads = append(payloads, payloadConfirm)
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, rv.callbackID(), fieldsVersion(fields), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	rows := OrganizeButtons(btns[:len(fields)], rv.maxButtons)
+	markup.Inline(append(rows, tb.Row{btns[len(fields)]})...)
+	rv.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// callback handles the Confirm and Edit buttons.
+func (rv *Review) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Review.Callback")
+	defer task.End()
+
+	rv.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, rv.callbackID(), rv.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if rv.form == nil {
+		return c.Respond(&tb.CallbackResponse{})
+	}
+	fields := rv.answered(c)
+	if d.Version != fieldsVersion(fields) || !rv.isShown(c) {
+		// the fields have changed, or the review was confirmed.
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, rv.fallbackLang)
+	}
+	if d.Payload == payloadConfirm {
+		return rv.confirm(ctx, c)
+	}
+	idx, err := strconv.Atoi(d.Payload)
+	if err != nil || idx < 0 || len(fields) <= idx {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, rv.fallbackLang)
+	}
+	if err := c.Respond(&tb.CallbackResponse{}); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+	return rv.form.edit(c, rv.name, fields[idx].Name)
+}
+
+// isShown returns true if the review message of the callback awaits the
+// confirmation, i.e. it was not confirmed and the form was not reset.
+func (rv *Review) isShown(c tb.Context) bool {
+	reqID, _ := rv.reg.RequestInfo(rv.recipient(c), c.Callback().Message.ID)
+	return reqID != registry.Unknown
+}
+
+// confirm invokes the next controller, or completes the form.
+func (rv *Review) confirm(ctx context.Context, c tb.Context) error {
+	if rv.removeButtons {
+		if _, err := c.Bot().EditReplyMarkup(c.Message(), nil); err != nil {
+			trace.Log(ctx, "EditReplyMarkup", err.Error())
+		}
+	}
+	if err := c.Respond(&tb.CallbackResponse{Text: MsgOK}); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+	rv.reg.Unregister(rv.recipient(c), c.Callback().Message.ID)
+	return rv.nextHandler(c)
+}
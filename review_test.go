@@ -0,0 +1,86 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestReview(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	r := user.Recipient()
+
+	colour := NewPicklist("colour", NewStaticTVC("Colour?", []string{"red", "green"}, func(context.Context, tb.Context) error { return nil }))
+	name := NewInputText("name", "Name?", func(context.Context, tb.Context) error { return nil })
+	tags := NewChecklist("tags", NewStaticTVC("Tags?", []string{"a", "b"}, func(context.Context, tb.Context) error { return nil }))
+	rv := NewReview("review", RVOptLabels(map[string]string{"name": "Name"}))
+	var completed map[string]string
+	NewForm(colour, name, tags, rv).
+		SetOverwrite(true).
+		OnComplete(func(_ context.Context, _ tb.Context, data map[string]string) error {
+			completed = data
+			return nil
+		})
+	colour.SetValue(r, "red")
+	name.SetValue(r, "<Bob>")
+	tags.SetValue(r, encodeList([]string{"a", "b"}))
+
+	assert.NoError(t, rv.Handler(newTestCallback(b, user, "")))
+	assert.Equal(t, "Please check your answers:\n\n<b>colour:</b> red\n<b>Name:</b> &lt;Bob&gt;\n<b>tags:</b> a, b", log.call(0).Params["text"])
+
+	m, err := rv.inlineMarkup(newTestCallback(b, user, ""), rv.answered(newTestCallback(b, user, "")))
+	assert.NoError(t, err)
+	assert.Equal(t, [][]string{{"✏️ colour", "✏️ Name"}, {"✏️ tags"}, {"✅ Confirm"}}, markupLabels(m))
+
+	// edit jumps to the picklist, that replaces the review message.
+	assert.NoError(t, rv.callback(pressButton(t, b, user, m, "✏️ colour")))
+	assert.Equal(t, "editMessageText", log.call(1).Method)
+	assert.Equal(t, "Colour?", log.call(1).Params["text"])
+
+	// once answered, the form returns to the review.
+	choices, pg, _ := colour.choices(context.Background(), newTestCallback(b, user, ""), 0)
+	pm, _ := colour.inlineMarkup(newTestCallback(b, user, ""), choices, pg)
+	assert.NoError(t, colour.callback(pressButton(t, b, user, pm, "green")))
+	last := log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageText", last.Method)
+	assert.Contains(t, last.Params["text"], "<b>colour:</b> green")
+	assert.NotContains(t, log.methods()[1:], "sendMessage", "name is not asked again")
+
+	// the input replaces the review message with the prompt.
+	b.Handle(tb.OnText, name.OnTextMw(nil))
+	assert.NoError(t, rv.callback(pressButton(t, b, user, m, "✏️ Name")))
+	last = log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageText", last.Method)
+	assert.Equal(t, "Name?", last.Params["text"])
+	b.ProcessUpdate(tb.Update{Message: &tb.Message{ID: 2, Sender: user, Chat: &tb.Chat{ID: user.ID}, Text: "Alice"}})
+	last = log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageText", last.Method)
+	assert.Contains(t, last.Params["text"], "<b>Name:</b> Alice")
+
+	// fields changed since the review was rendered.
+	tags.reg.Clear(r)
+	for _, label := range []string{"✏️ Name", "✅ Confirm"} {
+		c := pressButton(t, b, user, m, label)
+		assert.NoError(t, rv.callback(c))
+		assert.Equal(t, MsgBtnStale, c.lastResponse().Text)
+	}
+	assert.Nil(t, completed)
+	tags.SetValue(r, encodeList([]string{"a", "b"}))
+
+	n := len(log.methods())
+	assert.NoError(t, rv.callback(pressButton(t, b, user, m, "✅ Confirm")))
+	assert.Equal(t, map[string]string{"colour": "green", "name": "Alice", "tags": `["a","b"]`}, completed)
+	assert.Equal(t, "editMessageReplyMarkup", log.call(n).Method, "buttons are removed")
+
+	// the buttons of the confirmed review are outdated.
+	completed = nil
+	for _, label := range []string{"✏️ Name", "✅ Confirm"} {
+		c := pressButton(t, b, user, m, label)
+		assert.NoError(t, rv.callback(c))
+		assert.Equal(t, MsgBtnStale, c.lastResponse().Text)
+	}
+	assert.Nil(t, completed)
+}
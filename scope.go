@@ -0,0 +1,84 @@
+package tbcomctl
+
+import (
+	"strconv"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// Scope derives the recipient, that is the registry key of the control state,
+// from the context.  It decides whether the state is kept per user (the
+// default), per chat, or per user in the chat.  A custom Scope may return any
+// recipient, that identifies the state.
+type Scope func(c tb.Context) tb.Recipient
+
+var (
+	// ScopeUser keeps the state per user, the same user has the same state in
+	// all chats.
+	ScopeUser Scope = func(c tb.Context) tb.Recipient { return c.Sender() }
+	// ScopeChat keeps the state per chat, all users in the chat share it.
+	ScopeChat Scope = func(c tb.Context) tb.Recipient { return c.Chat() }
+	// ScopeChatUser keeps the state per user in the chat.
+	ScopeChatUser Scope = func(c tb.Context) tb.Recipient {
+		return ChatUser{ChatID: c.Chat().ID, UserID: c.Sender().ID}
+	}
+)
+
+// ChatUser is the recipient, that identifies the user in the chat.  It is the
+// key of the ScopeChatUser state and of the group mode input state, see
+// IOptGroup.
+type ChatUser struct {
+	ChatID int64
+	UserID int64
+}
+
+// Recipient returns the recipient string in the "chatID:userID" format.
+func (cu ChatUser) Recipient() string {
+	return strconv.FormatInt(cu.ChatID, 10) + ":" + strconv.FormatInt(cu.UserID, 10)
+}
+
+// scoper is the interface for the controls, that support the scope.
+type scoper interface {
+	setScope(s Scope)
+	recipient(c tb.Context) tb.Recipient
+}
+
+// optScope sets the scope of the control.
+func optScope(s Scope) option {
+	return func(ctl *commonCtl) {
+		ctl.setScope(s)
+	}
+}
+
+// setScope sets the scope of the control.
+func (cc *commonCtl) setScope(s Scope) {
+	cc.scope = s
+}
+
+// recipient returns the registry key of the control state for the context.
+func (cc *commonCtl) recipient(c tb.Context) tb.Recipient {
+	if cc.scope == nil {
+		return c.Sender()
+	}
+	return cc.scope(c)
+}
+
+// recipientOf returns the registry key of the controller state for the
+// context.  Controllers that do not support the scope use the sender.
+func recipientOf(ctrl Controller, c tb.Context) tb.Recipient {
+	if s, ok := ctrl.(scoper); ok {
+		return s.recipient(c)
+	}
+	return c.Sender()
+}
+
+// recipientFunc returns the recipient key of the controller state.
+type recipientFunc func(ctrl Controller) string
+
+// contextRecipient returns the recipientFunc, that returns the recipient of
+// the controller scope for the context.
+func contextRecipient(c tb.Context) recipientFunc {
+	return func(ctrl Controller) string {
+		return recipientOf(ctrl, c).Recipient()
+	}
+}
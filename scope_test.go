@@ -0,0 +1,117 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestForm_SetScope(t *testing.T) {
+	alice := &tb.User{ID: 1}
+	bob := &tb.User{ID: 2}
+
+	// each form has its own bot, as the controls of the forms have the same
+	// name.
+	var b *tb.Bot
+	newForm := func(t *testing.T, s Scope) (*Form, *Picklist) {
+		b = newTestBot(t)
+		p := NewPicklist("colour", NewStaticTVC("Pick", []string{"red", "green"}, func(context.Context, tb.Context) error { return nil }))
+		return NewForm(p).SetScope(s), p
+	}
+	// press returns the context of the button press by the user in the chat.
+	press := func(t *testing.T, p *Picklist, u *tb.User, chatID int64, label string) tb.Context {
+		choices, err := p.allChoices(context.Background(), nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err := p.inlineMarkup(newTestCallback(b, u, ""), choices, page{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		c := pressButton(t, b, u, m, label)
+		c.msg.Chat = &tb.Chat{ID: chatID}
+		assert.NoError(t, p.callback(c))
+		return c
+	}
+
+	t.Run("chat user", func(t *testing.T) {
+		fm, p := newForm(t, ScopeChatUser)
+		c1 := press(t, p, alice, -100, "red")
+		c2 := press(t, p, alice, -200, "green")
+		assert.Equal(t, map[string]string{"colour": "red"}, fm.DataContext(c1))
+		assert.Equal(t, map[string]string{"colour": "green"}, fm.DataContext(c2))
+		val, _ := fm.Value("colour", "-100:1")
+		assert.Equal(t, "red", val)
+		_, ok := fm.Value("colour", alice.Recipient())
+		assert.False(t, ok, "nothing is stored for the user")
+	})
+	t.Run("chat", func(t *testing.T) {
+		fm, p := newForm(t, ScopeChat)
+		c1 := press(t, p, alice, -100, "red")
+		c2 := press(t, p, bob, -100, "green")
+		assert.Equal(t, map[string]string{"colour": "green"}, fm.DataContext(c1), "users share the value")
+		assert.Equal(t, map[string]string{"colour": "green"}, fm.DataContext(c2))
+		assert.Equal(t, &tb.Chat{ID: -100}, fm.Recipient(c1))
+	})
+	t.Run("default", func(t *testing.T) {
+		fm, p := newForm(t, nil)
+		press(t, p, alice, -100, "red")
+		c := press(t, p, alice, -200, "green")
+		assert.Equal(t, map[string]string{"colour": "green"}, fm.DataContext(c))
+		assert.Equal(t, alice, fm.Recipient(c))
+	})
+	t.Run("custom", func(t *testing.T) {
+		session := ChatUser{ChatID: 0, UserID: 99}
+		fm, p := newForm(t, func(tb.Context) tb.Recipient { return session })
+		c := press(t, p, alice, -100, "red")
+		assert.Equal(t, map[string]string{"colour": "red"}, fm.DataContext(c))
+		val, _ := fm.Value("colour", session.Recipient())
+		assert.Equal(t, "red", val)
+	})
+}
+
+func TestForm_DataContext_mixedScope(t *testing.T) {
+	b := newTestBot(t)
+	alice := &tb.User{ID: 1}
+
+	p := NewPicklist("colour", NewStaticTVC("Pick", []string{"red", "green"}, nil), PickOptScope(ScopeChat))
+	ip := NewInputText("name", "Name?", nil)
+	fm := NewForm(p, ip)
+	p.SetValue("-100", "red")
+	ip.SetValue(alice.Recipient(), "Alice")
+
+	c := newTestCallback(b, alice, "")
+	c.msg.Chat = &tb.Chat{ID: -100}
+	assert.Equal(t, map[string]string{"colour": "red", "name": "Alice"}, fm.DataContext(c), "each value is taken for the controller scope")
+	assert.Equal(t, map[string]string{"name": "Alice"}, fm.Data(alice), "values are taken for the recipient")
+	val, _ := fm.ValueContext(c, "colour")
+	assert.Equal(t, "red", val)
+
+	type profile struct {
+		Colour string `form:"colour"`
+		Name   string `form:"name"`
+	}
+	var got profile
+	assert.NoError(t, fm.BindContext(c, &got))
+	assert.Equal(t, profile{Colour: "red", Name: "Alice"}, got, "Bind agrees with DataContext")
+
+	assert.NoError(t, fm.FillContext(c, &profile{Colour: "green", Name: "Bob"}))
+	assert.Equal(t, map[string]string{"colour": "green", "name": "Bob"}, fm.DataContext(c))
+	_, ok := p.Value(alice.Recipient())
+	assert.False(t, ok, "value is set for the controller scope")
+}
+
+func TestSubChecker_scope(t *testing.T) {
+	b := newTestBot(t)
+	c := newTestCallback(b, &tb.User{ID: 1}, "")
+	c.msg.Chat = &tb.Chat{ID: -100}
+
+	sc := NewSubChecker("sub", NewTexter("Subscribe"), nil, SCOptScope(ScopeChat))
+	assert.Equal(t, "-100", sc.recipient(c).Recipient())
+	assert.Equal(t, "-100", sc.pl.recipient(c).Recipient(), "picklist has the same scope")
+
+	NewForm(sc).SetScope(ScopeChatUser)
+	assert.Equal(t, "-100:1", sc.pl.recipient(c).Recipient(), "form scope is set on the picklist")
+}
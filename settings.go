@@ -0,0 +1,439 @@
+package tbcomctl
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"sync"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// Settings is the settings panel, that lists the settings with their current
+// values, one per row.  Pressing the toggle setting switches it on and off,
+// pressing the multi-choice setting cycles through the choices.  The panel is
+// edited in place on each press.
+//
+// The values are stored in the registry, encoded as a JSON object, or in the
+// SettingsStore (see SettOptStore).  The settings are permanent: if they are
+// stored in the registry, the values are not removed by the sweeper (see
+// StartSweeper) and by Form.Reset, only the message and the request entries of
+// the panel are, so a persistent registry should be used, or the
+// SettingsStore.
+type Settings struct {
+	commonCtl
+
+	removeButtons bool
+	doneBtn       bool
+
+	texter     Texter
+	doneBtnTxt Texter
+
+	settings []Setting
+	store    SettingsStore
+}
+
+var _ Controller = &Settings{}
+
+// Setting is the option of the Settings panel.
+type Setting struct {
+	// Name is the key of the setting.
+	Name string
+	// Label is shown to the user, it is translated with the package Printer.
+	Label string
+	// Choices of the multi-choice setting, translated with the package
+	// Printer.  If empty, the setting is the toggle with the values "true"
+	// and "false".
+	Choices []string
+	// Default is the value of the setting, until the user changes it.  If
+	// empty, the toggle is off, and the multi-choice setting has the first
+	// choice.
+	Default string
+	// OnChange is called before the new value is stored, it receives the value
+	// as the Context.Data.  If it returns an error, the value is not changed,
+	// and the user is alerted.
+	OnChange HandleContextFunc
+}
+
+// toggle returns true if the setting is the toggle.
+func (s *Setting) toggle() bool {
+	return len(s.Choices) == 0
+}
+
+// valid returns true if the value is valid for the setting.
+func (s *Setting) valid(value string) bool {
+	if s.toggle() {
+		_, err := strconv.ParseBool(value)
+		return err == nil
+	}
+	return s.index(value) >= 0
+}
+
+// index returns the index of the choice, or -1.
+func (s *Setting) index(value string) int {
+	for i, ch := range s.Choices {
+		if ch == value {
+			return i
+		}
+	}
+	return -1
+}
+
+// defaultValue returns the value of the setting, until the user changes it.
+func (s *Setting) defaultValue() string {
+	if s.valid(s.Default) {
+		return s.Default
+	}
+	if s.toggle() {
+		return strconv.FormatBool(false)
+	}
+	return s.Choices[0]
+}
+
+// next returns the value that follows the value.
+func (s *Setting) next(value string) string {
+	if s.toggle() {
+		on, _ := strconv.ParseBool(value)
+		return strconv.FormatBool(!on)
+	}
+	return s.Choices[(s.index(value)+1)%len(s.Choices)]
+}
+
+// SettingsStore stores the settings of the recipients.
+type SettingsStore interface {
+	// Settings should return the values of the settings of the recipient, the
+	// missing settings have the default values.
+	Settings(recipient string) (map[string]string, error)
+	// SetSetting should store the value of the setting of the recipient.
+	SetSetting(recipient string, name string, value string) error
+}
+
+// regStore is the SettingsStore that keeps the settings in the registry of the
+// control, encoded as a JSON object.
+type regStore struct {
+	mu sync.Mutex
+	cc *commonCtl
+}
+
+func (rs *regStore) Settings(recipient string) (map[string]string, error) {
+	val, ok := rs.cc.Value(recipient)
+	if !ok || val == "" {
+		return map[string]string{}, nil
+	}
+	var m map[string]string
+	if err := json.Unmarshal([]byte(val), &m); err != nil {
+		return nil, fmt.Errorf("invalid settings: %w", err)
+	}
+	return m, nil
+}
+
+func (rs *regStore) SetSetting(recipient string, name string, value string) error {
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	m, err := rs.Settings(recipient)
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		m = make(map[string]string, 1)
+	}
+	m[name] = value
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	rs.cc.SetValue(recipient, string(data))
+	return nil
+}
+
+type SettingsOption func(*Settings)
+
+// SettOptStore sets the store of the settings values, by default the values
+// are kept in the registry.
+func SettOptStore(store SettingsStore) SettingsOption {
+	return func(s *Settings) {
+		s.store = store
+	}
+}
+
+// SettOptBtnDone adds the Done button, that invokes the next controller, or
+// completes the form.
+func SettOptBtnDone(texter Texter) SettingsOption {
+	return func(s *Settings) {
+		s.doneBtn = true
+		s.doneBtnTxt = texter
+	}
+}
+
+// SettOptRemoveButtons set the Remove Buttons option.  If Remove Buttons is
+// set, the buttons will be removed once the user presses Done.
+func SettOptRemoveButtons(b bool) SettingsOption {
+	return func(s *Settings) {
+		s.removeButtons = b
+	}
+}
+
+func SettOptOverwrite(b bool) SettingsOption {
+	return func(s *Settings) {
+		s.commonCtl.setOverwrite(b)
+	}
+}
+
+func SettOptPrivateOnly(b bool) SettingsOption {
+	return func(s *Settings) {
+		optPrivateOnly(b)(&s.commonCtl)
+	}
+}
+
+func SettOptFallbackLang(lang string) SettingsOption {
+	return func(s *Settings) {
+		optFallbackLang(lang)(&s.commonCtl)
+	}
+}
+
+// SettOptRegistry sets the registry that stores the settings panel state.
+func SettOptRegistry(reg Registry) SettingsOption {
+	return func(s *Settings) {
+		optRegistry(reg)(&s.commonCtl)
+	}
+}
+
+// SettOptScope sets the scope of the settings, see Scope.
+func SettOptScope(sc Scope) SettingsOption {
+	return func(s *Settings) {
+		optScope(sc)(&s.commonCtl)
+	}
+}
+
+// NewSettings creates a new settings panel with the text produced by texter.
+func NewSettings(name string, texter Texter, settings []Setting, opts ...SettingsOption) *Settings {
+	s := &Settings{
+		commonCtl: newCommonCtl(name),
+		texter:    texter,
+		settings:  settings,
+	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	if s.store == nil {
+		s.store = &regStore{cc: &s.commonCtl}
+	}
+	return s
+}
+
+// inRegistry returns true if the settings are stored in the registry.
+func (s *Settings) inRegistry() bool {
+	_, ok := s.store.(*regStore)
+	return ok
+}
+
+// expire removes the expired registry entries.  If the settings are stored in
+// the registry, the values are kept, only the message and the request
+// entries are removed.
+func (s *Settings) expire(ctx context.Context, b *tb.Bot, before time.Time) []string {
+	if !s.inRegistry() {
+		return s.commonCtl.expire(ctx, b, before)
+	}
+	e, ok := s.reg.(requestExpirer)
+	if !ok {
+		return nil
+	}
+	return e.ExpireRequests("", before)
+}
+
+// clear removes the registry entries of the recipient.  If the settings are
+// stored in the registry, the values are kept.
+func (s *Settings) clear(recipient string) {
+	rs, ok := s.store.(*regStore)
+	if !ok {
+		s.commonCtl.clear(recipient)
+		return
+	}
+	rs.mu.Lock()
+	defer rs.mu.Unlock()
+	val, ok := s.Value(recipient)
+	s.commonCtl.clear(recipient)
+	if ok {
+		s.SetValue(recipient, val)
+	}
+}
+
+// Values returns the values of all settings of the recipient.
+func (s *Settings) Values(recipient string) (map[string]string, error) {
+	stored, err := s.store.Settings(recipient)
+	if err != nil {
+		return nil, err
+	}
+	values := make(map[string]string, len(s.settings))
+	for i := range s.settings {
+		st := &s.settings[i]
+		if val, ok := stored[st.Name]; ok && st.valid(val) {
+			values[st.Name] = val
+		} else {
+			values[st.Name] = st.defaultValue()
+		}
+	}
+	return values, nil
+}
+
+// Handler is a handler function to use with telebot.Handle.
+func (s *Settings) Handler(c tb.Context) error {
+	if s.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	text, err := s.texter.Text(WithController(context.Background(), s), c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, s.fallbackLang))
+		return fmt.Errorf("error while generating text for controller: %s: %w", s.name, err)
+	}
+	markup, err := s.inlineMarkup(c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, s.fallbackLang))
+		return fmt.Errorf("error while generating markup for controller: %s: %w", s.name, err)
+	}
+	outbound, err := s.sendOrEdit(c, text, s.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = s.reg.Register(s.recipient(c), outbound.ID)
+	s.logOutgoingMsg(outbound, "settings")
+	return nil
+}
+
+// crossMark is prepended to the labels of the toggle settings that are off.
+const crossMark = "❌ "
+
+// callbackID returns the ID of the settings for the callback data.
+func (s *Settings) callbackID() string {
+	return callbackID("settings", s.name)
+}
+
+// Register registers the settings callback with the dispatcher of the bot, see
+// Picklist.Register.
+func (s *Settings) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(s.callbackID(), s, s.callback)
+}
+
+// version returns the version of the settings for the callback data.
+func (s *Settings) version() string {
+	names := make([]string, len(s.settings))
+	for i := range s.settings {
+		names[i] = s.settings[i].Name
+	}
+	return codec.Version(names...)
+}
+
+// inlineMarkup generates the settings buttons with the current values.
+// Setting buttons have the index of the setting as the payload.
+func (s *Settings) inlineMarkup(c tb.Context) (*tb.ReplyMarkup, error) {
+	values, err := s.Values(s.recipient(c).Recipient())
+	if err != nil {
+		return nil, err
+	}
+	pr := PrinterContext(c, s.fallbackLang)
+	var labels, payloads []string
+	for i := range s.settings {
+		st := &s.settings[i]
+		val := values[st.Name]
+		var label string
+		if st.toggle() {
+			mark := crossMark
+			if on, _ := strconv.ParseBool(val); on {
+				mark = checkMark
+			}
+			label = mark + pr.Sprintf(st.Label)
+		} else {
+			label = pr.Sprintf(st.Label) + ": " + pr.Sprintf(val)
+		}
+		labels, payloads = append(labels, label), append(payloads, strconv.Itoa(i))
+	}
+	if s.doneBtn {
+		txt, err := s.doneBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("doneTextFn returned an error: %s", err)
+		}
+		labels, payloads = append(labels, pr.Sprintf(txt)), append(payloads, payloadDone)
+	}
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, s.callbackID(), s.version(), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	markup.Inline(OrganizeButtons(btns, 1)...)
+	s.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (s *Settings) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Settings.Callback")
+	defer task.End()
+
+	s.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, s.callbackID(), s.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if d.Version != s.version() {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, s.fallbackLang)
+	}
+	if d.Payload == payloadDone {
+		return s.done(ctx, c)
+	}
+	idx, err := strconv.Atoi(d.Payload)
+	if err != nil || idx < 0 || len(s.settings) <= idx {
+		return respondStale(c, s.fallbackLang)
+	}
+	if err := s.change(ctx, c, &s.settings[idx]); err != nil {
+		var e *Error
+		if errors.As(err, &e) {
+			return respondAlert(c, e.Msg)
+		}
+		respondAlert(c, PrinterContext(c, s.fallbackLang).Sprintf(MsgUnexpected))
+		return err
+	}
+
+	markup, err := s.inlineMarkup(c)
+	if err != nil {
+		return err
+	}
+	if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+		trace.Log(ctx, "EditReplyMarkup", err.Error())
+	}
+	return c.Respond(&tb.CallbackResponse{})
+}
+
+// change switches the setting to the next value.
+func (s *Settings) change(ctx context.Context, c tb.Context, st *Setting) error {
+	recipient := s.recipient(c).Recipient()
+	values, err := s.Values(recipient)
+	if err != nil {
+		return err
+	}
+	next := st.next(values[st.Name])
+	if st.OnChange != nil {
+		if err := st.OnChange(WithController(ctx, s), withCallbackData(c, next)); err != nil {
+			return err
+		}
+	}
+	return s.store.SetSetting(recipient, st.Name, next)
+}
+
+// done invokes the next controller, or completes the form.
+func (s *Settings) done(ctx context.Context, c tb.Context) error {
+	if s.removeButtons {
+		if err := removeMarkup(c); err != nil {
+			trace.Log(ctx, "EditReplyMarkup", err.Error())
+		}
+	}
+	return s.proceed(ctx, c, &tb.CallbackResponse{Text: MsgOK})
+}
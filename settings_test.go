@@ -0,0 +1,124 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/registry"
+)
+
+// testStore is the SettingsStore backed by the map.
+type testStore map[string]map[string]string
+
+func (ts testStore) Settings(recipient string) (map[string]string, error) {
+	return ts[recipient], nil
+}
+
+func (ts testStore) SetSetting(recipient, name, value string) error {
+	if ts[recipient] == nil {
+		ts[recipient] = make(map[string]string)
+	}
+	ts[recipient][name] = value
+	return nil
+}
+
+func TestSettings(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "ru"}
+	r := user.Recipient()
+
+	var changes []string
+	settings := []Setting{
+		{Name: "notify", Label: "Notifications", Default: "true", OnChange: func(_ context.Context, c tb.Context) error {
+			changes = append(changes, "notify="+c.Data())
+			return nil
+		}},
+		{Name: "lang", Label: "Language", Choices: []string{"en", "ru", "de"}, Default: "ru"},
+		{Name: "locked", Label: "Locked", OnChange: func(context.Context, tb.Context) error {
+			return &Error{Msg: "not allowed", Type: TErrRetry}
+		}},
+	}
+
+	t.Run("registry", func(t *testing.T) {
+		s := NewSettings("settings", NewTexter("Settings"), settings, SettOptBtnDone(NewTexter(MsgDone)))
+		var completed map[string]string
+		NewForm(s).OnComplete(func(_ context.Context, _ tb.Context, data map[string]string) error {
+			completed = data
+			return nil
+		})
+		c := newTestCallback(b, user, "")
+		m, err := s.inlineMarkup(c)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, [][]string{{"✅ Notifications"}, {"Language: ru"}, {"❌ Locked"}, {"Готово"}}, markupLabels(m))
+
+		assert.NoError(t, s.callback(pressButton(t, b, user, m, "✅ Notifications")))
+		assert.Equal(t, []string{"notify=false"}, changes)
+		assert.Equal(t, "editMessageReplyMarkup", log.call(len(log.methods())-1).Method)
+
+		assert.NoError(t, s.callback(pressButton(t, b, user, m, "Language: ru")))
+		assert.NoError(t, s.callback(pressButton(t, b, user, m, "Language: ru")))
+		m, _ = s.inlineMarkup(c)
+		assert.Equal(t, [][]string{{"❌ Notifications"}, {"Language: en"}, {"❌ Locked"}, {"Готово"}}, markupLabels(m), "choices are cycled")
+
+		// OnChange rejects the change.
+		press := pressButton(t, b, user, m, "❌ Locked")
+		assert.NoError(t, s.callback(press))
+		assert.Equal(t, &tb.CallbackResponse{Text: "not allowed", ShowAlert: true}, press.lastResponse())
+
+		values, err := s.Values(r)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"notify": "false", "lang": "en", "locked": "false"}, values)
+
+		assert.NoError(t, s.callback(pressButton(t, b, user, m, "Готово")))
+		assert.Equal(t, map[string]string{"settings": `{"lang":"en","notify":"false"}`}, completed)
+	})
+	t.Run("store", func(t *testing.T) {
+		store := testStore{r: {"lang": "de", "notify": "maybe"}}
+		s := NewSettings("store", NewTexter("Settings"), settings[:2], SettOptStore(store))
+		m, err := s.inlineMarkup(newTestCallback(b, user, ""))
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, [][]string{{"✅ Notifications"}, {"Language: de"}}, markupLabels(m), "invalid value is replaced with the default")
+
+		assert.NoError(t, s.callback(pressButton(t, b, user, m, "Language: de")))
+		assert.Equal(t, "en", store[r]["lang"], "choices wrap around")
+		_, ok := s.Value(r)
+		assert.False(t, ok, "registry is not used")
+	})
+	t.Run("permanent", func(t *testing.T) {
+		s := NewSettings("permanent", NewTexter("Settings"), settings[:1])
+		fm := NewForm(s)
+		assert.NoError(t, s.store.SetSetting(r, "notify", "false"))
+		s.reg.Register(user, 10)
+		s.expire(context.Background(), b, time.Now().Add(time.Hour))
+		_, ok := s.OutgoingID(r)
+		assert.False(t, ok, "panel message is expired")
+		s.reg.Register(user, 11)
+		fm.Reset(r)
+		reqID, _ := s.reg.RequestInfo(user, 11)
+		assert.Equal(t, registry.Unknown, reqID, "panel request is cleared")
+		values, err := s.Values(r)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"notify": "false"}, values, "settings survive the sweeper and the reset")
+
+		// the registry of the panel with the store is cleared as usual.
+		s = NewSettings("stored", NewTexter("Settings"), settings[:1], SettOptStore(testStore{}))
+		s.SetValue(r, "state")
+		s.clear(r)
+		_, ok = s.Value(r)
+		assert.False(t, ok)
+	})
+	t.Run("store error", func(t *testing.T) {
+		s := NewSettings("broken", NewTexter("Settings"), settings[:1])
+		s.SetValue(r, "not json")
+		_, err := s.inlineMarkup(newTestCallback(b, user, ""))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,289 @@
+package tbcomctl
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// Stepper is the numeric stepper, that shows the value between the − and +
+// buttons, and the OK button.  Each press edits the message in place, the
+// presses that would take the value out of the min and max bounds are rejected
+// with the alert.  The value of the Stepper is the number, the Callback receives
+// it as the Context.Data when the user presses OK.
+type Stepper struct {
+	commonCtl
+
+	removeButtons bool
+	backBtn       bool
+
+	tvc        TextCallbacker
+	okBtnTxt   Texter
+	backBtnTxt Texter
+
+	min, max int
+	initial  int
+	bigStep  int // step of the additional buttons, 0 - no additional buttons.
+}
+
+var _ Controller = &Stepper{}
+
+type StepperOption func(*Stepper)
+
+// StepOptInitial sets the value that is shown initially, the default is min.
+func StepOptInitial(n int) StepperOption {
+	return func(st *Stepper) {
+		st.initial = n
+	}
+}
+
+// StepOptBigStep adds the −n and +n buttons to the stepper.
+func StepOptBigStep(n int) StepperOption {
+	return func(st *Stepper) {
+		st.bigStep = n
+	}
+}
+
+// StepOptBtnOK sets the text of the OK button.
+func StepOptBtnOK(texter Texter) StepperOption {
+	return func(st *Stepper) {
+		st.okBtnTxt = texter
+	}
+}
+
+// StepOptBtnBack adds the back button to the stepper.
+func StepOptBtnBack(texter Texter) StepperOption {
+	return func(st *Stepper) {
+		st.backBtn = true
+		st.backBtnTxt = texter
+	}
+}
+
+// StepOptRemoveButtons set the Remove Buttons option.  If Remove Buttons is
+// set, the buttons will be removed once the user presses OK.
+func StepOptRemoveButtons(b bool) StepperOption {
+	return func(st *Stepper) {
+		st.removeButtons = b
+	}
+}
+
+func StepOptOverwrite(b bool) StepperOption {
+	return func(st *Stepper) {
+		st.commonCtl.setOverwrite(b)
+	}
+}
+
+func StepOptPrivateOnly(b bool) StepperOption {
+	return func(st *Stepper) {
+		optPrivateOnly(b)(&st.commonCtl)
+	}
+}
+
+func StepOptFallbackLang(lang string) StepperOption {
+	return func(st *Stepper) {
+		optFallbackLang(lang)(&st.commonCtl)
+	}
+}
+
+// StepOptRegistry sets the registry that stores the stepper state.
+func StepOptRegistry(reg Registry) StepperOption {
+	return func(st *Stepper) {
+		optRegistry(reg)(&st.commonCtl)
+	}
+}
+
+// StepOptScope sets the scope of the stepper state, see Scope.
+func StepOptScope(s Scope) StepperOption {
+	return func(st *Stepper) {
+		optScope(s)(&st.commonCtl)
+	}
+}
+
+// NewStepper creates a new stepper with the value between min and max
+// inclusive.  TextCallbacker.Text produces the text of the message,
+// TextCallbacker.Callback is called when the user presses OK.  It panics, if
+// min is greater than max.
+func NewStepper(name string, tc TextCallbacker, min, max int, opts ...StepperOption) *Stepper {
+	if min > max {
+		panic(fmt.Sprintf("tbcomctl: stepper %s: min %d is greater than max %d", name, min, max))
+	}
+	st := &Stepper{
+		commonCtl: newCommonCtl(name),
+		tvc:       tc,
+		okBtnTxt:  NewTexter(MsgOK),
+		min:       min,
+		max:       max,
+		initial:   min,
+	}
+	for _, opt := range opts {
+		opt(st)
+	}
+	return st
+}
+
+// Handler is a handler function to use with telebot.Handle.
+func (st *Stepper) Handler(c tb.Context) error {
+	if st.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	text, err := st.tvc.Text(WithController(context.Background(), st), c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, st.fallbackLang))
+		return fmt.Errorf("error while generating text for controller: %s: %w", st.name, err)
+	}
+	markup, err := st.inlineMarkup(c, st.initialValue(c))
+	if err != nil {
+		return fmt.Errorf("error while generating markup for controller: %s: %w", st.name, err)
+	}
+	outbound, err := st.sendOrEdit(c, text, st.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = st.reg.Register(st.recipient(c), outbound.ID)
+	st.logOutgoingMsg(outbound, "stepper")
+	return nil
+}
+
+// initialValue returns the picked value, if the user returns to the stepper,
+// or the initial value.
+func (st *Stepper) initialValue(c tb.Context) int {
+	if val, ok := st.Value(st.recipient(c).Recipient()); ok {
+		if n, err := strconv.Atoi(val); err == nil && st.inRange(n) {
+			return n
+		}
+	}
+	if !st.inRange(st.initial) {
+		return st.min
+	}
+	return st.initial
+}
+
+// inRange returns true if n is within the bounds.
+func (st *Stepper) inRange(n int) bool {
+	return st.min <= n && n <= st.max
+}
+
+// callbackID returns the ID of the stepper for the callback data.
+func (st *Stepper) callbackID() string {
+	return callbackID("stepper", st.name)
+}
+
+// Register registers the stepper callback with the dispatcher of the bot, see
+// Picklist.Register.
+func (st *Stepper) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(st.callbackID(), st, st.callback)
+}
+
+// version returns the version of the bounds for the callback data.
+func (st *Stepper) version() string {
+	return codec.Version(strconv.Itoa(st.min), strconv.Itoa(st.max))
+}
+
+// inlineMarkup generates the stepper for the value n.  The step buttons have
+// the resulting value as the payload.
+func (st *Stepper) inlineMarkup(c tb.Context, n int) (*tb.ReplyMarkup, error) {
+	var labels, payloads []string
+	add := func(label, payload string) {
+		labels, payloads = append(labels, label), append(payloads, payload)
+	}
+	if st.bigStep > 0 {
+		add(stepperDown+strconv.Itoa(st.bigStep), payloadSet+strconv.Itoa(n-st.bigStep))
+	}
+	add(stepperDown, payloadSet+strconv.Itoa(n-1))
+	add(strconv.Itoa(n), payloadNoop)
+	add(stepperUp, payloadSet+strconv.Itoa(n+1))
+	if st.bigStep > 0 {
+		add(stepperUp+strconv.Itoa(st.bigStep), payloadSet+strconv.Itoa(n+st.bigStep))
+	}
+	steps := len(labels)
+
+	okTxt, err := st.okBtnTxt.Text(context.Background(), c)
+	if err != nil {
+		dlg.Printf("okTextFn returned an error: %s", err)
+	}
+	add(PrinterContext(c, st.fallbackLang).Sprintf(okTxt), payloadPick+strconv.Itoa(n))
+	if st.backBtn {
+		txt, err := st.backBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("backTextFn returned an error: %s", err)
+		}
+		add(PrinterContext(c, st.fallbackLang).Sprintf(txt), payloadBack)
+	}
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, st.callbackID(), st.version(), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	rows := []tb.Row{btns[:steps]}
+	for _, btn := range btns[steps:] {
+		rows = append(rows, tb.Row{btn})
+	}
+	markup.Inline(rows...)
+	st.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (st *Stepper) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "Stepper.Callback")
+	defer task.End()
+
+	st.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, st.callbackID(), st.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if d.Version != st.version() {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, st.fallbackLang)
+	}
+	switch d.Payload {
+	case payloadNoop:
+		return c.Respond(&tb.CallbackResponse{})
+	case payloadBack:
+		trace.Log(ctx, "callback", "back is pressed (option)")
+		return st.handleBackButton(ctx, c)
+	}
+	if len(d.Payload) < 2 {
+		return respondStale(c, st.fallbackLang)
+	}
+	n, err := strconv.Atoi(d.Payload[1:])
+	if err != nil {
+		return respondStale(c, st.fallbackLang)
+	}
+	if !st.inRange(n) {
+		trace.Log(ctx, "callback", "out of range")
+		return respondAlert(c, PrinterContext(c, st.fallbackLang).Sprintf(MsgOutOfRange, st.min, st.max))
+	}
+	switch d.Payload[:1] {
+	case payloadSet:
+		markup, err := st.inlineMarkup(c, n)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+			trace.Log(ctx, "EditReplyMarkup", err.Error())
+		}
+		return c.Respond(&tb.CallbackResponse{})
+	case payloadPick:
+		return st.done(ctx, c, n)
+	}
+	return respondStale(c, st.fallbackLang)
+}
+
+// done calls the user callback with the picked value n and invokes the next
+// handler.
+func (st *Stepper) done(ctx context.Context, c tb.Context, n int) error {
+	var update func(tb.Context) error
+	if st.removeButtons {
+		update = removeMarkup
+	}
+	return st.pickValue(ctx, c, st, st.tvc, strconv.Itoa(n), update)
+}
@@ -0,0 +1,128 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestStepper(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "ru"}
+
+	var got []string
+	st := NewStepper("qty", NewStaticTVC("Quantity?", nil, func(_ context.Context, c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	}), 1, 20, StepOptInitial(3), StepOptBigStep(10))
+	var completed map[string]string
+	NewForm(st).OnComplete(func(_ context.Context, _ tb.Context, data map[string]string) error {
+		completed = data
+		return nil
+	})
+	c := newTestCallback(b, user, "")
+
+	m, err := st.inlineMarkup(c, st.initialValue(c))
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"−10", stepperDown, "3", stepperUp, "+10"}, {MsgOK}}, markupLabels(m))
+
+	// out of range press is rejected.
+	press := pressButton(t, b, user, m, "−10")
+	assert.NoError(t, st.callback(press))
+	assert.Equal(t, &tb.CallbackResponse{Text: "Значение должно быть от 1 до 20.", ShowAlert: true}, press.lastResponse())
+	assert.Empty(t, log.methods())
+
+	// the message is edited in place.
+	assert.NoError(t, st.callback(pressButton(t, b, user, m, "+10")))
+	assert.Equal(t, []string{"editMessageReplyMarkup"}, log.methods())
+	assert.Contains(t, log.call(0).Params["reply_markup"], `"text":"13"`)
+
+	m, _ = st.inlineMarkup(c, 13)
+	assert.NoError(t, st.callback(pressButton(t, b, user, m, MsgOK)))
+	assert.Equal(t, []string{"13"}, got)
+	assert.Equal(t, map[string]string{"qty": "13"}, completed)
+	assert.Equal(t, 13, st.initialValue(c), "stepper returns to the picked value")
+
+	// the bounds have changed.
+	old := pressButton(t, b, user, m, stepperUp)
+	st.max = 10
+	assert.NoError(t, st.callback(old))
+	assert.Equal(t, "⌛ Эта кнопка устарела, попробуйте еще раз.", old.lastResponse().Text)
+	assert.Equal(t, 3, st.initialValue(c), "picked value out of the new bounds is replaced with the initial value")
+}
+
+func TestStepper_bounds(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	c := newTestCallback(b, user, "")
+
+	assert.Panics(t, func() { NewStepper("inverted", nil, 2, 1) })
+
+	st := NewStepper("single", nil, 5, 5, StepOptInitial(100))
+	assert.Equal(t, 5, st.initialValue(c), "initial value out of the bounds is replaced with min")
+	m, err := st.inlineMarkup(c, 5)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, label := range []string{stepperUp, stepperDown} {
+		press := pressButton(t, b, user, m, label)
+		assert.NoError(t, st.callback(press))
+		assert.Equal(t, &tb.CallbackResponse{Text: "The value must be between 5 and 5.", ShowAlert: true}, press.lastResponse())
+	}
+}
+
+func TestStepper_back(t *testing.T) {
+	b := newTestBot(t)
+	c := newTestCallback(b, &tb.User{ID: 42}, "")
+	st := NewStepper("back", nil, 1, 5, StepOptBtnBack(NewTexter(MsgBack)), StepOptFallbackLang("ru"))
+	m, err := st.inlineMarkup(c, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{stepperDown, "1", stepperUp}, {MsgOK}, {"⬅ Назад"}}, markupLabels(m), "back label is translated")
+}
+
+func TestStepper_callbackError(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	var back bool
+	prev := NewMessage("prev", NewTexter("Previous"))
+	st := NewStepper("qty", NewStaticTVC("Quantity?", nil, func(_ context.Context, c tb.Context) error {
+		switch c.Data() {
+		case "1":
+			return &Error{Type: TErrRetry, Msg: "not yet", Alert: true}
+		case "2":
+			back = true
+			return BackPressed
+		}
+		return nil
+	}), 1, 3, StepOptRemoveButtons(true))
+	NewForm(prev, st)
+	c := newTestCallback(b, user, "")
+
+	m, _ := st.inlineMarkup(c, 1)
+	press := pressButton(t, b, user, m, MsgOK)
+	assert.Error(t, st.callback(press))
+	assert.Equal(t, &tb.CallbackResponse{Text: "not yet", ShowAlert: true}, press.lastResponse())
+	_, ok := st.Value(user.Recipient())
+	assert.False(t, ok, "value is not stored on retry")
+	assert.Empty(t, log.methods())
+
+	m, _ = st.inlineMarkup(c, 2)
+	assert.NoError(t, st.callback(pressButton(t, b, user, m, MsgOK)))
+	assert.True(t, back)
+	_, ok = st.Value(user.Recipient())
+	assert.False(t, ok, "value is not stored when the user goes back")
+
+	m, _ = st.inlineMarkup(c, 3)
+	n := len(log.methods())
+	assert.NoError(t, st.callback(pressButton(t, b, user, m, MsgOK)))
+	val, _ := st.Value(user.Recipient())
+	assert.Equal(t, "3", val)
+	assert.Equal(t, "editMessageReplyMarkup", log.call(n).Method, "buttons are removed")
+}
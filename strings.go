@@ -3,6 +3,7 @@ package tbcomctl
 import (
 	"crypto/rand"
 	"io"
+	"strings"
 
 	"golang.org/x/text/language"
 	"golang.org/x/text/message"
@@ -31,6 +32,12 @@ func PrinterContext(c tb.Context, fallback ...string) *message.Printer {
 // Printer returns the Message Printer for the desired lang.  If the lang is not
 // valid, the fallback languages will be used, if set.
 func Printer(lang string, fallback ...string) *message.Printer {
+	return message.NewPrinter(langTag(lang, fallback...))
+}
+
+// langTag returns the language tag for lang, or the fallback language tag, if
+// lang is not valid.
+func langTag(lang string, fallback ...string) language.Tag {
 	tag, err := language.Parse(lang)
 	if err != nil {
 		if len(fallback) > 0 && fallback[0] != "" {
@@ -39,9 +46,31 @@ func Printer(lang string, fallback ...string) *message.Printer {
 			tag = language.MustParse(FallbackLang)
 		}
 	}
-	return message.NewPrinter(tag)
+	return tag
+}
+
+// translate returns the translation of the text s for the user language.
+// Unlike the Printer, it does not treat s and its translation as the format
+// string, so it is safe for the arbitrary text, i.e. "Get 50% off".  If there's
+// no translation, s is returned as is.
+func translate(c tb.Context, s string, fallback ...string) string {
+	var r textRenderer
+	tag := langTag(c.Sender().LanguageCode, fallback...)
+	if err := message.DefaultCatalog.Context(tag, &r).Execute(s); err != nil {
+		return s
+	}
+	return r.String()
+}
+
+// textRenderer renders the catalog message as the plain text.
+type textRenderer struct {
+	strings.Builder
 }
 
+func (r *textRenderer) Render(s string) { r.WriteString(s) }
+
+func (r *textRenderer) Arg(int) interface{} { return nil }
+
 const (
 	randStringCharset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
 	randStringSz      = len(randStringCharset)
@@ -0,0 +1,226 @@
+package tbcomctl
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// skipValue is the user input that skips the optional input.
+const skipValue = "-"
+
+// structField is the description of the form controller generated from the
+// struct field.
+type structField struct {
+	typ      reflect.Type
+	tag      fieldTag
+	prompt   string         // text shown to the user
+	i18nKey  string         // i18n key of the text, if any
+	choices  []string       // choices for the picklist
+	required bool           // input can't be skipped
+	re       *regexp.Regexp // validation regexp, if any
+}
+
+// NewFormFromStruct creates the Form from the struct definition.  v must be a
+// struct or a pointer to one.  Each field with the "form" tag (see Form.Bind)
+// becomes a controller, in the order of the fields:
+//
+//   - string, numeric and time.Time fields become Inputs, the input is
+//     validated to be convertible to the field type;
+//   - fields with choices become Picklists;
+//   - slice fields with choices become Checklists, the required Checklist
+//     needs at least one value to be selected;
+//   - bool fields become Yes/No Picklists, the labels are translated for the
+//     user, the stored values are "true" and "false";
+//   - pointer fields become the controllers of the element type, the
+//     pointer is left nil if the optional input is skipped.
+//
+// Additional tags describe the controller:
+//
+//	prompt:"Text"        - the text shown to the user, it is translated with
+//	                       the package Printer;
+//	i18n:"key"           - the i18n message key for the text, the prompt is
+//	                       used if the key has no translation;
+//	choices:"a|b|c"      - the values for the Picklist;
+//	required:"false"     - the input is optional and can be skipped, inputs
+//	                       are required by default;
+//	validate:"^\\d{5}$"  - the regular expression that the input must match.
+//
+// Example:
+//
+//	type Signup struct {
+//		Name   string `form:"name" prompt:"What's your name?"`
+//		Age    int    `form:"age" prompt:"How old are you?"`
+//		Plan   string `form:"plan" prompt:"Choose the plan" choices:"free|pro"`
+//		Promo  string `form:"promo" prompt:"Promo code" required:"false" validate:"^[A-Z0-9]{6}$"`
+//		Agreed bool   `form:"agreed" prompt:"Do you accept the terms?"`
+//	}
+//
+//	fm, err := NewFormFromStruct(Signup{})
+//
+// Once the form is complete, the data can be decoded with Form.Bind.
+func NewFormFromStruct(v interface{}) (*Form, error) {
+	t := reflect.TypeOf(v)
+	if t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, errors.New("form: struct expected")
+	}
+	var ctrls []Controller
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		tag, ok := sf.Tag.Lookup(bindTag)
+		if !ok || tag == "-" || sf.PkgPath != "" {
+			continue
+		}
+		fld, err := parseStructField(sf, tag)
+		if err != nil {
+			return nil, fmt.Errorf("form: field %s: %w", sf.Name, err)
+		}
+		ctrls = append(ctrls, fld.controller())
+	}
+	if len(ctrls) == 0 {
+		return nil, errors.New("form: struct has no fields with the form tag")
+	}
+	return NewForm(ctrls...), nil
+}
+
+// parseStructField parses the struct field tags.
+func parseStructField(sf reflect.StructField, tag string) (*structField, error) {
+	fld := &structField{
+		typ:      sf.Type,
+		tag:      parseTag(tag),
+		prompt:   sf.Tag.Get("prompt"),
+		i18nKey:  sf.Tag.Get("i18n"),
+		required: true,
+	}
+	if fld.tag.name == "" {
+		fld.tag.name = sf.Name
+	}
+	if fld.prompt == "" {
+		fld.prompt = sf.Name
+	}
+	if s, ok := sf.Tag.Lookup("required"); ok {
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid required tag: %w", err)
+		}
+		fld.required = b
+	}
+	if s := sf.Tag.Get("validate"); s != "" {
+		re, err := regexp.Compile(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid validate tag: %w", err)
+		}
+		fld.re = re
+	}
+	if s := sf.Tag.Get("choices"); s != "" {
+		fld.choices = strings.Split(s, "|")
+	}
+
+	// checking that the type is supported.
+	if fld.kind() == reflect.Bool {
+		fld.choices = []string{"true", "false"}
+	}
+	if _, err := encodeValue(reflect.Zero(fld.base()), fld.tag); err != nil {
+		return nil, err
+	}
+	if fld.kind() == reflect.Slice && len(fld.choices) == 0 {
+		return nil, fmt.Errorf("slice field %s requires choices", fld.typ)
+	}
+	return fld, nil
+}
+
+// base returns the field type, or the element type for the pointer fields.
+func (fld *structField) base() reflect.Type {
+	if fld.typ.Kind() == reflect.Ptr {
+		return fld.typ.Elem()
+	}
+	return fld.typ
+}
+
+// kind returns the kind of the base type of the field.
+func (fld *structField) kind() reflect.Kind {
+	return fld.base().Kind()
+}
+
+// controller creates the controller for the field.
+func (fld *structField) controller() Controller {
+	if fld.kind() == reflect.Slice {
+		var min int
+		if fld.required {
+			min = 1
+		}
+		return NewChecklist(fld.tag.name, &TVC{
+			TextFn:   fld.text,
+			ValuesFn: func(context.Context, tb.Context) ([]string, error) { return fld.choices, nil },
+			CBfn:     func(context.Context, tb.Context) error { return nil },
+		}, CLOptMin(min))
+	}
+	if fld.kind() == reflect.Bool {
+		return NewPicklist(fld.tag.name, &choiceTVC{
+			TVC: &TVC{
+				TextFn: fld.text,
+				CBfn:   func(context.Context, tb.Context) error { return nil },
+			},
+			choices: []Choice{{Key: "true", Label: MsgYes}, {Key: "false", Label: MsgNo}},
+		})
+	}
+	if len(fld.choices) > 0 {
+		return NewPicklist(fld.tag.name, &TVC{
+			TextFn:   fld.text,
+			ValuesFn: func(context.Context, tb.Context) ([]string, error) { return fld.choices, nil },
+			CBfn:     func(context.Context, tb.Context) error { return nil },
+		})
+	}
+	return NewInput(
+		fld.tag.name,
+		&TVC{TextFn: fld.text, CBfn: fld.validate},
+		IOptValueResolver(fld.resolve),
+	)
+}
+
+// text returns the text for the user.
+func (fld *structField) text(_ context.Context, c tb.Context) (string, error) {
+	pr := PrinterContext(c)
+	txt := pr.Sprintf(fld.prompt)
+	if fld.i18nKey != "" {
+		if tr := pr.Sprintf(fld.i18nKey); tr != fld.i18nKey {
+			txt = tr
+		}
+	}
+	if len(fld.choices) == 0 && !fld.required {
+		txt += "\n\n" + pr.Sprintf(MsgSkipHint)
+	}
+	return txt, nil
+}
+
+// validate validates the user input.
+func (fld *structField) validate(_ context.Context, c tb.Context) error {
+	text := c.Message().Text
+	if !fld.required && text == skipValue {
+		return nil
+	}
+	if err := decodeValue(reflect.New(fld.typ).Elem(), text, fld.tag); err != nil {
+		return NewInputError(PrinterContext(c).Sprintf(MsgInvalidValue))
+	}
+	if fld.re != nil && !fld.re.MatchString(text) {
+		return NewInputError(PrinterContext(c).Sprintf(MsgInvalidValue))
+	}
+	return nil
+}
+
+// resolve returns the value to store in the registry.
+func (fld *structField) resolve(m *tb.Message) (string, error) {
+	if !fld.required && m.Text == skipValue {
+		return "", nil
+	}
+	return m.Text, nil
+}
@@ -0,0 +1,127 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+type testSignup struct {
+	Name   string   `form:"name" prompt:"What's your name?"`
+	Age    int      `form:"age" prompt:"How old are you?"`
+	Plan   string   `form:"plan" prompt:"Choose the plan" choices:"free|pro"`
+	Promo  string   `form:"promo" prompt:"Promo code" required:"false" validate:"^[A-Z0-9]{6}$"`
+	Agreed bool     `form:"agreed" i18n:"tbcomctl.test.agreed" prompt:"Do you accept the terms?"`
+	Topics []string `form:"topics" prompt:"Topics of interest" choices:"go|rust|zig" required:"false"`
+	Notes  string
+}
+
+func TestNewFormFromStruct(t *testing.T) {
+	fm, err := NewFormFromStruct(&testSignup{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, ctrl := range fm.ctrls {
+		names = append(names, ctrl.Name())
+	}
+	assert.Equal(t, []string{"name", "age", "plan", "promo", "agreed", "topics"}, names)
+	assert.IsType(t, &Input{}, fm.cm["age"])
+	assert.IsType(t, &Picklist{}, fm.cm["plan"])
+	assert.IsType(t, &Picklist{}, fm.cm["agreed"])
+	if assert.IsType(t, &Checklist{}, fm.cm["topics"]) {
+		assert.Equal(t, 0, fm.cm["topics"].(*Checklist).min)
+	}
+
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	c := newTestContext(user)
+	ctx := context.Background()
+
+	promo := fm.cm["promo"].(*Input)
+	text, err := promo.tc.Text(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "Promo code\n\n"+MsgSkipHint, text)
+
+	agreed := fm.cm["agreed"].(*Picklist)
+	text, err = agreed.tvc.Text(ctx, c)
+	assert.NoError(t, err)
+	assert.Equal(t, "Do you accept the terms?", text, "prompt is used if the i18n key has no translation")
+	choices, _, err := agreed.choices(ctx, c, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Choice{{Key: "true", Label: MsgYes}, {Key: "false", Label: MsgNo}}, choices)
+
+	validate := func(ip *Input, text string) error {
+		c.msg = &tb.Message{Text: text}
+		return ip.tc.Callback(ctx, c)
+	}
+	age := fm.cm["age"].(*Input)
+	assert.NoError(t, validate(age, "42"))
+	assert.Error(t, validate(age, "forty two"))
+	assert.Error(t, validate(age, skipValue), "required input can't be skipped")
+	assert.NoError(t, validate(promo, "ABC123"))
+	assert.Error(t, validate(promo, "abc"))
+	assert.NoError(t, validate(promo, skipValue))
+	val, _ := promo.valueResolverFn(&tb.Message{Text: skipValue})
+	assert.Equal(t, "", val)
+
+	// the data is bound to the struct.
+	r := user.Recipient()
+	fm.cm["name"].(valueSetter).SetValue(r, "Bob")
+	age.SetValue(r, "42")
+	fm.cm["plan"].(valueSetter).SetValue(r, "pro")
+	promo.SetValue(r, "")
+	agreed.SetValue(r, "true")
+	fm.cm["topics"].(valueSetter).SetValue(r, encodeList([]string{"go", "zig"}))
+	var got testSignup
+	assert.NoError(t, fm.Bind(user, &got))
+	assert.Equal(t, testSignup{Name: "Bob", Age: 42, Plan: "pro", Agreed: true, Topics: []string{"go", "zig"}}, got)
+}
+
+func TestNewFormFromStruct_errors(t *testing.T) {
+	_, err := NewFormFromStruct("not a struct")
+	assert.Error(t, err)
+	_, err = NewFormFromStruct(struct{ A string }{})
+	assert.Error(t, err)
+	_, err = NewFormFromStruct(struct {
+		A string `form:"a" validate:"("`
+	}{})
+	assert.Error(t, err)
+	_, err = NewFormFromStruct(struct {
+		A map[string]string `form:"a"`
+	}{})
+	assert.Error(t, err)
+	_, err = NewFormFromStruct(struct {
+		A []string `form:"a"`
+	}{})
+	assert.Error(t, err)
+}
+
+func TestNewFormFromStruct_pointers(t *testing.T) {
+	type testPtr struct {
+		Age    *int  `form:"age" required:"false"`
+		Agreed *bool `form:"agreed"`
+	}
+	fm, err := NewFormFromStruct(testPtr{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.IsType(t, &Input{}, fm.cm["age"])
+	assert.IsType(t, &Picklist{}, fm.cm["agreed"])
+
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+	c := newTestContext(user)
+	c.msg = &tb.Message{Text: "forty two"}
+	assert.Error(t, fm.cm["age"].(*Input).tc.Callback(context.Background(), c))
+
+	r := user.Recipient()
+	fm.cm["age"].(valueSetter).SetValue(r, "")
+	fm.cm["agreed"].(valueSetter).SetValue(r, "true")
+	var got testPtr
+	assert.NoError(t, fm.Bind(user, &got))
+	assert.Nil(t, got.Age, "skipped input leaves the pointer nil")
+	if assert.NotNil(t, got.Agreed) {
+		assert.True(t, *got.Agreed)
+	}
+}
@@ -3,6 +3,7 @@ package tbcomctl
 import (
 	"context"
 	"fmt"
+	"time"
 
 	tb "gopkg.in/telebot.v3"
 )
@@ -30,6 +31,20 @@ func SCOptFallbackLang(lang string) SCOption {
 	}
 }
 
+// SCOptScope sets the scope of the subscription checker state, see Scope.
+func SCOptScope(s Scope) SCOption {
+	return func(sc *SubChecker) {
+		sc.setScope(s)
+	}
+}
+
+// SCOptRegistry sets the registry that stores the subscription checker state.
+func SCOptRegistry(reg Registry) SCOption {
+	return func(sc *SubChecker) {
+		sc.setRegistry(reg)
+	}
+}
+
 // NewSubChecker creates new subscription checker that checks the subscription
 // on the desired channels.  Boter must be added to channels for this to work.
 func NewSubChecker(name string, t Texter, chats []int64, opts ...SCOption) *SubChecker {
@@ -37,19 +52,46 @@ func NewSubChecker(name string, t Texter, chats []int64, opts ...SCOption) *SubC
 		commonCtl: newCommonCtl(name),
 		chats:     chats,
 	}
-	for _, o := range opts {
-		o(sc)
-	}
 	// SubChecker uses picklist for its filthy job.
-	pl := NewPicklist(
-		"$subcheck"+randString(8), // assigning a fake name
+	sc.pl = NewPicklist(
+		"$subcheck:"+name, // assigning a fake name, stable between restarts.
 		&TVC{TextFn: t.Text, ValuesFn: sc.valuesFn, CBfn: sc.callback},
 		PickOptRemoveButtons(true),
 	)
-	sc.pl = pl
+	for _, o := range opts {
+		o(sc)
+	}
 	return sc
 }
 
+// setRegistry sets the registry for the subscription checker and the
+// underlying picklist.
+func (sc *SubChecker) setRegistry(reg Registry) {
+	sc.commonCtl.setRegistry(reg)
+	sc.pl.setRegistry(reg)
+}
+
+// setScope sets the scope of the subscription checker and the underlying
+// picklist.
+func (sc *SubChecker) setScope(s Scope) {
+	sc.commonCtl.setScope(s)
+	sc.pl.setScope(s)
+}
+
+// expire removes the expired entries of the subscription checker and the
+// underlying picklist.
+func (sc *SubChecker) expire(ctx context.Context, b *tb.Bot, before time.Time) []string {
+	sc.pl.expire(ctx, b, before)
+	return sc.commonCtl.expire(ctx, b, before)
+}
+
+// clear removes the entries of the recipient of the subscription checker and
+// the underlying picklist.
+func (sc *SubChecker) clear(recipient string) {
+	sc.pl.clear(recipient)
+	sc.commonCtl.clear(recipient)
+}
+
 func (sc *SubChecker) valuesFn(_ context.Context, c tb.Context) ([]string, error) {
 	p := PrinterContext(c, sc.pl.fallbackLang)
 	return []string{p.Sprintf(MsgSubCheck)}, nil
@@ -83,6 +125,27 @@ func (sc *SubChecker) callback(_ context.Context, c tb.Context) error {
 	return nil
 }
 
+// SetNext sets the next controller for the subscription checker and the
+// underlying picklist.
+func (sc *SubChecker) SetNext(ctrl Controller) {
+	sc.commonCtl.SetNext(ctrl)
+	sc.pl.SetNext(ctrl)
+}
+
+// SetPrev sets the previous controller for the subscription checker and the
+// underlying picklist.
+func (sc *SubChecker) SetPrev(ctrl Controller) {
+	sc.commonCtl.SetPrev(ctrl)
+	sc.pl.SetPrev(ctrl)
+}
+
+// SetForm links the subscription checker and the underlying picklist to the
+// form.
+func (sc *SubChecker) SetForm(fm *Form) {
+	sc.commonCtl.SetForm(fm)
+	sc.pl.SetForm(fm)
+}
+
 func (sc *SubChecker) Handler(c tb.Context) error {
 	return sc.pl.Handler(c)
 }
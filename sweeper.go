@@ -0,0 +1,101 @@
+package tbcomctl
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+)
+
+// ExpireFunc is the function that is called when the controller stops
+// awaiting the user response, because the waiting entry has expired.  b is the
+// bot passed to StartSweeper.  r is the recipient of the controller state, see
+// Scope: it is the tb.ChatID of the user or the chat, or the ChatUser, or, for
+// custom scopes, the recipient with the registry key.  ctx contains the
+// controller, see ControllerFromCtx.
+type ExpireFunc func(ctx context.Context, b *tb.Bot, r tb.Recipient)
+
+// expirer is the interface for the controllers that support expiry of their
+// registry entries.
+type expirer interface {
+	expire(ctx context.Context, b *tb.Bot, before time.Time) []string
+}
+
+// clearer is the interface for the controllers that can clear the state of
+// the recipient.
+type clearer interface {
+	clear(recipient string)
+}
+
+// StartSweeper starts the background sweeper, that, every interval, removes
+// the registry entries of ctrls that were not updated for longer than ttl.
+// Entries expire individually, so the ttl should be long enough for the user
+// to complete the form.  Registries that do not implement Expirer are not
+// swept.  b is passed to the expiry functions of the controllers, see
+// IOptOnExpire.  Sweeper stops when the ctx is cancelled.
+func StartSweeper(ctx context.Context, b *tb.Bot, interval, ttl time.Duration, ctrls ...Controller) {
+	startSweeper(ctx, interval, ttl, func(before time.Time) {
+		sweep(ctx, b, before, ctrls...)
+	})
+}
+
+// startSweeper calls fn every interval with the time, before which the entries
+// have expired, until the ctx is cancelled.
+func startSweeper(ctx context.Context, interval, ttl time.Duration, fn func(before time.Time)) {
+	go func() {
+		t := time.NewTicker(interval)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case now := <-t.C:
+				fn(now.Add(-ttl))
+			}
+		}
+	}()
+}
+
+// sweep expires the registry entries of ctrls that were updated before the
+// given time.
+func sweep(ctx context.Context, b *tb.Bot, before time.Time, ctrls ...Controller) {
+	for _, ctrl := range ctrls {
+		if e, ok := ctrl.(expirer); ok {
+			if expired := e.expire(ctx, b, before); len(expired) > 0 {
+				dlg.Printf("%s: expired %d waiting entries", ctrl.Name(), len(expired))
+			}
+		}
+	}
+}
+
+// expire removes the registry entries that were updated before the given time.
+// It returns the recipients that were awaited for the response.
+func (cc *commonCtl) expire(_ context.Context, _ *tb.Bot, before time.Time) []string {
+	e, ok := cc.reg.(Expirer)
+	if !ok {
+		return nil
+	}
+	return e.Expire("", before)
+}
+
+// clear removes the registry entries of the recipient.
+func (cc *commonCtl) clear(recipient string) {
+	cc.reg.Clear(recipient)
+}
+
+// keyRecipient returns the recipient for the registry key, see ExpireFunc.
+func keyRecipient(key string) tb.Recipient {
+	if id, err := strconv.ParseInt(key, 10, 64); err == nil {
+		return tb.ChatID(id)
+	}
+	if i := strings.IndexByte(key, ':'); i > 0 {
+		chatID, err1 := strconv.ParseInt(key[:i], 10, 64)
+		userID, err2 := strconv.ParseInt(key[i+1:], 10, 64)
+		if err1 == nil && err2 == nil {
+			return ChatUser{ChatID: chatID, UserID: userID}
+		}
+	}
+	return nsRecipient(key)
+}
@@ -0,0 +1,98 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestInput_expire(t *testing.T) {
+	var (
+		user    = &tb.User{ID: 42}
+		reg     = NewMemRegistry()
+		b       = newTestBot(t)
+		expired []tb.Recipient
+	)
+	ip := NewInputText("name", "enter your name", nil,
+		IOptRegistry(reg),
+		IOptOnExpire(func(ctx context.Context, bot *tb.Bot, r tb.Recipient) {
+			ctrl, ok := ControllerFromCtx(ctx)
+			assert.True(t, ok)
+			assert.Equal(t, "name", ctrl.Name())
+			assert.Equal(t, b, bot)
+			expired = append(expired, r)
+		}),
+	)
+	other := NewInputText("other", "enter something", nil, IOptRegistry(reg))
+	// the namespace of "name" is the prefix of the namespace of "name:other".
+	prefixed := NewInputText("name:other", "enter something", nil, IOptRegistry(reg))
+	ip.reg.Wait(user, 100)
+	ip.SetValue(user.Recipient(), "value")
+	other.reg.Wait(user, 101)
+	prefixed.reg.Wait(user, 102)
+
+	sweep(context.Background(), b, time.Now().Add(-time.Hour), ip, other)
+	assert.Empty(t, expired)
+	assert.True(t, ip.reg.IsWaiting(user))
+
+	sweep(context.Background(), b, time.Now().Add(time.Hour), ip)
+	assert.Equal(t, []tb.Recipient{tb.ChatID(user.ID)}, expired)
+	assert.False(t, ip.reg.IsWaiting(user))
+	_, ok := ip.Value(user.Recipient())
+	assert.False(t, ok)
+	// the other controls share the registry, but must not be affected.
+	assert.True(t, other.reg.IsWaiting(user))
+	assert.True(t, prefixed.reg.IsWaiting(user))
+}
+
+func TestKeyRecipient(t *testing.T) {
+	tests := []struct {
+		key  string
+		want tb.Recipient
+	}{
+		{"42", tb.ChatID(42)},
+		{"-100123", tb.ChatID(-100123)},
+		{"-100123:42", ChatUser{ChatID: -100123, UserID: 42}},
+		{"custom:key", nsRecipient("custom:key")},
+	}
+	for _, tt := range tests {
+		t.Run(tt.key, func(t *testing.T) {
+			r := keyRecipient(tt.key)
+			assert.Equal(t, tt.want, r)
+			assert.Equal(t, tt.key, r.Recipient())
+		})
+	}
+}
+
+func TestForm_expire(t *testing.T) {
+	const r = "42"
+	reg := NewMemRegistry()
+	ip := NewInputText("name", "enter your name", nil)
+	fm := NewFormRegistry(reg, ip)
+
+	fm.resetPath(r)
+	fm.setDone(r, true)
+	ip.SetValue(r, "value")
+	st, _ := fm.state(r)
+	assert.Equal(t, formState{Done: true, Path: []string{"name"}}, st)
+
+	fm.expire(time.Now().Add(-time.Hour))
+	st, _ = fm.state(r)
+	assert.True(t, st.Done)
+
+	fm.expire(time.Now().Add(time.Hour))
+	st, _ = fm.state(r)
+	assert.Equal(t, formState{}, st)
+	_, ok := ip.Value(r)
+	assert.True(t, ok, "form state is separate from the controller values")
+
+	fm.setDone(r, true)
+	fm.Reset(r)
+	st, _ = fm.state(r)
+	assert.Equal(t, formState{}, st)
+	_, ok = ip.Value(r)
+	assert.False(t, ok)
+}
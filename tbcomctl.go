@@ -3,14 +3,12 @@ package tbcomctl
 
 import (
 	"context"
-	"crypto/sha1"
-	"fmt"
+	"errors"
+	"runtime/trace"
 	"strconv"
 
 	"golang.org/x/text/language"
 	tb "gopkg.in/telebot.v3"
-
-	"github.com/rusq/tbcomctl/v4/internal/registry"
 )
 
 const (
@@ -22,6 +20,11 @@ type overwriter interface {
 	setOverwrite(b bool)
 }
 
+// waiter is the interface for the controllers that wait for the user input.
+type waiter interface {
+	stopWait(c tb.Context)
+}
+
 type commonCtl struct {
 	name string // name of the control, must be unique if used within chained controls.
 
@@ -37,7 +40,8 @@ type commonCtl struct {
 	fallbackLang string          // fallback language for i18n
 	sendOpts     *tb.SendOptions // default send options.
 
-	reg *registry.Memory
+	reg   Registry
+	scope Scope // derives the registry key from the context, if nil - the sender.
 }
 
 // PrivateOnly is the middleware that restricts the handler to only private
@@ -85,15 +89,6 @@ func (m StoredMessage) MessageSig() (string, int64) {
 	return m.MessageID, m.ChatID
 }
 
-var hasher = sha1.New
-
-// hash returns the hash of the s, using the hasher function.
-func hash(s string) string {
-	h := hasher()
-	h.Write([]byte(s))
-	return fmt.Sprintf("%x", h.Sum(nil))
-}
-
 // option is the function signature for options that are common to all the
 // controls. Concrete control implementations should use these options, if they
 // must implement this functionality.
@@ -129,12 +124,19 @@ func optDefaultSendOpts(opts *tb.SendOptions) option {
 	}
 }
 
+// optRegistry sets the registry for the control.
+func optRegistry(reg Registry) option {
+	return func(ctl *commonCtl) {
+		ctl.setRegistry(reg)
+	}
+}
+
 // newCommonCtl creates a new commonCtl instance.  It gives most of the
 // functions that satisfy Controller interface for free.
 func newCommonCtl(name string) commonCtl {
 	return commonCtl{
 		name:     name,
-		reg:      registry.NewMemRegistry(),
+		reg:      NewMemRegistry(),
 		sendOpts: &tb.SendOptions{ParseMode: tb.ModeHTML},
 	}
 }
@@ -142,8 +144,8 @@ func newCommonCtl(name string) commonCtl {
 // multibuttonMarkup returns a markup containing a bunch of buttons.  If
 // showCounter is true, will show a counter beside each of the labels. each
 // telegram button will have a button index pressed by the user in the
-// callback.Data. Prefix is the prefix that will be prepended to the unique
-// before hash is called to form the Control-specific unique fields.
+// callback data payload. Prefix is the prefix that is used with the control
+// name to form the Control-specific callback ID, see callbackID.
 func (cc *commonCtl) multibuttonMarkup(b *tb.Bot, btns []Button, showCounter bool, prefix string, cbFn func(tb.Context) error) *tb.ReplyMarkup {
 	const (
 		sep = ": "
@@ -153,18 +155,29 @@ func (cc *commonCtl) multibuttonMarkup(b *tb.Bot, btns []Button, showCounter boo
 	}
 	markup := new(tb.ReplyMarkup)
 
-	var buttons []tb.Btn
+	labels := make([]string, len(btns))
+	payloads := make([]string, len(btns))
 	for i, ri := range btns {
-		bn := markup.Data(ri.label(showCounter, sep), hash(prefix+ri.Name), strconv.Itoa(i))
-		buttons = append(buttons, bn)
-		b.Handle(&bn, cbFn)
+		labels[i] = ri.label(showCounter, sep)
+		payloads[i] = strconv.Itoa(i)
+	}
+	id := callbackID(prefix, cc.name)
+	buttons, err := encodedButtons(markup, id, multibuttonVersion, labels, payloads)
+	if err != nil {
+		panic(err) // can't happen, the payloads are short.
 	}
+	DispatcherFor(b).handle(id, cc, cbFn)
 
 	markup.Inline(OrganizeButtons(buttons, defNumButtons)...)
 
 	return markup
 }
 
+// multibuttonVersion is the callback data version of the multibutton markup.
+// The buttons of the posts are long lived, so the version does not depend on
+// the button labels.
+const multibuttonVersion = "1"
+
 // SetNext sets next controller in the chain.
 func (cc *commonCtl) SetNext(ctrl Controller) {
 	if ctrl != nil {
@@ -179,6 +192,96 @@ func (cc *commonCtl) SetPrev(ctrl Controller) {
 	}
 }
 
+// nextHandler runs the next handler, if it's available, or completes the form,
+// if the controller is the last one in the form.
+func (cc *commonCtl) nextHandler(c tb.Context) error {
+	if cc.form != nil {
+		return cc.form.forward(c, cc.name)
+	}
+	if cc.next != nil {
+		return cc.next.Handler(c)
+	}
+	return nil
+}
+
+// prevHandler runs the handler of the controller that was shown to the user
+// before this one.
+func (cc *commonCtl) prevHandler(c tb.Context) error {
+	if cc.form != nil {
+		return cc.form.back(c, cc.name)
+	}
+	if cc.prev != nil {
+		return cc.prev.Handler(c)
+	}
+	return nil
+}
+
+// pickValue calls the callback of the controller ctrl with the value picked by
+// the user, that it receives as the Context.Data, stores the value and invokes
+// the next handler.  update is called once the value is stored, to update the
+// message of the controller, i.e. to remove the buttons, it may be nil.
+func (cc *commonCtl) pickValue(ctx context.Context, c tb.Context, ctrl Controller, tc TextCallbacker, value string, update func(c tb.Context) error) error {
+	c = withCallbackData(c, value)
+
+	resp := tb.CallbackResponse{Text: MsgOK}
+	if err := tc.Callback(WithController(ctx, ctrl), c); err != nil {
+		if errors.Is(err, BackPressed) {
+			trace.Log(ctx, "callback", "back is pressed (user)")
+			return cc.handleBackButton(ctx, c)
+		}
+		e, ok := err.(*Error)
+		if !ok {
+			respondAlert(c, PrinterContext(c, cc.fallbackLang).Sprintf(MsgUnexpected))
+			cc.reg.Unregister(cc.recipient(c), c.Callback().Message.ID)
+			return err
+		}
+		switch e.Type {
+		case TErrNoChange:
+			resp = tb.CallbackResponse{}
+		case TErrRetry:
+			c.Respond(&tb.CallbackResponse{Text: e.Msg, ShowAlert: e.Alert})
+			return e
+		default:
+			resp = tb.CallbackResponse{Text: e.Msg, ShowAlert: e.Alert}
+		}
+	}
+
+	cc.SetValue(cc.recipient(c).Recipient(), value)
+	if update != nil {
+		if err := update(c); err != nil {
+			lg.Printf("%s: error editing message: %s", cc.name, err)
+		}
+	}
+	return cc.proceed(ctx, c, &resp)
+}
+
+// proceed responds to the button press with resp, invokes the next handler and
+// unregisters the message of the controller.
+func (cc *commonCtl) proceed(ctx context.Context, c tb.Context, resp *tb.CallbackResponse) error {
+	if err := c.Respond(resp); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+	err := cc.nextHandler(c)
+	cc.reg.Unregister(cc.recipient(c), c.Callback().Message.ID)
+	return err
+}
+
+// removeMarkup removes the buttons of the message of the callback.
+func removeMarkup(c tb.Context) error {
+	_, err := c.Bot().EditReplyMarkup(c.Message(), nil)
+	return err
+}
+
+// handleBackButton acknowledges the button press and runs the handler of the
+// controller that the user came from.
+func (cc *commonCtl) handleBackButton(ctx context.Context, c tb.Context) error {
+	if err := c.Respond(&tb.CallbackResponse{}); err != nil {
+		trace.Log(ctx, "respond", err.Error())
+	}
+	cc.setBackPressed(c)
+	return cc.prevHandler(c)
+}
+
 // NewControllerChain returns the controller chain.
 //
 // Deprecated: use NewForm instead.  NewControllerChain will be removed in the next versions.
@@ -207,6 +310,14 @@ func (cc *commonCtl) Form() *Form {
 	return cc.form
 }
 
+// setRegistry replaces the controller registry with reg.  Keys are prefixed
+// with the controller name, so that reg can be shared between controllers.
+func (cc *commonCtl) setRegistry(reg Registry) {
+	if reg != nil {
+		cc.reg = namespaced(cc.name, reg)
+	}
+}
+
 // setOverwite sets overwrite flag to b.
 func (cc *commonCtl) setOverwrite(b bool) {
 	cc.overwrite = b
@@ -232,19 +343,25 @@ func (cc *commonCtl) sendOrEdit(c tb.Context, txt string, sendOpts ...interface{
 
 // getPreviousMsgID returns the ID of the previous outbound message.
 func (cc *commonCtl) getPreviousMsgID(ct tb.Context) (int, bool) {
+	if from, ok := fromCtrl(ct); ok {
+		// within the form, the previous message is the message of the
+		// controller the user came from.
+		cc.resetBackPressed(ct)
+		return from.OutgoingID(recipientOf(from, ct).Recipient())
+	}
 	if cc.isBackPressed(ct) {
 		cc.resetBackPressed(ct)
 		if cc.next == nil {
 			// internal error
 			return 0, false
 		}
-		return cc.next.OutgoingID(ct.Sender().Recipient())
+		return cc.next.OutgoingID(recipientOf(cc.next, ct).Recipient())
 	}
 	// back not pressed
 	if cc.prev == nil {
 		return 0, false
 	}
-	return cc.prev.OutgoingID(ct.Sender().Recipient())
+	return cc.prev.OutgoingID(recipientOf(cc.prev, ct).Recipient())
 }
 
 // isBackPressed returns true if the "back" button was pressed.
@@ -261,6 +378,20 @@ func (cc *commonCtl) resetBackPressed(ct tb.Context) {
 	ct.Set(BackPressed.Error(), false) // reset the context value
 }
 
+// fromCtrlKey is the context key for the controller that the user came from.
+const fromCtrlKey = "tbcomctl.from"
+
+// setFromCtrl records the controller that the user came from in the context.
+func setFromCtrl(ct tb.Context, ctrl Controller) {
+	ct.Set(fromCtrlKey, ctrl)
+}
+
+// fromCtrl returns the controller that the user came from.
+func fromCtrl(ct tb.Context) (Controller, bool) {
+	ctrl, ok := ct.Get(fromCtrlKey).(Controller)
+	return ctrl, ok && ctrl != nil
+}
+
 func unexpectedErrorText(c tb.Context, fallbackLang ...string) string {
 	pr := PrinterContext(c, fallbackLang...)
 	return pr.Sprintf(MsgUnexpected)
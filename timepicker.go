@@ -0,0 +1,425 @@
+package tbcomctl
+
+import (
+	"context"
+	"fmt"
+	"runtime/trace"
+	"strconv"
+	"strings"
+	"time"
+
+	tb "gopkg.in/telebot.v3"
+
+	"github.com/rusq/tbcomctl/v4/internal/codec"
+)
+
+// TimeLayout is the layout of the values stored by the TimePicker.
+const TimeLayout = "15:04"
+
+// TimePicker is the time of day picker, that edits the inline message with the
+// hour and minute steppers.  The value of the TimePicker is the time in the
+// TimeLayout format, or the Go duration string (see time.Duration.String) in
+// the duration mode (see TPOptDuration).  The Callback receives it as the
+// Context.Data.
+type TimePicker struct {
+	commonCtl
+
+	removeButtons bool
+	backBtn       bool
+	localeClock   bool // 12-hour clock for the locales that use it.
+
+	tvc        TextCallbacker
+	doneBtnTxt Texter
+	backBtnTxt Texter
+
+	step     int // granularity in minutes.
+	initial  int // initial value in minutes.
+	duration bool
+	max      int // maximum duration in minutes.
+}
+
+var _ Controller = &TimePicker{}
+
+const (
+	minutesInDay = 24 * 60
+	defTimeStep  = 15 // minutes
+	stepperUp    = "+"
+	stepperDown  = "−"
+)
+
+// clock12Langs are the languages that use the 12-hour clock.
+var clock12Langs = map[string]bool{
+	"en": true, "en-us": true, "en-ca": true, "en-au": true, "en-nz": true, "en-in": true, "en-ph": true,
+	"hi": true, "bn": true, "ur": true, "ar": true, "ko": true, "fil": true, "tl": true,
+}
+
+// uses12Hour returns true if the language uses the 12-hour clock.
+func uses12Hour(lang string) bool {
+	lang = strings.ToLower(lang)
+	if ok, found := clock12Langs[lang]; found {
+		return ok
+	}
+	if i := strings.IndexByte(lang, '-'); i > 0 && lang[:i] != "en" {
+		return clock12Langs[lang[:i]]
+	}
+	return false
+}
+
+type TimePickerOption func(*TimePicker)
+
+// TPOptStep sets the granularity of the minute stepper, the default is 15
+// minutes.  It must divide the hour evenly, or be the whole number of hours,
+// that divides the day evenly, in which case only the hour stepper is shown.
+// It panics, if the step is invalid.
+func TPOptStep(d time.Duration) TimePickerOption {
+	m := int(d / time.Minute)
+	if d%time.Minute != 0 || m <= 0 || (m < 60 && 60%m != 0) || (m >= 60 && (m%60 != 0 || minutesInDay%m != 0)) {
+		panic(fmt.Sprintf("tbcomctl: invalid time picker step %s: it must divide the hour or the day evenly", d))
+	}
+	return func(tp *TimePicker) {
+		tp.step = m
+	}
+}
+
+// TPOptInitial sets the value that is shown initially, the time of day is
+// set as the duration since midnight.
+func TPOptInitial(d time.Duration) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.initial = int(d / time.Minute)
+	}
+}
+
+// TPOptDuration enables the duration mode, the user picks the duration up to
+// max, and the value is stored as the Go duration string.
+func TPOptDuration(max time.Duration) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.duration = true
+		tp.max = int(max / time.Minute)
+	}
+}
+
+// TPOptLocaleClock sets the Locale Clock option.  If set, the time is shown on
+// the 12-hour clock to the users with the locales that use it, otherwise the
+// 24-hour clock is used.  It doesn't affect the stored value.
+func TPOptLocaleClock(b bool) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.localeClock = b
+	}
+}
+
+// TPOptBtnDone sets the text of the Done button.
+func TPOptBtnDone(texter Texter) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.doneBtnTxt = texter
+	}
+}
+
+// TPOptBtnBack adds the back button to the time picker.
+func TPOptBtnBack(texter Texter) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.backBtn = true
+		tp.backBtnTxt = texter
+	}
+}
+
+// TPOptRemoveButtons set the Remove Buttons option.  If Remove Buttons is
+// set, the buttons will be removed once the user presses Done.
+func TPOptRemoveButtons(b bool) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.removeButtons = b
+	}
+}
+
+func TPOptOverwrite(b bool) TimePickerOption {
+	return func(tp *TimePicker) {
+		tp.commonCtl.setOverwrite(b)
+	}
+}
+
+func TPOptPrivateOnly(b bool) TimePickerOption {
+	return func(tp *TimePicker) {
+		optPrivateOnly(b)(&tp.commonCtl)
+	}
+}
+
+func TPOptFallbackLang(lang string) TimePickerOption {
+	return func(tp *TimePicker) {
+		optFallbackLang(lang)(&tp.commonCtl)
+	}
+}
+
+// TPOptRegistry sets the registry that stores the time picker state.
+func TPOptRegistry(reg Registry) TimePickerOption {
+	return func(tp *TimePicker) {
+		optRegistry(reg)(&tp.commonCtl)
+	}
+}
+
+// TPOptScope sets the scope of the time picker state, see Scope.
+func TPOptScope(s Scope) TimePickerOption {
+	return func(tp *TimePicker) {
+		optScope(s)(&tp.commonCtl)
+	}
+}
+
+// NewTimePicker creates a new time picker.  TextCallbacker.Text produces the
+// text of the message, TextCallbacker.Callback is called when the user presses
+// Done.
+func NewTimePicker(name string, tc TextCallbacker, opts ...TimePickerOption) *TimePicker {
+	tp := &TimePicker{
+		commonCtl:  newCommonCtl(name),
+		tvc:        tc,
+		doneBtnTxt: NewTexter(MsgDone),
+		step:       defTimeStep,
+	}
+	for _, opt := range opts {
+		opt(tp)
+	}
+	return tp
+}
+
+// Handler is a handler function to use with telebot.Handle.
+func (tp *TimePicker) Handler(c tb.Context) error {
+	if tp.privateOnly && !c.Message().Private() {
+		return nil
+	}
+	text, err := tp.tvc.Text(WithController(context.Background(), tp), c)
+	if err != nil {
+		c.Send(unexpectedErrorText(c, tp.fallbackLang))
+		return fmt.Errorf("error while generating text for controller: %s: %w", tp.name, err)
+	}
+	markup, err := tp.inlineMarkup(c, tp.initialValue(c))
+	if err != nil {
+		return fmt.Errorf("error while generating markup for controller: %s: %w", tp.name, err)
+	}
+	outbound, err := tp.sendOrEdit(c, text, tp.withMarkup(markup))
+	if err != nil {
+		return err
+	}
+	_ = tp.reg.Register(tp.recipient(c), outbound.ID)
+	tp.logOutgoingMsg(outbound, "timepicker")
+	return nil
+}
+
+// initialValue returns the picked value, if the user returns to the time
+// picker, or the initial value, in minutes.
+func (tp *TimePicker) initialValue(c tb.Context) int {
+	if val, ok := tp.Value(tp.recipient(c).Recipient()); ok {
+		if n, err := tp.parse(val); err == nil {
+			return n
+		}
+	}
+	n := tp.initial - tp.initial%tp.step
+	if !tp.valid(n) {
+		return 0
+	}
+	return n
+}
+
+// format returns the value of n minutes as it is stored.
+func (tp *TimePicker) format(n int) string {
+	if tp.duration {
+		return (time.Duration(n) * time.Minute).String()
+	}
+	return fmt.Sprintf("%02d:%02d", n/60, n%60)
+}
+
+// parse parses the stored value and returns it in minutes.
+func (tp *TimePicker) parse(s string) (int, error) {
+	if tp.duration {
+		d, err := time.ParseDuration(s)
+		return int(d / time.Minute), err
+	}
+	t, err := time.Parse(TimeLayout, s)
+	return t.Hour()*60 + t.Minute(), err
+}
+
+// valid returns true if n minutes is the valid value.
+func (tp *TimePicker) valid(n int) bool {
+	if n < 0 || n%tp.step != 0 {
+		return false
+	}
+	if tp.duration {
+		return n <= tp.max
+	}
+	return n < minutesInDay
+}
+
+// add returns the value n changed by delta minutes.  The time of day wraps
+// around midnight, the duration stays within the bounds.
+func (tp *TimePicker) add(n, delta int) int {
+	n += delta
+	if !tp.duration {
+		return (n%minutesInDay + minutesInDay) % minutesInDay
+	}
+	if n < 0 {
+		return 0
+	}
+	if n > tp.max {
+		return tp.max - tp.max%tp.step
+	}
+	return n
+}
+
+// labels returns the labels of the hours and minutes of the value n for the
+// user.
+func (tp *TimePicker) labels(c tb.Context, n int) (hours, minutes string) {
+	h, m := n/60, n%60
+	if tp.duration {
+		pr := PrinterContext(c, tp.fallbackLang)
+		return pr.Sprintf(MsgHours, h), pr.Sprintf(MsgMinutes, m)
+	}
+	if tp.localeClock && uses12Hour(c.Sender().LanguageCode) {
+		suffix := "AM"
+		if h >= 12 {
+			suffix = "PM"
+		}
+		if h = h % 12; h == 0 {
+			h = 12
+		}
+		return fmt.Sprintf("%d %s", h, suffix), fmt.Sprintf("%02d", m)
+	}
+	return fmt.Sprintf("%02d", h), fmt.Sprintf("%02d", m)
+}
+
+// Callback data payloads of the time picker buttons, the value is in minutes.
+const (
+	payloadSet  = "s" // followed by the new value.
+	payloadPick = "y" // followed by the picked value.
+)
+
+// callbackID returns the ID of the time picker for the callback data.
+func (tp *TimePicker) callbackID() string {
+	return callbackID("timepicker", tp.name)
+}
+
+// Register registers the time picker callback with the dispatcher of the bot,
+// see Picklist.Register.
+func (tp *TimePicker) Register(b *tb.Bot) {
+	DispatcherFor(b).handle(tp.callbackID(), tp, tp.callback)
+}
+
+// version returns the version of the time picker settings for the callback
+// data.
+func (tp *TimePicker) version() string {
+	return codec.Version(strconv.Itoa(tp.step), strconv.FormatBool(tp.duration), strconv.Itoa(tp.max))
+}
+
+// inlineMarkup generates the steppers for the value n.
+func (tp *TimePicker) inlineMarkup(c tb.Context, n int) (*tb.ReplyMarkup, error) {
+	hourStep := 60
+	minuteStepper := tp.step < 60
+	if !minuteStepper {
+		hourStep = tp.step
+	}
+	var labels, payloads []string
+	add := func(label, payload string) {
+		labels, payloads = append(labels, label), append(payloads, payload)
+	}
+	stepper := func(label string, delta int) {
+		if v := tp.add(n, delta); v != n {
+			add(label, payloadSet+strconv.Itoa(v))
+		} else {
+			add(labelBlank, payloadNoop)
+		}
+	}
+	hours, minutes := tp.labels(c, n)
+
+	stepper(stepperUp, hourStep)
+	if minuteStepper {
+		stepper(stepperUp, tp.step)
+	}
+	add(hours, payloadNoop)
+	if minuteStepper {
+		add(minutes, payloadNoop)
+	}
+	stepper(stepperDown, -hourStep)
+	if minuteStepper {
+		stepper(stepperDown, -tp.step)
+	}
+	doneTxt, err := tp.doneBtnTxt.Text(context.Background(), c)
+	if err != nil {
+		dlg.Printf("doneTextFn returned an error: %s", err)
+	}
+	add(PrinterContext(c, tp.fallbackLang).Sprintf(doneTxt), payloadPick+strconv.Itoa(n))
+	if tp.backBtn {
+		txt, err := tp.backBtnTxt.Text(context.Background(), c)
+		if err != nil {
+			dlg.Printf("backTextFn returned an error: %s", err)
+		}
+		add(txt, payloadBack)
+	}
+
+	markup := new(tb.ReplyMarkup)
+	btns, err := encodedButtons(markup, tp.callbackID(), tp.version(), labels, payloads)
+	if err != nil {
+		return nil, err
+	}
+	cols := 1
+	if minuteStepper {
+		cols = 2
+	}
+	rows := OrganizeButtons(btns[:3*cols], cols)
+	for _, btn := range btns[3*cols:] {
+		rows = append(rows, tb.Row{btn})
+	}
+	markup.Inline(rows...)
+	tp.Register(bot(c.Bot()))
+	return markup, nil
+}
+
+// callback is the callback function that will be registered for the buttons.
+func (tp *TimePicker) callback(c tb.Context) error {
+	ctx, task := trace.NewTask(context.Background(), "TimePicker.Callback")
+	defer task.End()
+
+	tp.logCallback(c.Callback())
+
+	d, ok := decodeCallback(c, tp.callbackID(), tp.fallbackLang)
+	if !ok {
+		return nil
+	}
+	if d.Version != tp.version() {
+		trace.Log(ctx, "callback", "stale button")
+		return respondStale(c, tp.fallbackLang)
+	}
+	switch d.Payload {
+	case payloadNoop:
+		return c.Respond(&tb.CallbackResponse{})
+	case payloadBack:
+		trace.Log(ctx, "callback", "back is pressed (option)")
+		return tp.handleBackButton(ctx, c)
+	}
+	if len(d.Payload) < 2 {
+		return respondStale(c, tp.fallbackLang)
+	}
+	n, err := strconv.Atoi(d.Payload[1:])
+	if err != nil || !tp.valid(n) {
+		trace.Log(ctx, "callback", "invalid value")
+		return respondStale(c, tp.fallbackLang)
+	}
+	switch d.Payload[:1] {
+	case payloadSet:
+		markup, err := tp.inlineMarkup(c, n)
+		if err != nil {
+			return err
+		}
+		if _, err := c.Bot().EditReplyMarkup(c.Message(), markup); err != nil {
+			trace.Log(ctx, "EditReplyMarkup", err.Error())
+		}
+		return c.Respond(&tb.CallbackResponse{})
+	case payloadPick:
+		return tp.done(ctx, c, n)
+	}
+	return respondStale(c, tp.fallbackLang)
+}
+
+// done calls the user callback with the picked value n and invokes the next
+// handler.
+func (tp *TimePicker) done(ctx context.Context, c tb.Context, n int) error {
+	var update func(tb.Context) error
+	if tp.removeButtons {
+		update = removeMarkup
+	}
+	return tp.pickValue(ctx, c, tp, tp.tvc, tp.format(n), update)
+}
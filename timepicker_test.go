@@ -0,0 +1,101 @@
+package tbcomctl
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestTimePicker(t *testing.T) {
+	b, log := newRecordingBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	var got []string
+	tp := NewTimePicker("time", NewStaticTVC("Delivery time?", nil, func(_ context.Context, c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	}), TPOptInitial(23*time.Hour+50*time.Minute))
+	c := newTestCallback(b, user, "")
+
+	n := tp.initialValue(c)
+	assert.Equal(t, 23*60+45, n, "initial value is rounded to the step")
+	m, err := tp.inlineMarkup(c, n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{stepperUp, stepperUp}, {"23", "45"}, {stepperDown, stepperDown}, {"Done"}}, markupLabels(m))
+
+	// the time wraps around midnight.
+	assert.NoError(t, tp.callback(pressButton(t, b, user, m, stepperUp)))
+	edit := log.call(len(log.methods()) - 1)
+	assert.Equal(t, "editMessageReplyMarkup", edit.Method)
+	assert.Contains(t, edit.Params["reply_markup"], `"text":"00"`)
+
+	m, _ = tp.inlineMarkup(c, 9*60+30)
+	assert.NoError(t, tp.callback(pressButton(t, b, user, m, "Done")))
+	assert.Equal(t, []string{"09:30"}, got)
+	val, _ := tp.Value(user.Recipient())
+	assert.Equal(t, "09:30", val)
+	assert.Equal(t, 9*60+30, tp.initialValue(c), "time picker returns to the picked value")
+
+	// the button of the time picker with the other step.
+	tp.step = 1
+	m, _ = tp.inlineMarkup(c, 9*60+31)
+	tp.step = 15
+	press := pressButton(t, b, user, m, "Done")
+	assert.NoError(t, tp.callback(press))
+	assert.Len(t, got, 1)
+	assert.Equal(t, MsgBtnStale, press.lastResponse().Text)
+}
+
+func TestTimePicker_labels(t *testing.T) {
+	tests := []struct {
+		name string
+		tp   *TimePicker
+		lang string
+		n    int
+		want [][]string
+	}{
+		{"24h", NewTimePicker("t", nil, TPOptLocaleClock(true)), "ru", 13*60 + 15, [][]string{{stepperUp, stepperUp}, {"13", "15"}, {stepperDown, stepperDown}, {"Готово"}}},
+		{"12h", NewTimePicker("t", nil, TPOptLocaleClock(true)), "en", 13*60 + 15, [][]string{{stepperUp, stepperUp}, {"1 PM", "15"}, {stepperDown, stepperDown}, {"Done"}}},
+		{"12h midnight", NewTimePicker("t", nil, TPOptLocaleClock(true)), "en-US", 0, [][]string{{stepperUp, stepperUp}, {"12 AM", "00"}, {stepperDown, stepperDown}, {"Done"}}},
+		{"12h en-GB", NewTimePicker("t", nil, TPOptLocaleClock(true)), "en-GB", 13 * 60, [][]string{{stepperUp, stepperUp}, {"13", "00"}, {stepperDown, stepperDown}, {"Done"}}},
+		{"12h off", NewTimePicker("t", nil), "en", 13 * 60, [][]string{{stepperUp, stepperUp}, {"13", "00"}, {stepperDown, stepperDown}, {"Done"}}},
+		{"hour step", NewTimePicker("t", nil, TPOptStep(2*time.Hour)), "en", 8 * 60, [][]string{{stepperUp}, {"08"}, {stepperDown}, {"Done"}}},
+		{"duration", NewTimePicker("t", nil, TPOptDuration(2*time.Hour), TPOptBtnBack(NewTexter("Back"))), "ru", 90, [][]string{{stepperUp, stepperUp}, {"1 ч", "30 мин"}, {stepperDown, stepperDown}, {"Готово"}, {"Back"}}},
+		{"duration max", NewTimePicker("t", nil, TPOptDuration(2*time.Hour)), "en", 120, [][]string{{labelBlank, labelBlank}, {"2 h", "0 min"}, {stepperDown, stepperDown}, {"Done"}}},
+		{"duration zero", NewTimePicker("t", nil, TPOptDuration(2*time.Hour)), "en", 0, [][]string{{stepperUp, stepperUp}, {"0 h", "0 min"}, {labelBlank, labelBlank}, {"Done"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			m, err := tt.tp.inlineMarkup(newTestCallback(newTestBot(t), &tb.User{ID: 1, LanguageCode: tt.lang}, ""), tt.n)
+			if err != nil {
+				t.Fatal(err)
+			}
+			assert.Equal(t, tt.want, markupLabels(m))
+		})
+	}
+}
+
+func TestTPOptStep(t *testing.T) {
+	for _, d := range []time.Duration{time.Minute, 20 * time.Minute, time.Hour, 6 * time.Hour, 24 * time.Hour} {
+		assert.NotPanics(t, func() { TPOptStep(d) }, d)
+	}
+	for _, d := range []time.Duration{0, -time.Hour, 90 * time.Second, 7 * time.Minute, 90 * time.Minute, 5 * time.Hour} {
+		assert.Panics(t, func() { TPOptStep(d) }, d)
+	}
+}
+
+func TestTimePicker_duration(t *testing.T) {
+	tp := NewTimePicker("t", nil, TPOptDuration(10*time.Hour))
+	assert.Equal(t, "1h30m0s", tp.format(90))
+	n, err := tp.parse("1h30m0s")
+	assert.NoError(t, err)
+	assert.Equal(t, 90, n)
+	assert.Equal(t, 0, tp.add(15, -60))
+	assert.Equal(t, 600, tp.add(590, 60))
+	assert.False(t, tp.valid(615))
+}
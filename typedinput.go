@@ -0,0 +1,144 @@
+package tbcomctl
+
+import (
+	"math"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/text/message"
+	tb "gopkg.in/telebot.v3"
+)
+
+// DateLayout is the layout of the values stored by the date input.
+const DateLayout = "2006-01-02"
+
+// parseFunc validates the trimmed user input s and returns the normalized
+// value.  It should return NewInputError with the message printed with pr, if
+// the input is invalid.
+type parseFunc func(pr *message.Printer, s string) (string, error)
+
+// typedInput creates the Input that stores the normalized value returned by
+// parse.  Errors are printed in the language of the sender.  The value
+// resolver set by the caller with IOptValueResolver is chained: parse receives
+// the value that it returns, instead of the message text.  The value is
+// resolved before the callback, which receives the normalized value as the
+// Context.Data.
+func typedInput(name string, tc TextCallbacker, parse parseFunc, opts ...InputOption) *Input {
+	ip := NewInput(name, tc, opts...)
+	ip.resolveFirst = true
+	resolve := ip.valueResolverFn
+	ip.valueResolverFn = func(m *tb.Message) (string, error) {
+		s, err := resolve(m)
+		if err != nil {
+			return "", err
+		}
+		return parse(ip.printer(m), strings.TrimSpace(s))
+	}
+	return ip
+}
+
+// NewIntInput creates the Input that accepts an integer between min and max
+// inclusive.
+func NewIntInput(name string, tc TextCallbacker, min, max int64, opts ...InputOption) *Input {
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		n, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			return "", NewInputError(pr.Sprintf(MsgInvalidInt))
+		}
+		if n < min || max < n {
+			return "", NewInputError(pr.Sprintf(MsgIntRange, min, max))
+		}
+		return strconv.FormatInt(n, 10), nil
+	}, opts...)
+}
+
+// NewDecimalInput creates the Input that accepts a decimal number, comma is
+// accepted as the decimal separator.  The stored value uses the dot.
+func NewDecimalInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		f, err := strconv.ParseFloat(strings.Replace(s, ",", ".", 1), 64)
+		if err != nil || math.IsNaN(f) || math.IsInf(f, 0) {
+			return "", NewInputError(pr.Sprintf(MsgInvalidDecimal))
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64), nil
+	}, opts...)
+}
+
+// NewRegexpInput creates the Input that accepts the text that matches re.
+func NewRegexpInput(name string, tc TextCallbacker, re *regexp.Regexp, opts ...InputOption) *Input {
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		if !re.MatchString(s) {
+			return "", NewInputError(pr.Sprintf(MsgInvalidValue))
+		}
+		return s, nil
+	}, opts...)
+}
+
+// NewEmailInput creates the Input that accepts an email address.  The domain
+// of the stored address is lower cased.
+func NewEmailInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		addr, err := mail.ParseAddress(s)
+		if err != nil || addr.Address != s {
+			// display names and comments are not allowed.
+			return "", NewInputError(pr.Sprintf(MsgInvalidEmail))
+		}
+		at := strings.LastIndexByte(s, '@')
+		if !strings.Contains(s[at:], ".") {
+			return "", NewInputError(pr.Sprintf(MsgInvalidEmail))
+		}
+		return s[:at] + strings.ToLower(s[at:]), nil
+	}, opts...)
+}
+
+var (
+	phoneSeparators = strings.NewReplacer(" ", "", "-", "", "(", "", ")", "", ".", "")
+	e164Rx          = regexp.MustCompile(`^\+[1-9]\d{7,14}$`)
+)
+
+// NewPhoneInput creates the Input that accepts a phone number in the
+// international format.  Spaces, dashes, dots and parentheses are ignored, the
+// leading 00 is accepted instead of the plus sign.  The stored value is in the
+// E.164 format, i.e. +14155552671.
+func NewPhoneInput(name string, tc TextCallbacker, opts ...InputOption) *Input {
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		phone := phoneSeparators.Replace(s)
+		if strings.HasPrefix(phone, "00") {
+			phone = "+" + phone[2:]
+		}
+		if !e164Rx.MatchString(phone) {
+			return "", NewInputError(pr.Sprintf(MsgInvalidPhone))
+		}
+		return phone, nil
+	}, opts...)
+}
+
+// NewDateInput creates the Input that accepts a date in the layout, see
+// time.Parse.  The stored value is in the DateLayout (ISO 8601) format.
+func NewDateInput(name string, tc TextCallbacker, layout string, opts ...InputOption) *Input {
+	example := time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC).Format(layout)
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		t, err := time.Parse(layout, s)
+		if err != nil {
+			return "", NewInputError(pr.Sprintf(MsgInvalidDate, example))
+		}
+		return t.Format(DateLayout), nil
+	}, opts...)
+}
+
+// NewOneOfInput creates the Input that accepts one of the values, the case is
+// ignored.  The stored value is the matching item of values.
+func NewOneOfInput(name string, tc TextCallbacker, values []string, opts ...InputOption) *Input {
+	values = append([]string(nil), values...)
+	return typedInput(name, tc, func(pr *message.Printer, s string) (string, error) {
+		for _, v := range values {
+			if strings.EqualFold(v, s) {
+				return v, nil
+			}
+		}
+		return "", NewInputError(pr.Sprintf(MsgInvalidChoice, strings.Join(values, ", ")))
+	}, opts...)
+}
@@ -0,0 +1,96 @@
+package tbcomctl
+
+import (
+	"context"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	tb "gopkg.in/telebot.v3"
+)
+
+func TestTypedInputs(t *testing.T) {
+	tc := NewStaticTVC("Enter the value", nil, nil)
+	tests := []struct {
+		name    string
+		ip      *Input
+		text    string
+		want    string
+		wantErr string
+	}{
+		{"int", NewIntInput("n", tc, -10, 10), " +07 ", "7", ""},
+		{"int min", NewIntInput("n", tc, -10, 10), "-10", "-10", ""},
+		{"int out of range", NewIntInput("n", tc, -10, 10), "11", "", "❌ Введите число от -10 до 10."},
+		{"int not a number", NewIntInput("n", tc, -10, 10), "1.5", "", "❌ Введите целое число."},
+		{"decimal", NewDecimalInput("d", tc), "3,50", "3.5", ""},
+		{"decimal exp", NewDecimalInput("d", tc), "1e3", "1000", ""},
+		{"decimal nan", NewDecimalInput("d", tc), "NaN", "", "❌ Введите число."},
+		{"regexp", NewRegexpInput("r", tc, regexp.MustCompile(`^[A-Z]{3}$`)), "ABC", "ABC", ""},
+		{"regexp mismatch", NewRegexpInput("r", tc, regexp.MustCompile(`^[A-Z]{3}$`)), "abc", "", "❌ Неверное значение, попробуйте еще раз."},
+		{"email", NewEmailInput("e", tc), "John.Doe@Example.COM", "John.Doe@example.com", ""},
+		{"email display name", NewEmailInput("e", tc), "John <john@example.com>", "", "❌ Введите правильный адрес электронной почты."},
+		{"email no domain", NewEmailInput("e", tc), "john@localhost", "", "❌ Введите правильный адрес электронной почты."},
+		{"phone", NewPhoneInput("p", tc), "+1 (415) 555-2671", "+14155552671", ""},
+		{"phone 00", NewPhoneInput("p", tc), "0044 20 7946 0958", "+442079460958", ""},
+		{"phone local", NewPhoneInput("p", tc), "555-2671", "", "❌ Введите номер телефона в международном формате, например +74951234567."},
+		{"phone letters", NewPhoneInput("p", tc), "+1 415 CALL ME", "", "❌ Введите номер телефона в международном формате, например +74951234567."},
+		{"date", NewDateInput("dt", tc, "02.01.2006"), "09.03.2024", "2024-03-09", ""},
+		{"date invalid", NewDateInput("dt", tc, "02.01.2006"), "30.02.2024", "", "❌ Введите дату в формате: 31.12.2023."},
+		{"one of", NewOneOfInput("o", tc, []string{"Red", "Green"}), "green", "Green", ""},
+		{"one of unknown", NewOneOfInput("o", tc, []string{"Red", "Green"}), "blue", "", "❌ Введите одно из значений: Red, Green."},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.ip.valueResolverFn(&tb.Message{Text: tt.text, Sender: &tb.User{LanguageCode: "ru"}})
+			if tt.wantErr != "" {
+				var e *Error
+				if assert.ErrorAs(t, err, &e) {
+					assert.Equal(t, TInputError, e.Type)
+					assert.Equal(t, tt.wantErr, e.Msg)
+				}
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTypedInputs_fallbackLang(t *testing.T) {
+	ip := NewIntInput("n", NewStaticTVC("Enter the value", nil, nil), 1, 10, IOptFallbackLang("ru"))
+	_, err := ip.valueResolverFn(&tb.Message{Text: "x", Sender: &tb.User{}})
+	var e *Error
+	if assert.ErrorAs(t, err, &e) {
+		assert.Equal(t, "❌ Введите целое число.", e.Msg)
+	}
+}
+
+func TestInput_OnTextMw(t *testing.T) {
+	b := newTestBot(t)
+	user := &tb.User{ID: 42, LanguageCode: "en"}
+
+	var got []string
+	ip := NewPhoneInput("phone", NewStaticTVC("Phone?", nil, func(_ context.Context, c tb.Context) error {
+		got = append(got, c.Data())
+		return nil
+	}), IOptValueResolver(func(m *tb.Message) (string, error) { return strings.TrimPrefix(m.Text, "tel:"), nil }))
+	b.Handle(tb.OnText, ip.OnTextMw(nil))
+
+	send := func(text string) {
+		b.ProcessUpdate(tb.Update{Message: &tb.Message{ID: 2, Sender: user, Chat: &tb.Chat{ID: user.ID}, Text: text}})
+	}
+
+	ip.reg.Wait(user, 1)
+	send("not a phone")
+	assert.Empty(t, got, "callback is not called for the invalid input")
+	assert.True(t, ip.reg.IsWaiting(user), "user is asked to retry")
+	_, ok := ip.Value(user.Recipient())
+	assert.False(t, ok)
+
+	send("tel:+44 20 7946 0958")
+	assert.Equal(t, []string{"+442079460958"}, got, "callback receives the normalized value of the caller resolver")
+	val, _ := ip.Value(user.Recipient())
+	assert.Equal(t, "+442079460958", val)
+	assert.False(t, ip.reg.IsWaiting(user))
+}